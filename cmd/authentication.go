@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/hasher"
+	"github.com/pkg/errors"
+)
+
+// Authenticator abstraction for authentication service.
+type Authenticator interface {
+	Authenticate(ctx context.Context, email, password string) (*entities.User, error)
+}
+
+// LoginAttempts tracks failed logins per email, locking emails failing too
+// many times in a row.
+type LoginAttempts interface {
+	Locked(ctx context.Context, email string) (bool, time.Duration, error)
+	Fail(ctx context.Context, email string) error
+	Reset(ctx context.Context, email string) error
+}
+
+// Authenticate holds authentication domain logic. Locked emails are rejected
+// before their password is checked.
+func (s *Service) Authenticate(ctx context.Context, email, password string) (*entities.User, error) {
+	email = entities.NormalizeEmail(email, s.LowercaseEmail)
+
+	if s.Attempts != nil {
+		locked, _, err := s.Attempts.Locked(ctx, email)
+		if err != nil {
+			return nil, errors.Wrap(err, "login attempts locked")
+		}
+
+		if locked {
+			return nil, svcerrors.ErrAccountLocked
+		}
+	}
+
+	user, err := s.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, svcerrors.ErrUserNotFound) {
+			// unknown emails fail as known ones, so neither lockouts nor
+			// response times tell them apart.
+			s.compareDummy(password)
+			return nil, s.failLogin(ctx, email)
+		}
+
+		return nil, errors.Wrap(err, "repository find by email")
+	}
+
+	if err := s.Hasher.Compare(user.Password, password); err != nil {
+		return nil, s.failLogin(ctx, email)
+	}
+
+	if s.Attempts != nil {
+		if err := s.Attempts.Reset(ctx, email); err != nil {
+			return nil, errors.Wrap(err, "login attempts reset")
+		}
+	}
+
+	if r, ok := s.Hasher.(hasher.Rehasher); ok && r.NeedsRehash(user.Password) {
+		s.rehash(ctx, user, password)
+	}
+
+	return user, nil
+}
+
+// dummyPassword is hashed into the hash passwords of unknown emails are
+// compared against.
+const dummyPassword = "dummy password of unknown emails"
+
+// compareDummy compares password against a hash made once by the hasher,
+// so unknown emails pay the same hashing cost as known ones.
+func (s *Service) compareDummy(password string) {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = s.Hasher.Hash(dummyPassword)
+	})
+
+	s.Hasher.Compare(s.dummyHash, password)
+}
+
+// failLogin records a failed login of email and returns ErrInvalidCredentials.
+func (s *Service) failLogin(ctx context.Context, email string) error {
+	if s.Attempts != nil {
+		if err := s.Attempts.Fail(ctx, email); err != nil {
+			return errors.Wrap(err, "login attempts fail")
+		}
+	}
+
+	return svcerrors.ErrInvalidCredentials
+}
+
+// rehash upgrades password hash of the user to the current hasher settings.
+// Failures keep the old hash, the upgrade is retried on the next login.
+func (s *Service) rehash(ctx context.Context, u *entities.User, password string) {
+	hash, err := s.Hasher.Hash(password)
+	if err != nil {
+		return
+	}
+
+	if err := s.UpdatePassword(ctx, u.ID, hash); err != nil {
+		return
+	}
+	u.Password = hash
+}
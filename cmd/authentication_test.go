@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/hasher"
+	"github.com/newtondev/service_object/pkg/lockout"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthentication(t *testing.T) {
+	t.Log("with registered user.")
+	{
+		repo := testStorage()
+		s := Service{Repository: repo, Hasher: fakeHasher{}}
+		ctx := context.Background()
+
+		_, err := repo.Create(ctx, testForm("auth@domain.zone", "qwerty"))
+		assert.Nil(t, err)
+
+		t.Log("\ttest:0\tshould return user for valid credentials.")
+		{
+			u, err := s.Authenticate(ctx, "auth@domain.zone", "qwerty")
+			assert.Nil(t, err)
+			assert.Equal(t, "auth@domain.zone", u.Email)
+		}
+
+		t.Log("\ttest:1\tshould reject wrong password.")
+		{
+			u, err := s.Authenticate(ctx, "auth@domain.zone", "other")
+			assert.Nil(t, u)
+			assert.Equal(t, svcerrors.ErrInvalidCredentials, err)
+		}
+
+		t.Log("\ttest:2\tshould reject missing user with the same error.")
+		{
+			u, err := s.Authenticate(ctx, "missing@domain.zone", "qwerty")
+			assert.Nil(t, u)
+			assert.Equal(t, svcerrors.ErrInvalidCredentials, err)
+		}
+
+		t.Log("\ttest:3\tshould compare the password of a missing user like of a known one.")
+		{
+			h := &countingHasher{}
+			s := Service{Repository: repo, Hasher: h}
+
+			for _, email := range []string{"auth@domain.zone", "missing@domain.zone", "other@domain.zone"} {
+				_, err := s.Authenticate(ctx, email, "wrong")
+				assert.Equal(t, svcerrors.ErrInvalidCredentials, err)
+			}
+			assert.Equal(t, 3, h.compares)
+			assert.Equal(t, 1, h.hashes)
+		}
+	}
+}
+
+// countingHasher is a fakeHasher counting its calls.
+type countingHasher struct {
+	fakeHasher
+	hashes, compares int
+}
+
+func (h *countingHasher) Hash(password string) (string, error) {
+	h.hashes++
+	return h.fakeHasher.Hash(password)
+}
+
+func (h *countingHasher) Compare(hash, password string) error {
+	h.compares++
+	return h.fakeHasher.Compare(hash, password)
+}
+
+func TestAuthenticationRehash(t *testing.T) {
+	t.Log("with user hashed at the minimum bcrypt cost.")
+	{
+		ctx := context.Background()
+		repo := testStorage()
+		repo.Hasher = hasher.NewBcrypt(bcrypt.MinCost)
+
+		u, err := repo.Create(ctx, testForm("auth@domain.zone", "qwerty"))
+		assert.Nil(t, err)
+
+		t.Log("\ttest:0\tshould keep hash at the configured cost.")
+		{
+			s := Service{Repository: repo, Hasher: hasher.NewBcrypt(bcrypt.MinCost)}
+
+			_, err := s.Authenticate(ctx, "auth@domain.zone", "qwerty")
+			assert.Nil(t, err)
+
+			stored, err := repo.FindByID(ctx, u.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, u.Password, stored.Password)
+		}
+
+		t.Log("\ttest:1\tshould rehash below-cost hash with the configured cost.")
+		{
+			s := Service{Repository: repo, Hasher: hasher.NewBcrypt(bcrypt.MinCost + 1)}
+
+			authed, err := s.Authenticate(ctx, "auth@domain.zone", "qwerty")
+			assert.Nil(t, err)
+
+			stored, err := repo.FindByID(ctx, u.ID)
+			assert.Nil(t, err)
+			assert.NotEqual(t, u.Password, stored.Password)
+			assert.Equal(t, stored.Password, authed.Password)
+
+			cost, err := bcrypt.Cost([]byte(stored.Password))
+			assert.Nil(t, err)
+			assert.Equal(t, bcrypt.MinCost+1, cost)
+
+			_, err = s.Authenticate(ctx, "auth@domain.zone", "qwerty")
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestAuthenticationLockout(t *testing.T) {
+	t.Log("with lockout after three failed logins for a minute.")
+	{
+		ctx := context.Background()
+		repo := testStorage()
+		_, err := repo.Create(ctx, testForm("auth@domain.zone", "qwerty"))
+		assert.Nil(t, err)
+
+		clock := &fakeClock{now: testNow}
+		attempts := lockout.NewMemory(3, time.Minute)
+		attempts.Clock = clock
+		s := Service{Repository: repo, Hasher: fakeHasher{}, Attempts: attempts}
+
+		t.Log("\ttest:0\tshould reset failures on successful login.")
+		{
+			for i := 0; i < 2; i++ {
+				_, err := s.Authenticate(ctx, "auth@domain.zone", "other")
+				assert.Equal(t, svcerrors.ErrInvalidCredentials, err)
+			}
+
+			_, err := s.Authenticate(ctx, "auth@domain.zone", "qwerty")
+			assert.Nil(t, err)
+
+			_, err = s.Authenticate(ctx, "auth@domain.zone", "other")
+			assert.Equal(t, svcerrors.ErrInvalidCredentials, err)
+		}
+
+		t.Log("\ttest:1\tshould lock the account on reaching the threshold.")
+		{
+			for i := 0; i < 2; i++ {
+				_, err := s.Authenticate(ctx, "auth@domain.zone", "other")
+				assert.Equal(t, svcerrors.ErrInvalidCredentials, err)
+			}
+
+			u, err := s.Authenticate(ctx, "auth@domain.zone", "qwerty")
+			assert.Nil(t, u)
+			assert.Equal(t, svcerrors.ErrAccountLocked, err)
+		}
+
+		t.Log("\ttest:2\tshould unlock the account after the cooldown.")
+		{
+			clock.Advance(time.Minute)
+
+			u, err := s.Authenticate(ctx, "auth@domain.zone", "qwerty")
+			assert.Nil(t, err)
+			assert.Equal(t, "auth@domain.zone", u.Email)
+		}
+
+		t.Log("\ttest:3\tshould lock unknown emails alike.")
+		{
+			for i := 0; i < 3; i++ {
+				_, err := s.Authenticate(ctx, "missing@domain.zone", "qwerty")
+				assert.Equal(t, svcerrors.ErrInvalidCredentials, err)
+			}
+
+			_, err := s.Authenticate(ctx, "missing@domain.zone", "qwerty")
+			assert.Equal(t, svcerrors.ErrAccountLocked, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/transport"
+)
+
+// DefaultMaxBatch is a number of forms a batch may hold when none is configured.
+const DefaultMaxBatch = 100
+
+// BatchResponse reports outcomes of batch items in request order.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// BatchResult is an outcome of a single batch item, either the created user
+// or the error it failed with.
+type BatchResult struct {
+	Index  int                     `json:"index"`
+	Status int                     `json:"status"`
+	User   *transport.UserResponse `json:"user,omitempty"`
+	Error  *ErrorBody              `json:"error,omitempty"`
+}
+
+// BatchRegistrationHandler registers every form of a JSON array on its own,
+// so a failing item does not fail the others.
+type BatchRegistrationHandler struct {
+	Registrator
+	// MaxBodyBytes limits request body size, DefaultMaxBodyBytes is used when zero.
+	MaxBodyBytes int64
+	// MaxBatch limits number of forms, DefaultMaxBatch is used when zero.
+	MaxBatch int
+	// Schema is optional, when set every item is validated against it
+	// before decoding.
+	Schema *SchemaValidator
+	// Translator is optional, when set validation messages are translated
+	// to the locale of the Accept-Language header, English is used otherwise.
+	Translator Translator
+	// ConcealExisting keeps taken emails from being revealed, items of new
+	// and taken emails are both reported as accepted without the user.
+	ConcealExisting bool
+}
+
+// ServeHTTP implements http.Handler.
+func (h *BatchRegistrationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, ok := readJSON(w, r, h.MaxBodyBytes)
+	if !ok {
+		return
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorBody{Code: CodeInvalidJSON, Message: err.Error()})
+		return
+	}
+
+	max := h.MaxBatch
+	if max <= 0 {
+		max = DefaultMaxBatch
+	}
+
+	switch {
+	case len(items) == 0:
+		writeError(w, http.StatusBadRequest, ErrorBody{Code: CodeInvalidBatch, Message: "batch is empty"})
+		return
+	case len(items) > max:
+		writeError(w, http.StatusRequestEntityTooLarge, ErrorBody{Code: CodeBatchTooLarge, Message: fmt.Sprintf("batch must hold at most %d forms", max)})
+		return
+	}
+
+	resp := BatchResponse{Results: make([]BatchResult, 0, len(items))}
+	seen := make(map[string]bool, len(items))
+	for i, item := range items {
+		resp.Results = append(resp.Results, h.register(r, i, item, seen))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMultiStatus)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// register registers the batch item at index i. Emails of earlier items are
+// kept in seen, a repeated one is reported as taken without registering it,
+// since both would pass the uniqueness check of the store.
+func (h *BatchRegistrationHandler) register(r *http.Request, i int, item []byte, seen map[string]bool) BatchResult {
+	f, status, e := parseForm(item, h.Schema)
+	if f == nil {
+		return BatchResult{Index: i, Status: status, Error: &e}
+	}
+
+	key := entities.NormalizeEmail(f.Email, true)
+	if seen[key] {
+		if h.ConcealExisting {
+			return BatchResult{Index: i, Status: http.StatusAccepted}
+		}
+		return h.failed(r, i, ValidationErrors{"email": constants.EmailExists})
+	}
+	seen[key] = true
+
+	u, err := h.Register(WithSourceIP(r.Context(), clientIP(r)), f)
+	if h.ConcealExisting {
+		if err = concealTaken(err); err == nil {
+			return BatchResult{Index: i, Status: http.StatusAccepted}
+		}
+	}
+	if err != nil {
+		return h.failed(r, i, err)
+	}
+
+	return BatchResult{Index: i, Status: http.StatusCreated, User: transport.NewUserResponse(u.Safe())}
+}
+
+// failed returns result of the batch item at index i failed with err.
+func (h *BatchRegistrationHandler) failed(r *http.Request, i int, err error) BatchResult {
+	if h.Translator != nil {
+		err = translate(err, h.Translator, h.Translator.Locale(r.Header.Get("Accept-Language")))
+	}
+
+	status, e := errorStatus(err)
+	return BatchResult{Index: i, Status: status, Error: &e}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchRegistration(t *testing.T) {
+	t.Log("with initialized server.")
+	{
+		repo := testStorage()
+		srv, err := NewServer(Config{}, ioutil.Discard, repo, prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		t.Log("\ttest:0\tshould register valid items of a mixed batch.")
+		{
+			body := `[
+				{"email": "a@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"},
+				{"email": "invalid", "password": "qwerty", "password_confirmation": "qwerty"},
+				{"email": "exists@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"},
+				{"email": "c@domain.zone", "passwrod": "qwerty"},
+				{"email": "b@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}
+			]`
+			resp, err := http.Post(s.URL+"/register/batch", "application/json", strings.NewReader(body))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+
+			var b BatchResponse
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&b))
+			assert.Len(t, b.Results, 5)
+
+			statuses := make([]int, 0, len(b.Results))
+			for i, r := range b.Results {
+				assert.Equal(t, i, r.Index)
+				statuses = append(statuses, r.Status)
+			}
+			assert.Equal(t, []int{201, 422, 422, 400, 201}, statuses)
+
+			assert.Equal(t, "a@domain.zone", b.Results[0].User.Email)
+			assert.Nil(t, b.Results[0].Error)
+			assert.Equal(t, CodeValidationFailed, b.Results[1].Error.Code)
+			assert.Equal(t, constants.EmailExists, b.Results[2].Error.Fields["email"])
+			assert.Equal(t, CodeUnknownField, b.Results[3].Error.Code)
+			assert.Equal(t, "b@domain.zone", b.Results[4].User.Email)
+			assert.Len(t, repo.Users, 3)
+		}
+
+		t.Log("\ttest:1\tshould reject body which is not an array.")
+		{
+			resp, err := http.Post(s.URL+"/register/batch", "application/json", strings.NewReader(`{"email": "a@domain.zone"}`))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+func TestBatchRegistrationSize(t *testing.T) {
+	t.Log("with batch handler limited to two forms.")
+	{
+		h := BatchRegistrationHandler{Registrator: fakeRegistrator{}, MaxBatch: 2}
+		form := `{"email": "a@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`
+
+		t.Log("\ttest:0\tshould accept batch within the limit.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register/batch", "["+form+","+form+"]"))
+			assert.Equal(t, http.StatusMultiStatus, w.Code)
+		}
+
+		t.Log("\ttest:1\tshould reject batch over the limit.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register/batch", "["+form+","+form+","+form+"]"))
+			assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, CodeBatchTooLarge, e.Error.Code)
+		}
+
+		t.Log("\ttest:2\tshould reject empty batch.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register/batch", "[]"))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, CodeInvalidBatch, e.Error.Code)
+		}
+	}
+}
+
+func TestBatchRegistrationDuplicates(t *testing.T) {
+	t.Log("with batch handler on a registrator accepting every form.")
+	{
+		h := BatchRegistrationHandler{Registrator: fakeRegistrator{}}
+
+		t.Log("\ttest:0\tshould register only the first of identical emails.")
+		{
+			body := `[
+				{"email": "a@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"},
+				{"email": "b@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"},
+				{"email": "A@Domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}
+			]`
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register/batch", body))
+			assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+			var b BatchResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&b))
+			assert.Len(t, b.Results, 3)
+
+			assert.Equal(t, http.StatusCreated, b.Results[0].Status)
+			assert.Equal(t, "a@domain.zone", b.Results[0].User.Email)
+			assert.Equal(t, http.StatusCreated, b.Results[1].Status)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, b.Results[2].Status)
+			assert.Nil(t, b.Results[2].User)
+			assert.Equal(t, constants.EmailExists, b.Results[2].Error.Fields["email"])
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/pkg/errors"
+)
+
+// CompositeValidator runs every validator and merges their validation errors.
+// The first error that is not a validation error stops the run and is returned.
+type CompositeValidator []Validator
+
+// Validate implements Validator.
+func (c CompositeValidator) Validate(ctx context.Context, f *entities.Form) error {
+	return c.run(func(v Validator) error {
+		return v.Validate(ctx, f)
+	})
+}
+
+// ValidateUpdate implements Validator.
+func (c CompositeValidator) ValidateUpdate(ctx context.Context, u *entities.User, f *entities.Form) error {
+	return c.run(func(v Validator) error {
+		return v.ValidateUpdate(ctx, u, f)
+	})
+}
+
+// ValidatePassword implements Validator.
+func (c CompositeValidator) ValidatePassword(ctx context.Context, password string) error {
+	return c.run(func(v Validator) error {
+		return v.ValidatePassword(ctx, password)
+	})
+}
+
+// run calls validate for every validator merging validation errors, the
+// first error of a field wins. Messages of validators reporting no rules
+// are merged without one.
+func (c CompositeValidator) run(validate func(Validator) error) error {
+	var validations FieldErrors
+	for _, v := range c {
+		err := validate(v)
+		if err == nil {
+			continue
+		}
+
+		var fe FieldErrors
+		if !errors.As(err, &fe) {
+			var vs ValidationErrors
+			if !errors.As(err, &vs) {
+				return err
+			}
+
+			for _, field := range sortedKeys(vs) {
+				fe = append(fe, FieldError{Field: field, Message: vs[field]})
+			}
+		}
+
+		for _, e := range fe {
+			if !validations.Has(e.Field) {
+				validations = append(validations, e)
+			}
+		}
+	}
+
+	if len(validations) > 0 {
+		return validations
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompositeValidator(t *testing.T) {
+	t.Log("with composed validators.")
+	{
+		ctx := context.Background()
+		f := testForm("new@domain.zone", "qwerty")
+
+		t.Log("\ttest:0\tshould merge errors of every validator.")
+		{
+			c := CompositeValidator{
+				staticValidator{err: ValidationErrors{"email": "email is invalid"}},
+				staticValidator{err: errors.Wrap(ValidationErrors{"password": "password mismatch", "email": "email exists"}, "wrapped")},
+			}
+
+			assert.Equal(t, ValidationErrors{"email": "email is invalid", "password": "password mismatch"}, validationMap(c.Validate(ctx, f)))
+		}
+
+		t.Log("\ttest:1\tshould pass when every validator passes.")
+		{
+			c := CompositeValidator{staticValidator{}, staticValidator{}}
+			assert.Nil(t, c.Validate(ctx, f))
+		}
+
+		t.Log("\ttest:2\tshould stop on the first non validation error.")
+		{
+			boom := errors.New("boom")
+			called := false
+			c := CompositeValidator{
+				staticValidator{err: ValidationErrors{"email": "email is invalid"}},
+				staticValidator{err: boom},
+				staticValidator{called: &called},
+			}
+
+			assert.Equal(t, boom, c.ValidatePassword(ctx, "qwerty"))
+			assert.False(t, called)
+		}
+	}
+}
+
+// staticValidator returns the configured error from every validation.
+type staticValidator struct {
+	err    error
+	called *bool
+}
+
+func (v staticValidator) Validate(context.Context, *entities.Form) error {
+	return v.result()
+}
+
+func (v staticValidator) ValidateUpdate(context.Context, *entities.User, *entities.Form) error {
+	return v.result()
+}
+
+func (v staticValidator) ValidatePassword(context.Context, string) error {
+	return v.result()
+}
+
+func (v staticValidator) result() error {
+	if v.called != nil {
+		*v.called = true
+	}
+
+	return v.err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/pkg/errors"
+)
+
+// AcceptedResponse is a neutral registration response, it reads the same
+// whether the email was free or taken.
+type AcceptedResponse struct {
+	Message string `json:"message"`
+}
+
+// writeAccepted responds with the neutral registration response.
+func writeAccepted(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(AcceptedResponse{Message: constants.RegistrationAccepted})
+}
+
+// concealTaken returns err without the error of a taken email, nil when it
+// was the only one. Other errors are returned as they are.
+func concealTaken(err error) error {
+	if errors.Is(err, svcerrors.ErrEmailExists) {
+		return nil
+	}
+
+	var fe FieldErrors
+	if errors.As(err, &fe) {
+		var rest FieldErrors
+		for _, e := range fe {
+			if !takenEmail(e.Field, e.Message) {
+				rest = append(rest, e)
+			}
+		}
+		if len(rest) == 0 {
+			return nil
+		}
+
+		return rest
+	}
+
+	var v ValidationErrors
+	if errors.As(err, &v) && takenEmail("email", v["email"]) {
+		if len(v) == 1 {
+			return nil
+		}
+
+		rest := make(ValidationErrors, len(v)-1)
+		for field, msg := range v {
+			if field != "email" {
+				rest[field] = msg
+			}
+		}
+
+		return rest
+	}
+
+	return err
+}
+
+// onlyTaken reports whether err fails only because the email is taken.
+func onlyTaken(err error) bool {
+	return err != nil && concealTaken(err) == nil
+}
+
+// takenEmail reports whether msg of field tells the email is taken.
+func takenEmail(field, msg string) bool {
+	return field == "email" && msg == constants.EmailExists
+}
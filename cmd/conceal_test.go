@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+func TestConcealTaken(t *testing.T) {
+	t.Log("with errors of a taken email.")
+	{
+		t.Log("\ttest:0\tshould drop the error when it is the only one.")
+		{
+			assert.Nil(t, concealTaken(errors.Wrap(svcerrors.ErrEmailExists, "repository create")))
+			assert.Nil(t, concealTaken(FieldErrors{{Field: "email", Rule: RuleUnique, Message: constants.EmailExists}}))
+			assert.Nil(t, concealTaken(ValidationErrors{"email": constants.EmailExists}))
+		}
+
+		t.Log("\ttest:1\tshould keep errors of other fields.")
+		{
+			assert.Equal(t, FieldErrors{{Field: "password", Rule: RuleConfirmation, Message: constants.PasswordMismatch}}, concealTaken(errors.Wrap(FieldErrors{
+				{Field: "password", Rule: RuleConfirmation, Message: constants.PasswordMismatch},
+				{Field: "email", Rule: RuleUnique, Message: constants.EmailExists},
+			}, "validator validate")))
+			assert.Equal(t, ValidationErrors{"password": constants.PasswordMismatch}, concealTaken(ValidationErrors{"email": constants.EmailExists, "password": constants.PasswordMismatch}))
+		}
+
+		t.Log("\ttest:2\tshould keep other errors as they are.")
+		{
+			invalid := FieldErrors{{Field: "email", Rule: RuleEmail, Message: "email is invalid"}}
+			assert.Equal(t, invalid, concealTaken(invalid))
+
+			boom := errors.New("boom")
+			assert.Equal(t, boom, concealTaken(boom))
+			assert.Nil(t, concealTaken(nil))
+		}
+	}
+}
+
+func TestConcealExisting(t *testing.T) {
+	serve := func(cfg Config, method, target, body string) *httptest.ResponseRecorder {
+		srv, err := NewServer(cfg, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, jsonRequest(method, target, body))
+		return w
+	}
+	taken := `{"email": "exists@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`
+
+	t.Log("with revealing registrations by default.")
+	{
+		t.Log("\ttest:0\tshould report the taken email.")
+		{
+			w := serve(Config{}, "POST", "/register", taken)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, map[string]string{"email": constants.EmailExists}, e.Error.Fields)
+		}
+
+		t.Log("\ttest:1\tshould serve email availability.")
+		{
+			w := serve(Config{}, "GET", "/register/email-available?email=exists@domain.zone", "")
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+	}
+
+	t.Log("with concealed existing emails.")
+	{
+		cfg := Config{ConcealExisting: true}
+
+		t.Log("\ttest:0\tshould accept a taken email with the neutral response.")
+		{
+			w := serve(cfg, "POST", "/register", taken)
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			assert.JSONEq(t, `{"message": "`+constants.RegistrationAccepted+`"}`, w.Body.String())
+		}
+
+		t.Log("\ttest:1\tshould respond the same to a new email.")
+		{
+			w := serve(cfg, "POST", "/register", `{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`)
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			assert.JSONEq(t, `{"message": "`+constants.RegistrationAccepted+`"}`, w.Body.String())
+			assert.Empty(t, w.Header().Get("Location"))
+		}
+
+		t.Log("\ttest:2\tshould report other errors without the taken email.")
+		{
+			w := serve(cfg, "POST", "/register", `{"email": "exists@domain.zone", "password": "qwerty", "password_confirmation": "qwertz"}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, map[string]string{"password": constants.PasswordMismatch}, e.Error.Fields)
+		}
+
+		t.Log("\ttest:3\tshould pass dry runs of a taken email.")
+		{
+			w := serve(cfg, "POST", "/register/validate", taken)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		t.Log("\ttest:4\tshould not serve email availability.")
+		{
+			w := serve(cfg, "GET", "/register/email-available?email=exists@domain.zone", "")
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+func TestConcealTiming(t *testing.T) {
+	register := func(conceal bool, email string) *countingHasher {
+		h := &countingHasher{}
+		repo := testStorage()
+		repo.Hasher = h
+		s := Service{Validator: &PlayValidator{Validator: validator.New(), Repository: repo}, Repository: repo, Hasher: h, ConcealExisting: conceal}
+
+		s.Register(context.Background(), testForm(email, "qwerty"))
+		return h
+	}
+
+	t.Log("with service concealing taken emails.")
+	{
+		t.Log("\ttest:0\tshould hash passwords of new and taken emails alike.")
+		{
+			assert.Equal(t, 1, register(true, "new@domain.zone").hashes)
+			assert.Equal(t, 1, register(true, "exists@domain.zone").hashes)
+		}
+	}
+
+	t.Log("with service reporting taken emails.")
+	{
+		t.Log("\ttest:0\tshould not hash passwords of taken emails.")
+		{
+			assert.Equal(t, 0, register(false, "exists@domain.zone").hashes)
+		}
+	}
+}
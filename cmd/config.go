@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/idempotency"
+	"github.com/newtondev/service_object/pkg/mailer"
+	"github.com/newtondev/service_object/pkg/transport"
+	"github.com/pkg/errors"
+	"go.yaml.in/yaml/v3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Default http server timeouts. Writes get more time than the default
+// request timeout, so timed out requests still receive their error.
+const (
+	// DefaultReadTimeout limits reading a whole request, slow clients
+	// trickling bytes are cut off after it.
+	DefaultReadTimeout = 15 * time.Second
+	// DefaultWriteTimeout limits handling a request and writing its response.
+	DefaultWriteTimeout = 60 * time.Second
+	// DefaultIdleTimeout limits how long keep-alive connections wait for
+	// the next request.
+	DefaultIdleTimeout = 2 * time.Minute
+)
+
+// Config holds server configuration.
+type Config struct {
+	Addr string
+	// GRPCAddr is an address of the gRPC server, it is not started when empty.
+	GRPCAddr string
+	Debug    bool
+	// DBDSN is a postgres connection string, users are kept in memory when empty.
+	DBDSN string
+	// RedisAddr is an address of redis caching known emails, uniqueness is
+	// always checked in the database when empty.
+	RedisAddr string
+	// UniqueCacheTTL is a time known emails are cached for.
+	UniqueCacheTTL time.Duration
+	// SMTPAddr is an address of the mail server welcome emails are sent
+	// through, no emails are sent when empty.
+	SMTPAddr string
+	// SMTPFrom is a sender address of emails.
+	SMTPFrom string
+	// SMTPUsername and SMTPPassword authenticate to the mail server, no
+	// authentication is used when the username is empty.
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPAttempts bounds deliveries of an email, transient failures are
+	// retried after SMTPBackoff doubling with every retry.
+	SMTPAttempts int
+	SMTPBackoff  time.Duration
+	// JWTSecret signs issued tokens, tokens are disabled when empty.
+	JWTSecret []byte
+	// BcryptCost is a cost of password hashing.
+	BcryptCost int
+	// Pepper is a secret keying passwords before hashing, passwords are not
+	// peppered when empty. New hashes are tagged with PepperVersion, hashes
+	// of peppers rotated out are verified with OldPeppers by version.
+	Pepper        []byte
+	PepperVersion int
+	OldPeppers    map[int][]byte
+	// TLSCert and TLSKey are PEM files the server is served over TLS with,
+	// plaintext http and gRPC are served when empty.
+	TLSCert string
+	TLSKey  string
+	// TLSMinVersion is a minimum accepted TLS version.
+	TLSMinVersion uint16
+	// RequestTimeout cancels registration requests, zero disables the limit.
+	RequestTimeout time.Duration
+	// ShutdownTimeout is a time to wait for in-flight requests on shutdown.
+	ShutdownTimeout time.Duration
+	// ReadTimeout, WriteTimeout and IdleTimeout limit connections of the
+	// http server, zero disables a limit.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// MaxBodyBytes limits registration request bodies.
+	MaxBodyBytes int64
+	// MaxBatch limits number of forms in a batch registration.
+	MaxBatch int
+	// RateLimit is a number of registration requests per second allowed for
+	// a client IP, zero disables the limit.
+	RateLimit float64
+	// RateBurst is a number of registration requests a client IP can make at once.
+	RateBurst int
+	// LockoutThreshold is a number of failed logins in a row locking the
+	// account for LockoutCooldown, zero disables the lockout.
+	LockoutThreshold int
+	LockoutCooldown  time.Duration
+	// PasswordHistory is a number of previous passwords, besides the current
+	// one, that changed passwords cannot reuse, zero disables the check.
+	PasswordHistory int
+	// IdempotencyTTL is a time registration responses are replayed for a
+	// repeated Idempotency-Key, zero disables replaying.
+	IdempotencyTTL time.Duration
+	// CORSOrigins are origins allowed to make cross-origin requests, all are
+	// denied when empty.
+	CORSOrigins []string
+	// MinPassword and MaxPassword bound password length in characters.
+	MinPassword int
+	MaxPassword int
+	// MinEntropy is a password strength in bits passwords must reach, zero
+	// disables the check.
+	MinEntropy float64
+	// RequireNames makes first and last name required on registration.
+	RequireNames bool
+	// RequireTerms makes accepting the terms of service required on
+	// registration.
+	RequireTerms bool
+	// ConcealExisting keeps registrations from revealing taken emails, they
+	// are accepted like new ones over HTTP and gRPC. NotifyExisting emails
+	// owners of taken emails instead.
+	ConcealExisting bool
+	NotifyExisting  bool
+	// BlockedDomains are disposable email domains rejected on registration.
+	BlockedDomains []string
+	// AllowedDomains are the only email domains accepted on registration,
+	// all are accepted when empty.
+	AllowedDomains []string
+	// RegistrationDisabled starts the server with registrations paused, they
+	// can be resumed at runtime by admins.
+	RegistrationDisabled bool
+	// JSONNaming is a casing of JSON response keys.
+	JSONNaming transport.Naming
+	// PublicURL is a base URL of the service links in emails point to, they
+	// are relative when empty.
+	PublicURL string
+	// ReadyWriteCheck makes readiness checks write a canary row to the
+	// repository, so a read-only database is reported as not ready.
+	ReadyWriteCheck bool
+}
+
+// flagEnv maps flags to environment variables overriding their defaults.
+var flagEnv = map[string]string{
+	"addr":                  "SERVICE_ADDR",
+	"grpc-addr":             "SERVICE_GRPC_ADDR",
+	"debug":                 "SERVICE_DEBUG",
+	"registration-disabled": "REGISTRATION_DISABLED",
+	"db-dsn":                "DB_DSN",
+	"redis-addr":            "REDIS_ADDR",
+	"smtp-addr":             "SMTP_ADDR",
+	"smtp-from":             "SMTP_FROM",
+	"smtp-username":         "SMTP_USERNAME",
+	"smtp-password":         "SMTP_PASSWORD",
+	"jwt-secret":            "JWT_SECRET",
+	"tls-cert":              "TLS_CERT",
+	"tls-key":               "TLS_KEY",
+	"pepper":                "PASSWORD_PEPPER",
+	"old-peppers":           "PASSWORD_OLD_PEPPERS",
+	"blocked-domains-file":  "BLOCKED_DOMAINS_FILE",
+	"allowed-domains":       "ALLOWED_DOMAINS",
+	"cors-origins":          "CORS_ORIGINS",
+	"public-url":            "PUBLIC_URL",
+}
+
+// LoadConfig reads configuration from environment variables and command line
+// flags, flags take precedence over environment. main reads the file named by
+// CONFIG_FILE with LoadConfigFile instead when it is set.
+func LoadConfig() (Config, error) {
+	return loadConfig(flag.CommandLine, os.Args[1:])
+}
+
+// LoadConfigFile reads configuration from the YAML or JSON file at path,
+// environment variables and command line flags. Environment takes
+// precedence over the file and flags over both.
+func LoadConfigFile(path string) (Config, error) {
+	file, err := readConfigFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return loadConfigFrom(flag.CommandLine, os.Args[1:], file)
+}
+
+// loadConfig registers flags in fs, sets them from environment and parses
+// args.
+func loadConfig(fs *flag.FlagSet, args []string) (Config, error) {
+	return loadConfigFrom(fs, args, nil)
+}
+
+// loadConfigFrom registers flags in fs and sets them from file values by
+// flag name, then from environment and args.
+func loadConfigFrom(fs *flag.FlagSet, args []string, file map[string]string) (Config, error) {
+	var (
+		cfg     Config
+		secret  string
+		pepper  string
+		peppers string
+		origins string
+		blocked string
+		allowed string
+		tlsMin  string
+		naming  string
+	)
+
+	fs.StringVar(&cfg.Addr, "addr", ":8080", "address of the http server")
+	fs.StringVar(&cfg.GRPCAddr, "grpc-addr", "", "address of the grpc server, it is not started when empty")
+	fs.BoolVar(&cfg.Debug, "debug", false, "enable debug")
+	fs.BoolVar(&cfg.RegistrationDisabled, "registration-disabled", false, "start with registrations paused")
+	fs.StringVar(&cfg.DBDSN, "db-dsn", "", "postgres connection string, users are kept in memory when empty")
+	fs.StringVar(&cfg.RedisAddr, "redis-addr", "", "address of redis caching known emails, no cache is used when empty")
+	fs.DurationVar(&cfg.UniqueCacheTTL, "unique-cache-ttl", time.Hour, "time known emails are cached for")
+	fs.StringVar(&cfg.SMTPAddr, "smtp-addr", "", "address of the mail server welcome emails are sent through, no emails are sent when empty")
+	fs.StringVar(&cfg.SMTPFrom, "smtp-from", "", "sender address of emails")
+	fs.StringVar(&cfg.SMTPUsername, "smtp-username", "", "username of the mail server, no authentication is used when empty")
+	fs.StringVar(&cfg.SMTPPassword, "smtp-password", "", "password of the mail server")
+	fs.IntVar(&cfg.SMTPAttempts, "smtp-attempts", mailer.DefaultAttempts, "max deliveries of an email failing transiently")
+	fs.DurationVar(&cfg.SMTPBackoff, "smtp-backoff", mailer.DefaultBackoff, "wait before the first retry of a failed delivery, doubled with every retry")
+	fs.StringVar(&secret, "jwt-secret", "", "HMAC secret for issued tokens, tokens are disabled when empty")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", "", "PEM certificate file, plaintext http and grpc are served when empty")
+	fs.StringVar(&cfg.TLSKey, "tls-key", "", "PEM private key file of the certificate")
+	fs.StringVar(&tlsMin, "tls-min-version", "1.2", "minimum accepted TLS version, one of 1.0, 1.1, 1.2, 1.3")
+	fs.IntVar(&cfg.BcryptCost, "bcrypt-cost", bcrypt.DefaultCost, "bcrypt cost used for password hashing")
+	fs.StringVar(&pepper, "pepper", "", "secret keying passwords before hashing, passwords are not peppered when empty")
+	fs.IntVar(&cfg.PepperVersion, "pepper-version", 1, "version of the pepper, bump it when the pepper is rotated")
+	fs.StringVar(&peppers, "old-peppers", "", "comma separated version:pepper pairs of rotated peppers still verified")
+	fs.Int64Var(&cfg.MaxBodyBytes, "max-body-bytes", DefaultMaxBodyBytes, "max size of a request body in bytes")
+	fs.IntVar(&cfg.MaxBatch, "max-batch", DefaultMaxBatch, "max number of forms in a batch registration")
+	fs.DurationVar(&cfg.RequestTimeout, "request-timeout", 30*time.Second, "max duration of a request, zero disables the limit")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", 10*time.Second, "time to wait for in-flight requests on shutdown")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", DefaultReadTimeout, "max duration of reading a request, zero disables the limit")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", DefaultWriteTimeout, "max duration of handling a request and writing its response, zero disables the limit")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", DefaultIdleTimeout, "max duration keep-alive connections wait for the next request, zero disables the limit")
+	fs.Float64Var(&cfg.RateLimit, "rate-limit", 1, "registration requests per second per client IP, zero disables the limit")
+	fs.IntVar(&cfg.RateBurst, "rate-burst", 5, "registration requests a client IP can make at once")
+	fs.IntVar(&cfg.LockoutThreshold, "lockout-threshold", 5, "failed logins in a row locking the account, zero disables the lockout")
+	fs.DurationVar(&cfg.LockoutCooldown, "lockout-cooldown", 15*time.Minute, "time an account stays locked after too many failed logins")
+	fs.IntVar(&cfg.PasswordHistory, "password-history", 5, "previous passwords changed passwords cannot reuse, zero disables the check")
+	fs.IntVar(&cfg.MinPassword, "password-min", DefaultMinPassword, "min password length in characters")
+	fs.IntVar(&cfg.MaxPassword, "password-max", DefaultMaxPassword, "max password length in characters")
+	fs.Float64Var(&cfg.MinEntropy, "password-min-entropy", 0, "min estimated password entropy in bits, zero disables the check")
+	fs.BoolVar(&cfg.RequireNames, "require-names", false, "require first and last name on registration")
+	fs.BoolVar(&cfg.ConcealExisting, "conceal-existing-emails", false, "accept registrations of taken emails like new ones instead of reporting them, email availability is not served")
+	fs.BoolVar(&cfg.NotifyExisting, "notify-existing-accounts", false, "email owners of taken emails registering again, requires -conceal-existing-emails")
+	fs.BoolVar(&cfg.RequireTerms, "require-terms", false, "require accepting the terms of service on registration")
+	fs.DurationVar(&cfg.IdempotencyTTL, "idempotency-ttl", idempotency.DefaultTTL, "time registration responses are replayed for a repeated Idempotency-Key, zero disables replaying")
+	fs.StringVar(&blocked, "blocked-domains-file", "", "file with disposable email domains to reject, one per line")
+	fs.StringVar(&allowed, "allowed-domains", "", "comma separated email domains accepted on registration, all are accepted when empty")
+	fs.BoolVar(&cfg.ReadyWriteCheck, "ready-write-check", false, "write a canary row to the database on readiness checks")
+	fs.StringVar(&naming, "json-naming", string(transport.SnakeCase), "casing of JSON response keys, one of snake_case, camelCase")
+	fs.StringVar(&cfg.PublicURL, "public-url", "", "base URL of the service links in emails point to, they are relative when empty")
+	fs.StringVar(&origins, "cors-origins", "", "comma separated origins allowed to make cross-origin requests")
+
+	for name, v := range file {
+		if fs.Lookup(name) == nil {
+			return cfg, errors.Errorf("unknown config key %q", name)
+		}
+		if err := fs.Set(name, v); err != nil {
+			return cfg, errors.Wrapf(err, "parse config key %q", name)
+		}
+	}
+	for name, key := range flagEnv {
+		if v, ok := os.LookupEnv(key); ok {
+			if err := fs.Set(name, v); err != nil {
+				return cfg, errors.Wrapf(err, "parse %s", key)
+			}
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	var err error
+	cfg.JWTSecret = []byte(secret)
+	cfg.Pepper = []byte(pepper)
+	if cfg.OldPeppers, err = parsePeppers(peppers); err != nil {
+		return cfg, err
+	}
+	if cfg.TLSMinVersion, err = tlsVersion(tlsMin); err != nil {
+		return cfg, err
+	}
+	cfg.CORSOrigins = splitList(origins)
+	cfg.AllowedDomains = splitList(allowed)
+	if cfg.JSONNaming, err = transport.ParseNaming(naming); err != nil {
+		return cfg, err
+	}
+
+	if blocked != "" {
+		if cfg.BlockedDomains, err = loadDomainsFile(blocked); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks required values are set and within range.
+func (c Config) Validate() error {
+	switch {
+	case c.Addr == "":
+		return errors.New("addr is required")
+	case (c.TLSCert == "") != (c.TLSKey == ""):
+		return errors.New("tls cert and key must be set together")
+	case c.SMTPAddr != "" && c.SMTPFrom == "":
+		return errors.New("smtp from is required with smtp addr")
+	case c.NotifyExisting && !c.ConcealExisting:
+		return errors.New("notify existing accounts requires conceal existing emails")
+	case c.SMTPAttempts < 1:
+		return errors.New("smtp attempts must be positive")
+	case c.SMTPBackoff < 0:
+		return errors.New("smtp backoff must not be negative")
+	case c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost:
+		return errors.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	case len(c.Pepper) > 0 && c.PepperVersion < 1:
+		return errors.New("pepper version must be positive")
+	case len(c.OldPeppers) > 0 && len(c.Pepper) == 0:
+		return errors.New("old peppers require a pepper")
+	case c.OldPeppers[c.PepperVersion] != nil:
+		return errors.New("old peppers must not reuse the pepper version")
+	case c.MaxBodyBytes <= 0:
+		return errors.New("max body bytes must be positive")
+	case c.MaxBatch < 1:
+		return errors.New("max batch must be positive")
+	case c.MinPassword < 1 || c.MaxPassword < c.MinPassword:
+		return errors.New("password length bounds must be positive with min not above max")
+	case c.MinEntropy < 0:
+		return errors.New("password min entropy must not be negative")
+	case c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0:
+		return errors.New("server timeouts must not be negative")
+	case c.UniqueCacheTTL < 0:
+		return errors.New("unique cache ttl must not be negative")
+	case c.IdempotencyTTL < 0:
+		return errors.New("idempotency ttl must not be negative")
+	case c.RateLimit < 0:
+		return errors.New("rate limit must not be negative")
+	case c.RateLimit > 0 && c.RateBurst < 1:
+		return errors.New("rate burst must be positive")
+	case c.PasswordHistory < 0:
+		return errors.New("password history must not be negative")
+	case c.LockoutThreshold < 0:
+		return errors.New("lockout threshold must not be negative")
+	case c.LockoutThreshold > 0 && c.LockoutCooldown <= 0:
+		return errors.New("lockout cooldown must be positive")
+	}
+
+	return nil
+}
+
+// tlsVersion parses TLS version number like 1.2.
+func tlsVersion(s string) (uint16, error) {
+	switch s {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+
+	return 0, errors.Errorf("unsupported tls version %q", s)
+}
+
+// splitList splits comma separated values dropping empty ones.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+// parsePeppers parses comma separated version:pepper pairs.
+func parsePeppers(s string) (map[int][]byte, error) {
+	list := splitList(s)
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	peppers := make(map[int][]byte, len(list))
+	for _, p := range list {
+		v, pepper, ok := strings.Cut(p, ":")
+		version, err := strconv.Atoi(v)
+		if !ok || err != nil || pepper == "" {
+			return nil, errors.New("old peppers must be version:pepper pairs")
+		}
+
+		peppers[version] = []byte(pepper)
+	}
+
+	return peppers, nil
+}
+
+// loadDomainsFile reads domains listed one per line, skipping blank lines
+// and # comments.
+func loadDomainsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open domains file")
+	}
+	defer f.Close()
+
+	return readDomains(f)
+}
+
+// readDomains reads domains listed one per line, skipping blank lines and
+// # comments.
+func readDomains(r io.Reader) ([]string, error) {
+	var domains []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		domains = append(domains, strings.ToLower(line))
+	}
+
+	return domains, sc.Err()
+}
+
+// readConfigFile reads values of the YAML or JSON file at path by flag name,
+// lists are joined with commas like their flags expect.
+func readConfigFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read config file")
+	}
+
+	var raw map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		err = d.Decode(&raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &raw)
+	default:
+		return nil, errors.Errorf("unsupported config file extension %q", ext)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parse config file")
+	}
+
+	values := make(map[string]string, len(raw))
+	for name, v := range raw {
+		if values[name], err = configValue(v); err != nil {
+			return nil, errors.Wrapf(err, "config key %q", name)
+		}
+	}
+
+	return values, nil
+}
+
+// configValue formats a decoded file value the way its flag parses it.
+func configValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			list = append(list, s)
+		}
+
+		return strings.Join(list, ","), nil
+	}
+
+	return "", errors.Errorf("unsupported value %v", v)
+}
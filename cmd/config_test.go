@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Log("with environment configured.")
+	{
+		t.Setenv("SERVICE_ADDR", ":9090")
+		t.Setenv("SERVICE_DEBUG", "true")
+		t.Setenv("JWT_SECRET", "env-secret")
+		t.Setenv("DB_DSN", "postgres://localhost/users")
+
+		t.Log("\ttest:0\tshould read values from environment.")
+		{
+			cfg, err := loadConfig(testFlagSet(), nil)
+			assert.Nil(t, err)
+			assert.Equal(t, ":9090", cfg.Addr)
+			assert.True(t, cfg.Debug)
+			assert.Equal(t, []byte("env-secret"), cfg.JWTSecret)
+			assert.Equal(t, "postgres://localhost/users", cfg.DBDSN)
+		}
+
+		t.Log("\ttest:1\tshould prefer flags over environment.")
+		{
+			cfg, err := loadConfig(testFlagSet(), []string{"-addr", ":7070", "-debug=false", "-jwt-secret", "flag-secret"})
+			assert.Nil(t, err)
+			assert.Equal(t, ":7070", cfg.Addr)
+			assert.False(t, cfg.Debug)
+			assert.Equal(t, []byte("flag-secret"), cfg.JWTSecret)
+		}
+
+		t.Log("\ttest:2\tshould reject invalid environment value.")
+		{
+			t.Setenv("SERVICE_DEBUG", "maybe")
+			_, err := loadConfig(testFlagSet(), nil)
+			assert.NotNil(t, err)
+		}
+
+		t.Log("\ttest:3\tshould validate required values.")
+		{
+			t.Setenv("SERVICE_DEBUG", "false")
+			_, err := loadConfig(testFlagSet(), []string{"-addr", ""})
+			assert.NotNil(t, err)
+		}
+
+		t.Log("\ttest:4\tshould validate tls settings.")
+		{
+			cfg, err := loadConfig(testFlagSet(), []string{"-tls-cert", "cert.pem", "-tls-key", "key.pem", "-tls-min-version", "1.3"})
+			assert.Nil(t, err)
+			assert.Equal(t, uint16(tls.VersionTLS13), cfg.TLSMinVersion)
+
+			_, err = loadConfig(testFlagSet(), []string{"-tls-cert", "cert.pem"})
+			assert.NotNil(t, err)
+
+			_, err = loadConfig(testFlagSet(), []string{"-tls-min-version", "2.0"})
+			assert.NotNil(t, err)
+		}
+
+		t.Log("\ttest:5\tshould default server timeouts.")
+		{
+			cfg, err := loadConfig(testFlagSet(), nil)
+			assert.Nil(t, err)
+			assert.Equal(t, DefaultReadTimeout, cfg.ReadTimeout)
+			assert.Equal(t, DefaultWriteTimeout, cfg.WriteTimeout)
+			assert.Equal(t, DefaultIdleTimeout, cfg.IdleTimeout)
+
+			_, err = loadConfig(testFlagSet(), []string{"-read-timeout", "-1s"})
+			assert.NotNil(t, err)
+		}
+
+		t.Log("\ttest:6\tshould read the pepper with rotated ones.")
+		{
+			t.Setenv("PASSWORD_PEPPER", "pepper-two")
+			cfg, err := loadConfig(testFlagSet(), []string{"-pepper-version", "2", "-old-peppers", "1:pepper-one"})
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("pepper-two"), cfg.Pepper)
+			assert.Equal(t, 2, cfg.PepperVersion)
+			assert.Equal(t, map[int][]byte{1: []byte("pepper-one")}, cfg.OldPeppers)
+
+			_, err = loadConfig(testFlagSet(), []string{"-pepper-version", "2", "-old-peppers", "pepper-one"})
+			assert.NotNil(t, err)
+
+			_, err = loadConfig(testFlagSet(), []string{"-pepper-version", "2", "-old-peppers", "2:pepper-one"})
+			assert.NotNil(t, err)
+		}
+
+		t.Log("\ttest:7\tshould validate lockout settings.")
+		{
+			cfg, err := loadConfig(testFlagSet(), nil)
+			assert.Nil(t, err)
+			assert.Equal(t, 5, cfg.LockoutThreshold)
+			assert.Equal(t, 15*time.Minute, cfg.LockoutCooldown)
+
+			_, err = loadConfig(testFlagSet(), []string{"-lockout-cooldown", "0s"})
+			assert.NotNil(t, err)
+
+			_, err = loadConfig(testFlagSet(), []string{"-lockout-threshold", "0", "-lockout-cooldown", "0s"})
+			assert.Nil(t, err)
+		}
+
+		t.Log("\ttest:8\tshould read allowed domains.")
+		{
+			t.Setenv("ALLOWED_DOMAINS", "corp.zone, subsidiary.zone,")
+			cfg, err := loadConfig(testFlagSet(), nil)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"corp.zone", "subsidiary.zone"}, cfg.AllowedDomains)
+		}
+
+		t.Log("\ttest:9\tshould reject negative min entropy.")
+		{
+			_, err := loadConfig(testFlagSet(), []string{"-password-min-entropy", "-1"})
+			assert.EqualError(t, err, "password min entropy must not be negative")
+		}
+
+		t.Log("\ttest:10\tshould read smtp retries.")
+		{
+			cfg, err := loadConfig(testFlagSet(), []string{"-smtp-attempts", "5", "-smtp-backoff", "2s"})
+			assert.Nil(t, err)
+			assert.Equal(t, 5, cfg.SMTPAttempts)
+			assert.Equal(t, 2*time.Second, cfg.SMTPBackoff)
+
+			_, err = loadConfig(testFlagSet(), []string{"-smtp-attempts", "0"})
+			assert.EqualError(t, err, "smtp attempts must be positive")
+		}
+
+		t.Log("\ttest:11\tshould notify existing accounts only when concealing them.")
+		{
+			_, err := loadConfig(testFlagSet(), []string{"-notify-existing-accounts"})
+			assert.EqualError(t, err, "notify existing accounts requires conceal existing emails")
+
+			cfg, err := loadConfig(testFlagSet(), []string{"-conceal-existing-emails", "-notify-existing-accounts"})
+			assert.Nil(t, err)
+			assert.True(t, cfg.ConcealExisting)
+			assert.True(t, cfg.NotifyExisting)
+		}
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	t.Log("with server timeouts configured.")
+	{
+		cfg := Config{ReadTimeout: 5 * time.Second, WriteTimeout: 45 * time.Second, IdleTimeout: time.Minute}
+		srv, err := NewServer(cfg, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+
+		t.Log("\ttest:0\tshould set the timeouts of the server.")
+		{
+			assert.Equal(t, 5*time.Second, srv.ReadTimeout)
+			assert.Equal(t, 45*time.Second, srv.WriteTimeout)
+			assert.Equal(t, time.Minute, srv.IdleTimeout)
+		}
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	load := func(path string, args ...string) (Config, error) {
+		file, err := readConfigFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		return loadConfigFrom(testFlagSet(), args, file)
+	}
+
+	t.Log("with yaml config file.")
+	{
+		path := write("config.yaml", `addr: ":9000"
+debug: true
+read-timeout: 5s
+rate-limit: 2.5
+password-min: 8
+allowed-domains:
+  - corp.zone
+  - subsidiary.zone
+`)
+
+		t.Log("\ttest:0\tshould read values by flag name.")
+		{
+			cfg, err := load(path)
+			assert.Nil(t, err)
+			assert.Equal(t, ":9000", cfg.Addr)
+			assert.True(t, cfg.Debug)
+			assert.Equal(t, 5*time.Second, cfg.ReadTimeout)
+			assert.Equal(t, 2.5, cfg.RateLimit)
+			assert.Equal(t, 8, cfg.MinPassword)
+			assert.Equal(t, []string{"corp.zone", "subsidiary.zone"}, cfg.AllowedDomains)
+		}
+
+		t.Log("\ttest:1\tshould keep defaults of values not in the file.")
+		{
+			cfg, err := load(path)
+			assert.Nil(t, err)
+			assert.Equal(t, DefaultWriteTimeout, cfg.WriteTimeout)
+		}
+
+		t.Log("\ttest:2\tshould prefer environment over the file.")
+		{
+			t.Setenv("SERVICE_ADDR", ":9090")
+			cfg, err := load(path)
+			assert.Nil(t, err)
+			assert.Equal(t, ":9090", cfg.Addr)
+			assert.True(t, cfg.Debug)
+		}
+
+		t.Log("\ttest:3\tshould prefer flags over environment and the file.")
+		{
+			cfg, err := load(path, "-addr", ":7070", "-password-min", "10")
+			assert.Nil(t, err)
+			assert.Equal(t, ":7070", cfg.Addr)
+			assert.Equal(t, 10, cfg.MinPassword)
+		}
+	}
+
+	t.Log("with json config file.")
+	{
+		t.Log("\ttest:0\tshould read values by flag name.")
+		{
+			cfg, err := load(write("config.json", `{"grpc-addr": ":9001", "max-batch": 10, "cors-origins": ["https://a.zone", "https://b.zone"]}`))
+			assert.Nil(t, err)
+			assert.Equal(t, ":9001", cfg.GRPCAddr)
+			assert.Equal(t, 10, cfg.MaxBatch)
+			assert.Equal(t, []string{"https://a.zone", "https://b.zone"}, cfg.CORSOrigins)
+		}
+
+		t.Log("\ttest:1\tshould validate the merged config.")
+		{
+			_, err := load(write("invalid.json", `{"password-min": 10, "password-max": 5}`))
+			assert.EqualError(t, err, "password length bounds must be positive with min not above max")
+		}
+	}
+
+	t.Log("with invalid config files.")
+	{
+		t.Log("\ttest:0\tshould reject unknown keys.")
+		{
+			_, err := load(write("unknown.yaml", "adress: :9000\n"))
+			assert.EqualError(t, err, `unknown config key "adress"`)
+		}
+
+		t.Log("\ttest:1\tshould reject values their flags cannot parse.")
+		{
+			_, err := load(write("bad.yaml", "read-timeout: soon\n"))
+			assert.ErrorContains(t, err, `parse config key "read-timeout"`)
+		}
+
+		t.Log("\ttest:2\tshould reject unsupported formats.")
+		{
+			_, err := load(write("config.toml", "addr = ':9000'\n"))
+			assert.EqualError(t, err, `unsupported config file extension ".toml"`)
+		}
+
+		t.Log("\ttest:3\tshould reject nested values.")
+		{
+			_, err := load(write("nested.yaml", "smtp:\n  addr: mail.zone:25\n"))
+			assert.ErrorContains(t, err, `config key "smtp"`)
+		}
+	}
+}
+
+func testFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type userAgentKey struct{}
+
+// WithContextValues stores client IP and user agent of the request in its
+// context, so they can be logged by the domain layer.
+func WithContextValues(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithSourceIP(r.Context(), clientIP(r))
+		ctx = context.WithValue(ctx, userAgentKey{}, r.UserAgent())
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// UserAgentFromContext returns user agent of the request carried by ctx.
+func UserAgentFromContext(ctx context.Context) string {
+	ua, _ := ctx.Value(userAgentKey{}).(string)
+	return ua
+}
+
+// ContextField names a context value added to log lines.
+type ContextField struct {
+	Name string
+	Key  interface{}
+}
+
+// ContextRegistry lists context values loggers pull from the context.
+type ContextRegistry []ContextField
+
+// LogContext is a registry of context values RegistratorWithLog logs.
+var LogContext = ContextRegistry{
+	{Name: "request_id", Key: requestIDKey{}},
+	{Name: "client_ip", Key: sourceIPKey{}},
+	{Name: "user_agent", Key: userAgentKey{}},
+}
+
+// Fields returns name and value pairs of registered values present in ctx.
+func (r ContextRegistry) Fields(ctx context.Context) []interface{} {
+	var fields []interface{}
+	for _, f := range r {
+		if v := ctx.Value(f.Key); v != nil && v != "" {
+			fields = append(fields, f.Name, v)
+		}
+	}
+
+	return fields
+}
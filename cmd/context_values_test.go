@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextValues(t *testing.T) {
+	t.Log("with request passing through context middleware.")
+	{
+		var ctx context.Context
+		h := WithRequestID(WithContextValues(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx = r.Context()
+		})))
+
+		r := httptest.NewRequest("POST", "/register", nil)
+		r.RemoteAddr = "192.0.2.7:4321"
+		r.Header.Set("User-Agent", "test-agent/1.0")
+		r.Header.Set(RequestIDHeader, "req-42")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		t.Log("\ttest:0\tshould store request values in context.")
+		{
+			assert.Equal(t, "192.0.2.7", SourceIP(ctx))
+			assert.Equal(t, "test-agent/1.0", UserAgentFromContext(ctx))
+			assert.Equal(t, []interface{}{"request_id", "req-42", "client_ip", "192.0.2.7", "user_agent", "test-agent/1.0"}, LogContext.Fields(ctx))
+		}
+
+		t.Log("\ttest:1\tshould log values as named fields without the raw context.")
+		{
+			var stdout, stderr bytes.Buffer
+			rl := NewRegistratorWithLog(fakeRegistrator{}, &stdout, &stderr, false)
+
+			_, err := rl.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+			assert.Contains(t, stdout.String(), "request_id: req-42")
+			assert.Contains(t, stdout.String(), "client_ip: 192.0.2.7")
+			assert.Contains(t, stdout.String(), "user_agent: test-agent/1.0")
+			assert.NotContains(t, stdout.String(), "WithValue")
+		}
+
+		t.Log("\ttest:2\tshould add values to structured log lines.")
+		{
+			var stdout, stderr bytes.Buffer
+			rl := NewRegistratorWithLog(fakeRegistrator{}, &stdout, &stderr, true)
+
+			_, err := rl.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+
+			lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			var entry map[string]interface{}
+			assert.Nil(t, json.Unmarshal([]byte(lines[0]), &entry))
+			assert.Equal(t, "req-42", entry["request_id"])
+			assert.Equal(t, "192.0.2.7", entry["client_ip"])
+			assert.Equal(t, "test-agent/1.0", entry["user_agent"])
+		}
+	}
+}
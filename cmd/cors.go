@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Defaults allowed for cross-origin requests.
+var (
+	DefaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE"}
+	DefaultCORSHeaders = []string{"Content-Type", "Authorization"}
+)
+
+// CORS holds cross-origin request allowlists. Empty Origins deny all
+// cross-origin requests, "*" allows any origin.
+type CORS struct {
+	Origins []string
+	Methods []string
+	Headers []string
+}
+
+// WithCORS sets cross-origin headers for allowed origins and answers
+// preflight requests.
+func WithCORS(h http.Handler, c CORS) http.Handler {
+	if len(c.Methods) == 0 {
+		c.Methods = DefaultCORSMethods
+	}
+	if len(c.Headers) == 0 {
+		c.Headers = DefaultCORSHeaders
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !c.allowOrigin(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if !preflight {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if !contains(c.Methods, r.Header.Get("Access-Control-Request-Method")) || !c.allowHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.Methods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.Headers, ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allowOrigin reports whether origin is in the allowlist.
+func (c CORS) allowOrigin(origin string) bool {
+	return contains(c.Origins, "*") || contains(c.Origins, origin)
+}
+
+// allowHeaders reports whether every comma separated header is in the allowlist.
+func (c CORS) allowHeaders(headers string) bool {
+	for _, h := range strings.Split(headers, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+
+		if !containsFold(c.Headers, h) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// contains reports whether s is in list.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsFold reports whether s is in list ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
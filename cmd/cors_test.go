@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	t.Log("with CORS allowing single origin.")
+	{
+		h := WithCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), CORS{Origins: []string{"https://app.domain.zone"}})
+
+		t.Log("\ttest:0\tshould answer preflight of allowed origin.")
+		{
+			req := httptest.NewRequest("OPTIONS", "/register", nil)
+			req.Header.Set("Origin", "https://app.domain.zone")
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			req.Header.Set("Access-Control-Request-Headers", "content-type")
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, "https://app.domain.zone", w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "POST")
+		}
+
+		t.Log("\ttest:1\tshould reject preflight of disallowed method.")
+		{
+			req := httptest.NewRequest("OPTIONS", "/register", nil)
+			req.Header.Set("Origin", "https://app.domain.zone")
+			req.Header.Set("Access-Control-Request-Method", "PATCH")
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		}
+
+		t.Log("\ttest:2\tshould reject preflight of disallowed origin.")
+		{
+			req := httptest.NewRequest("OPTIONS", "/register", nil)
+			req.Header.Set("Origin", "https://evil.zone")
+			req.Header.Set("Access-Control-Request-Method", "POST")
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		}
+
+		t.Log("\ttest:3\tshould not allow request of disallowed origin.")
+		{
+			req := httptest.NewRequest("POST", "/register", nil)
+			req.Header.Set("Origin", "https://evil.zone")
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+
+	t.Log("with default CORS.")
+	{
+		h := WithCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), CORS{})
+
+		t.Log("\ttest:0\tshould deny every origin.")
+		{
+			req := httptest.NewRequest("OPTIONS", "/register", nil)
+			req.Header.Set("Origin", "https://app.domain.zone")
+			req.Header.Set("Access-Control-Request-Method", "POST")
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		}
+	}
+}
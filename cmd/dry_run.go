@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/pkg/errors"
+)
+
+// DryRunner abstraction for validating registrations without creating users.
+type DryRunner interface {
+	DryRun(ctx context.Context, f *entities.Form) error
+}
+
+// DryRun sanitizes and validates the form the same way registrations do,
+// including email uniqueness, without creating the user.
+func (s *Service) DryRun(ctx context.Context, f *entities.Form) error {
+	f.Sanitize()
+	f.Email = entities.NormalizeEmail(f.Email, s.LowercaseEmail)
+
+	if err := s.Validator.Validate(ctx, f); err != nil {
+		return errors.Wrap(err, "validator validate")
+	}
+
+	return nil
+}
+
+// DryRunResponse reports a form passed validation.
+type DryRunResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// DryRunHandler for registration validation requests, it responds with the
+// errors registration would fail with.
+type DryRunHandler struct {
+	DryRunner
+	// MaxBodyBytes limits request body size, DefaultMaxBodyBytes is used when zero.
+	MaxBodyBytes int64
+	// Schema is optional, when set request bodies are validated against it
+	// before decoding.
+	Schema *SchemaValidator
+	// Translator is optional, when set validation messages are translated
+	// to the locale of the Accept-Language header, English is used otherwise.
+	Translator Translator
+	// ConcealExisting leaves taken emails out of the reported errors.
+	ConcealExisting bool
+}
+
+// ServeHTTP implements http.Handler.
+func (h *DryRunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, ok := decodeForm(w, r, h.MaxBodyBytes, h.Schema)
+	if !ok {
+		return
+	}
+
+	err := h.DryRun(r.Context(), f)
+	if h.ConcealExisting {
+		err = concealTaken(err)
+	}
+	if err != nil {
+		if h.Translator != nil {
+			err = translate(err, h.Translator, h.Translator.Locale(r.Header.Get("Accept-Language")))
+		}
+
+		encodeErrorFor(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DryRunResponse{Valid: true})
+}
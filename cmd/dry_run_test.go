@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Log("with initialized server.")
+	{
+		repo := testStorage()
+		srv, err := NewServer(Config{}, ioutil.Discard, repo, prometheus.NewRegistry())
+		assert.Nil(t, err)
+
+		validate := func(body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, jsonRequest("POST", "/register/validate", body))
+			return w
+		}
+
+		t.Log("\ttest:0\tshould accept valid form without creating the user.")
+		{
+			w := validate(`{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"valid": true}`, w.Body.String())
+			assert.Len(t, repo.Users, 1)
+		}
+
+		t.Log("\ttest:1\tshould report errors of invalid form without creating the user.")
+		{
+			w := validate(`{"email": "exists@domain.zone", "password": "qwerty", "password_confirmation": "qwertz"}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, CodeValidationFailed, e.Error.Code)
+			assert.Equal(t, map[string]string{"email": constants.EmailExists, "password": constants.PasswordMismatch}, e.Error.Fields)
+			assert.Len(t, repo.Users, 1)
+		}
+
+		t.Log("\ttest:2\tshould reject malformed body.")
+		{
+			w := validate(`{"email":`)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		}
+	}
+}
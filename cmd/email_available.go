@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/pkg/errors"
+)
+
+// AvailabilityChecker abstraction for checking emails are free to register.
+type AvailabilityChecker interface {
+	EmailAvailable(ctx context.Context, email string) (bool, error)
+}
+
+// EmailAvailable reports whether email is free to register, it only checks
+// uniqueness and leaves other rules to validation.
+func (s *Service) EmailAvailable(ctx context.Context, email string) (bool, error) {
+	err := s.Repository.Unique(ctx, entities.NormalizeEmail(email, s.LowercaseEmail))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, svcerrors.ErrEmailExists):
+		return false, nil
+	default:
+		return false, errors.Wrap(err, "repository unique")
+	}
+}
+
+// AvailabilityResponse reports whether an email is free to register.
+type AvailabilityResponse struct {
+	Available bool `json:"available"`
+}
+
+// EmailAvailableHandler for email availability requests, the email is taken
+// from the email query parameter.
+type EmailAvailableHandler struct {
+	AvailabilityChecker
+}
+
+// ServeHTTP implements http.Handler.
+func (h *EmailAvailableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		writeError(w, http.StatusBadRequest, ErrorBody{Code: CodeInvalidQuery, Message: "email is required"})
+		return
+	}
+
+	ok, err := h.EmailAvailable(r.Context(), email)
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AvailabilityResponse{Available: ok})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/storage"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailAvailable(t *testing.T) {
+	t.Log("with registered user.")
+	{
+		s := &Service{Repository: testStorage()}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould report taken email after normalization.")
+		{
+			ok, err := s.EmailAvailable(ctx, " exists@DOMAIN.zone")
+			assert.Nil(t, err)
+			assert.False(t, ok)
+		}
+
+		t.Log("\ttest:1\tshould report free email.")
+		{
+			ok, err := s.EmailAvailable(ctx, "new@domain.zone")
+			assert.Nil(t, err)
+			assert.True(t, ok)
+		}
+
+		t.Log("\ttest:2\tshould return storage failures.")
+		{
+			s := &Service{Repository: &failingStorage{MemStore: &storage.MemStore{}, err: errors.New("connection reset")}}
+			_, err := s.EmailAvailable(ctx, "new@domain.zone")
+			assert.NotNil(t, err)
+		}
+	}
+
+	t.Log("with rate limited server.")
+	{
+		srv, err := NewServer(Config{RateLimit: 1, RateBurst: 3}, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+
+		get := func(query string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/register/email-available"+query, nil))
+			return w
+		}
+
+		t.Log("\ttest:0\tshould respond with availability of the email.")
+		{
+			w := get("?email=exists@domain.zone")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"available": false}`, w.Body.String())
+
+			w = get("?email=new@domain.zone")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"available": true}`, w.Body.String())
+		}
+
+		t.Log("\ttest:1\tshould require the email.")
+		{
+			w := get("")
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		}
+
+		t.Log("\ttest:2\tshould limit checks of a client.")
+		{
+			w := get("?email=other@domain.zone")
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/i18n"
+	"github.com/pkg/errors"
+)
+
+// Error codes returned in the error envelope.
+const (
+	CodeInvalidJSON          = "invalid_json"
+	CodeInvalidType          = "invalid_type"
+	CodeEmptyBody            = "empty_body"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeUnknownField         = "unknown_field"
+	CodeInvalidBatch         = "invalid_batch"
+	CodeBatchTooLarge        = "batch_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInvalidQuery         = "invalid_query"
+	CodeValidationFailed     = "validation_failed"
+	CodeEmailExists          = "email_exists"
+	CodeUsernameExists       = "username_exists"
+	CodeNotFound             = "not_found"
+	CodeInvalidToken         = "invalid_token"
+	CodeTokenExpired         = "token_expired"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeTimeout              = "timeout"
+	CodeRateLimited          = "rate_limited"
+	CodeUnavailable          = "unavailable"
+	CodeRegistrationDisabled = "registration_disabled"
+	CodeAccountLocked        = "account_locked"
+	CodeRequestInFlight      = "request_in_flight"
+	CodeIdempotencyMismatch  = "idempotency_key_mismatch"
+	CodeInternal             = "internal_error"
+)
+
+// ErrorResponse is a JSON envelope for failed requests.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes a failure.
+type ErrorBody struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	// Errors lists validation errors with their rules when the request
+	// accepts structured errors.
+	Errors FieldErrors `json:"errors,omitempty"`
+}
+
+// writeError writes the error envelope with status.
+func writeError(w http.ResponseWriter, status int, body ErrorBody) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: body})
+}
+
+// encodeError maps err to a status and error code and writes the envelope.
+func encodeError(w http.ResponseWriter, err error) {
+	status, body := errorStatus(err)
+	writeError(w, status, body)
+}
+
+// encodeErrorFor is encodeError listing validation errors with their rules
+// when r accepts structured errors.
+func encodeErrorFor(w http.ResponseWriter, r *http.Request, err error) {
+	status, body := errorStatus(err)
+
+	var fe FieldErrors
+	if acceptsStructured(r) && errors.As(translate(err, defaultTranslator, i18n.DefaultLocale), &fe) {
+		body.Errors = fe
+	}
+
+	writeError(w, status, body)
+}
+
+// errorStatus maps err to a status and error body.
+func errorStatus(err error) (int, ErrorBody) {
+	var v ValidationErrors
+	if errors.As(translate(err, defaultTranslator, i18n.DefaultLocale), &v) {
+		return http.StatusUnprocessableEntity, ErrorBody{Code: CodeValidationFailed, Message: v.Error(), Fields: v}
+	}
+
+	switch {
+	case errors.Is(err, svcerrors.ErrEmailExists):
+		return http.StatusConflict, ErrorBody{Code: CodeEmailExists, Message: svcerrors.ErrEmailExists.Error()}
+	case errors.Is(err, svcerrors.ErrUsernameExists):
+		return http.StatusConflict, ErrorBody{Code: CodeUsernameExists, Message: svcerrors.ErrUsernameExists.Error()}
+	case errors.Is(err, svcerrors.ErrUserNotFound):
+		return http.StatusNotFound, ErrorBody{Code: CodeNotFound, Message: svcerrors.ErrUserNotFound.Error()}
+	case errors.Is(err, svcerrors.ErrInvalidToken):
+		return http.StatusBadRequest, ErrorBody{Code: CodeInvalidToken, Message: svcerrors.ErrInvalidToken.Error()}
+	case errors.Is(err, svcerrors.ErrTokenExpired):
+		return http.StatusBadRequest, ErrorBody{Code: CodeTokenExpired, Message: svcerrors.ErrTokenExpired.Error()}
+	case errors.Is(err, svcerrors.ErrAccountLocked):
+		return http.StatusTooManyRequests, ErrorBody{Code: CodeAccountLocked, Message: svcerrors.ErrAccountLocked.Error()}
+	case errors.Is(err, svcerrors.ErrTimeout):
+		return http.StatusServiceUnavailable, ErrorBody{Code: CodeTimeout, Message: svcerrors.ErrTimeout.Error()}
+	case errors.Is(err, svcerrors.ErrRequestInFlight):
+		return http.StatusConflict, ErrorBody{Code: CodeRequestInFlight, Message: svcerrors.ErrRequestInFlight.Error()}
+	case errors.Is(err, svcerrors.ErrStorageUnavailable):
+		return http.StatusServiceUnavailable, ErrorBody{Code: CodeUnavailable, Message: svcerrors.ErrStorageUnavailable.Error()}
+	default:
+		return http.StatusInternalServerError, ErrorBody{Code: CodeInternal, Message: http.StatusText(http.StatusInternalServerError)}
+	}
+}
+
+// errorJSON returns the envelope encoded as a string.
+func errorJSON(body ErrorBody) string {
+	b, _ := json.Marshal(ErrorResponse{Error: body})
+	return string(b)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		body   string
+	}{
+		{
+			name:   "validation errors",
+			err:    errors.Wrap(ValidationErrors{"email": "email is invalid"}, "validator validate"),
+			status: http.StatusUnprocessableEntity,
+			body:   `{"error":{"code":"validation_failed","message":"you have validation errors","fields":{"email":"email is invalid"}}}`,
+		},
+		{
+			name:   "email exists",
+			err:    errors.Wrap(svcerrors.ErrEmailExists, "repository create"),
+			status: http.StatusConflict,
+			body:   `{"error":{"code":"email_exists","message":"email already exists"}}`,
+		},
+		{
+			name:   "username exists",
+			err:    errors.Wrap(svcerrors.ErrUsernameExists, "repository create"),
+			status: http.StatusConflict,
+			body:   `{"error":{"code":"username_exists","message":"username already exists"}}`,
+		},
+		{
+			name:   "validation errors wrapped with %w",
+			err:    fmt.Errorf("register: %w", errors.Wrap(ValidationErrors{"email": "email is invalid"}, "validator validate")),
+			status: http.StatusUnprocessableEntity,
+			body:   `{"error":{"code":"validation_failed","message":"you have validation errors","fields":{"email":"email is invalid"}}}`,
+		},
+		{
+			name:   "user not found wrapped with %w",
+			err:    fmt.Errorf("find user: %w", svcerrors.ErrUserNotFound),
+			status: http.StatusNotFound,
+			body:   `{"error":{"code":"not_found","message":"user not found"}}`,
+		},
+		{
+			name:   "timeout",
+			err:    svcerrors.ErrTimeout,
+			status: http.StatusServiceUnavailable,
+			body:   `{"error":{"code":"timeout","message":"operation timed out"}}`,
+		},
+		{
+			name:   "storage unavailable",
+			err:    errors.Wrap(fmt.Errorf("repository unique: %w: %w", svcerrors.ErrStorageUnavailable, errors.New("connection reset")), "validator validate"),
+			status: http.StatusServiceUnavailable,
+			body:   `{"error":{"code":"unavailable","message":"storage temporarily unavailable"}}`,
+		},
+		{
+			name:   "account locked",
+			err:    svcerrors.ErrAccountLocked,
+			status: http.StatusTooManyRequests,
+			body:   `{"error":{"code":"account_locked","message":"account temporarily locked"}}`,
+		},
+		{
+			name:   "unknown error",
+			err:    errors.New("boom"),
+			status: http.StatusInternalServerError,
+			body:   `{"error":{"code":"internal_error","message":"Internal Server Error"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			encodeError(w, tt.err)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.body, w.Body.String())
+		})
+	}
+}
+
+func TestErrorChain(t *testing.T) {
+	t.Log("with errors wrapped by pkg/errors and fmt.")
+	{
+		err := errors.Wrap(fmt.Errorf("repository create: %w", svcerrors.ErrEmailExists), "service register")
+
+		t.Log("\ttest:0\tshould match the sentinel through the chain.")
+		{
+			assert.True(t, errors.Is(err, svcerrors.ErrEmailExists))
+			assert.False(t, errors.Is(err, svcerrors.ErrUserNotFound))
+		}
+
+		t.Log("\ttest:1\tshould extract validation errors through the chain.")
+		{
+			var v ValidationErrors
+			wrapped := fmt.Errorf("batch item 0: %w", errors.Wrap(ValidationErrors{"email": "email is invalid"}, "validator validate"))
+			assert.True(t, errors.As(wrapped, &v))
+			assert.Equal(t, "email is invalid", v["email"])
+			assert.False(t, errors.As(err, &v))
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/newtondev/service_object/pkg/constants"
+)
+
+// Rules reported with validation errors, tags of the struct validator are
+// reported as they are.
+const (
+	RuleRequired      = "required"
+	RuleEmail         = "email"
+	RuleDisposable    = "disposable"
+	RuleAllowedDomain = "allowed_domain"
+	RuleLength        = "length"
+	RuleStrength      = "strength"
+	RuleConfirmation  = "confirmation"
+	RuleNotEmail      = "not_email"
+	RuleBreached      = "breached"
+	RuleReused        = "reused"
+	RuleUnique        = "unique"
+	RuleAccepted      = "accepted"
+)
+
+// FieldError is a validation error of a field, message is an i18n key.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// FieldErrors holds validation errors with the rules that failed, at most
+// one per field in the order they were found. As ValidationErrors it is
+// their map view.
+type FieldErrors []FieldError
+
+// Error implements error interface
+func (fe FieldErrors) Error() string {
+	return constants.ValidationMsg
+}
+
+// As sets target to the map view when it is a *ValidationErrors, so callers
+// only interested in messages keep working.
+func (fe FieldErrors) As(target interface{}) bool {
+	v, ok := target.(*ValidationErrors)
+	if ok {
+		*v = fe.Map()
+	}
+
+	return ok
+}
+
+// Map returns messages of fe by field.
+func (fe FieldErrors) Map() ValidationErrors {
+	v := make(ValidationErrors, len(fe))
+	for _, e := range fe {
+		v[e.Field] = e.Message
+	}
+
+	return v
+}
+
+// Has reports whether field has an error.
+func (fe FieldErrors) Has(field string) bool {
+	for _, e := range fe {
+		if e.Field == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Set records the error of field, replacing the previous one.
+func (fe *FieldErrors) Set(field, rule, message string) {
+	for i, e := range *fe {
+		if e.Field == field {
+			(*fe)[i] = FieldError{Field: field, Rule: rule, Message: message}
+			return
+		}
+	}
+
+	*fe = append(*fe, FieldError{Field: field, Rule: rule, Message: message})
+}
+
+// StructuredErrors is the Accept media type parameter asking for validation
+// errors listed with their rules, as in "application/json; errors=structured".
+const StructuredErrors = "structured"
+
+// acceptsStructured reports whether r asks for structured validation errors.
+func acceptsStructured(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(accept)
+		if err == nil && mt == "application/json" && params["errors"] == StructuredErrors {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/newtondev/service_object/pkg/i18n"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+func TestFieldErrors(t *testing.T) {
+	t.Log("with play validator.")
+	{
+		v := &PlayValidator{Validator: validator.New(), Repository: testStorage()}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould report the failed rule with the message of every field.")
+		{
+			f := testForm("exists@domain.zone", "qwerty")
+			f.PasswordConfirmation = ""
+
+			err := v.Validate(ctx, f)
+			assert.Equal(t, FieldErrors{
+				{Field: "password_confirmation", Rule: RuleRequired, Message: constants.Required},
+				{Field: "email", Rule: RuleUnique, Message: constants.EmailExists},
+			}, err)
+		}
+
+		t.Log("\ttest:1\tshould keep the map view.")
+		{
+			var vs ValidationErrors
+			err := errors.Wrap(v.Validate(ctx, testForm("new@domain.zone", "qw")), "validator validate")
+			assert.True(t, errors.As(err, &vs))
+			assert.Equal(t, ValidationErrors{"password": i18n.Message(constants.PasswordLength, DefaultMinPassword, DefaultMaxPassword)}, vs)
+		}
+
+		t.Log("\ttest:2\tshould merge rules in composite validators.")
+		{
+			f := testForm("new@domain.zone", "qwerty")
+			f.PasswordConfirmation = "qwertz"
+
+			c := CompositeValidator{v, staticValidator{err: ValidationErrors{"first_name": "first name is reserved"}}}
+			assert.Equal(t, FieldErrors{
+				{Field: "password", Rule: RuleConfirmation, Message: constants.PasswordMismatch},
+				{Field: "first_name", Message: "first name is reserved"},
+			}, c.Validate(ctx, f))
+		}
+	}
+
+	t.Log("with registration handler.")
+	{
+		h := RegistrationHandler{Registrator: &Service{Validator: &PlayValidator{Validator: validator.New(), Repository: testStorage()}, Repository: testStorage()}}
+		register := func(accept string) ErrorResponse {
+			r := jsonRequest("POST", "/register", `{"email": "exists@domain.zone", "password": "qwerty", "password_confirmation": "qwertz"}`)
+			r.Header.Set("Accept", accept)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			return e
+		}
+		fields := map[string]string{"email": constants.EmailExists, "password": constants.PasswordMismatch}
+
+		t.Log("\ttest:0\tshould encode only the field messages by default.")
+		{
+			e := register("application/json")
+			assert.Equal(t, fields, e.Error.Fields)
+			assert.Nil(t, e.Error.Errors)
+		}
+
+		t.Log("\ttest:1\tshould list rules when structured errors are accepted.")
+		{
+			e := register("text/html, application/json; errors=structured")
+			assert.Equal(t, fields, e.Error.Fields)
+			assert.Equal(t, FieldErrors{
+				{Field: "password", Rule: RuleConfirmation, Message: constants.PasswordMismatch},
+				{Field: "email", Rule: RuleUnique, Message: constants.EmailExists},
+			}, e.Error.Errors)
+		}
+	}
+}
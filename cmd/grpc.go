@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"sort"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/i18n"
+	"github.com/newtondev/service_object/pkg/registrationpb"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// RegistrationServer serves registrations over gRPC.
+type RegistrationServer struct {
+	registrationpb.UnimplementedRegistrationServer
+	Registrator Registrator
+	// Switch is optional, registrations are rejected while it is disabled.
+	Switch *Switch
+	// ConcealExisting keeps taken emails from being revealed, registrations
+	// of new and taken emails are both answered with an empty user.
+	ConcealExisting bool
+}
+
+// NewGRPCServer prepares gRPC server registering users with r while sw is
+// enabled, concealing taken emails when conceal is set. Options like
+// transport credentials are passed to the server.
+func NewGRPCServer(r Registrator, sw *Switch, conceal bool, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	registrationpb.RegisterRegistrationServer(s, &RegistrationServer{Registrator: r, Switch: sw, ConcealExisting: conceal})
+
+	return s
+}
+
+// grpcOptions returns options of gRPC server of cfg, it is served over TLS
+// when certificate and key are configured as it carries passwords too.
+func grpcOptions(cfg Config) ([]grpc.ServerOption, error) {
+	if cfg.TLSCert == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "load tls certificate")
+	}
+
+	return []grpc.ServerOption{
+		grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: cfg.TLSMinVersion})),
+	}, nil
+}
+
+// Register implements registrationpb.RegistrationServer.
+func (s *RegistrationServer) Register(ctx context.Context, req *registrationpb.Form) (*registrationpb.User, error) {
+	if !s.Switch.Enabled() {
+		return nil, status.Error(codes.Unavailable, svcerrors.ErrRegistrationDisabled.Error())
+	}
+
+	u, err := s.Registrator.Register(ctx, &entities.Form{
+		Email:                req.GetEmail(),
+		Password:             req.GetPassword(),
+		PasswordConfirmation: req.GetPasswordConfirmation(),
+		AcceptedTerms:        req.GetAcceptedTerms(),
+		Username:             req.GetUsername(),
+		FirstName:            req.GetFirstName(),
+		LastName:             req.GetLastName(),
+		DisplayName:          req.GetDisplayName(),
+	})
+	if s.ConcealExisting {
+		if err = concealTaken(err); err == nil {
+			return &registrationpb.User{}, nil
+		}
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	safe := u.Safe()
+	return &registrationpb.User{
+		Id:       safe.ID,
+		Email:    safe.Email,
+		Verified: safe.Verified,
+	}, nil
+}
+
+// grpcError maps service errors to gRPC statuses, validation errors carry
+// the invalid fields as BadRequest details.
+func grpcError(err error) error {
+	var e ValidationErrors
+	if errors.As(translate(err, defaultTranslator, i18n.DefaultLocale), &e) {
+		code := codes.InvalidArgument
+		if e["email"] == constants.EmailExists {
+			code = codes.AlreadyExists
+		}
+
+		br := &errdetails.BadRequest{}
+		for _, f := range sortedKeys(e) {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: f, Description: e[f]})
+		}
+
+		st, derr := status.New(code, e.Error()).WithDetails(br)
+		if derr != nil {
+			return status.Error(code, e.Error())
+		}
+		return st.Err()
+	}
+
+	switch {
+	case errors.Is(err, svcerrors.ErrEmailExists):
+		return status.Error(codes.AlreadyExists, constants.EmailExists)
+	case errors.Is(err, svcerrors.ErrUsernameExists):
+		return status.Error(codes.AlreadyExists, constants.UsernameExists)
+	case errors.Is(err, svcerrors.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "request timed out")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request cancelled")
+	case errors.Is(err, svcerrors.ErrStorageUnavailable):
+		return status.Error(codes.Unavailable, svcerrors.ErrStorageUnavailable.Error())
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+// sortedKeys returns keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
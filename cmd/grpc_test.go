@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/newtondev/service_object/pkg/registrationpb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGRPCRegistration(t *testing.T) {
+	t.Log("with grpc server on in-memory listener.")
+	{
+		c, stop := grpcClient(t, Config{})
+		defer stop()
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould register user with valid form.")
+		{
+			u, err := c.Register(ctx, &registrationpb.Form{Email: "new@domain.zone", Password: "qwerty", PasswordConfirmation: "qwerty"})
+			assert.Nil(t, err)
+			assert.Equal(t, "2", u.GetId())
+			assert.Equal(t, "new@domain.zone", u.GetEmail())
+		}
+
+		t.Log("\ttest:1\tshould return invalid argument with field details.")
+		{
+			_, err := c.Register(ctx, &registrationpb.Form{Email: "other@domain.zone", Password: "qwerty", PasswordConfirmation: "other"})
+			st := status.Convert(err)
+			assert.Equal(t, codes.InvalidArgument, st.Code())
+
+			assert.Len(t, st.Details(), 1)
+			br, ok := st.Details()[0].(*errdetails.BadRequest)
+			assert.True(t, ok)
+			assert.Equal(t, "password", br.GetFieldViolations()[0].GetField())
+			assert.Equal(t, constants.PasswordMismatch, br.GetFieldViolations()[0].GetDescription())
+		}
+
+		t.Log("\ttest:2\tshould return already exists for registered email.")
+		{
+			_, err := c.Register(ctx, &registrationpb.Form{Email: "exists@domain.zone", Password: "qwerty", PasswordConfirmation: "qwerty"})
+			assert.Equal(t, codes.AlreadyExists, status.Code(err))
+		}
+	}
+}
+
+func TestGRPCTerms(t *testing.T) {
+	t.Log("with grpc server requiring terms of service.")
+	{
+		c, stop := grpcClient(t, Config{RequireTerms: true})
+		defer stop()
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould reject form without accepted terms.")
+		{
+			_, err := c.Register(ctx, &registrationpb.Form{Email: "new@domain.zone", Password: "qwerty", PasswordConfirmation: "qwerty"})
+			st := status.Convert(err)
+			assert.Equal(t, codes.InvalidArgument, st.Code())
+
+			assert.Len(t, st.Details(), 1)
+			br, ok := st.Details()[0].(*errdetails.BadRequest)
+			assert.True(t, ok)
+			assert.Equal(t, "terms", br.GetFieldViolations()[0].GetField())
+		}
+
+		t.Log("\ttest:1\tshould register user who accepted the terms.")
+		{
+			u, err := c.Register(ctx, &registrationpb.Form{Email: "new@domain.zone", Password: "qwerty", PasswordConfirmation: "qwerty", AcceptedTerms: true})
+			assert.Nil(t, err)
+			assert.Equal(t, "new@domain.zone", u.GetEmail())
+		}
+	}
+}
+
+func TestGRPCNames(t *testing.T) {
+	t.Log("with grpc server requiring names.")
+	{
+		c, stop := grpcClient(t, Config{RequireNames: true})
+		defer stop()
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould reject form without names.")
+		{
+			_, err := c.Register(ctx, &registrationpb.Form{Email: "new@domain.zone", Password: "qwerty", PasswordConfirmation: "qwerty"})
+			st := status.Convert(err)
+			assert.Equal(t, codes.InvalidArgument, st.Code())
+
+			br, ok := st.Details()[0].(*errdetails.BadRequest)
+			assert.True(t, ok)
+			assert.Len(t, br.GetFieldViolations(), 2)
+			assert.Equal(t, "first_name", br.GetFieldViolations()[0].GetField())
+			assert.Equal(t, "last_name", br.GetFieldViolations()[1].GetField())
+		}
+
+		t.Log("\ttest:1\tshould register user with names and username.")
+		{
+			u, err := c.Register(ctx, &registrationpb.Form{Email: "new@domain.zone", Password: "qwerty", PasswordConfirmation: "qwerty", Username: "newton", FirstName: "Isaac", LastName: "Newton", DisplayName: "Sir Isaac"})
+			assert.Nil(t, err)
+			assert.Equal(t, "new@domain.zone", u.GetEmail())
+		}
+	}
+}
+
+func TestGRPCConceal(t *testing.T) {
+	ctx := context.Background()
+	taken := &registrationpb.Form{Email: "exists@domain.zone", Password: "qwerty", PasswordConfirmation: "qwerty"}
+
+	t.Log("with grpc server reporting taken emails.")
+	{
+		c, stop := grpcClient(t, Config{})
+		defer stop()
+
+		t.Log("\ttest:0\tshould return already exists for registered email.")
+		{
+			_, err := c.Register(ctx, taken)
+			assert.Equal(t, codes.AlreadyExists, status.Code(err))
+		}
+	}
+
+	t.Log("with grpc server concealing taken emails.")
+	{
+		c, stop := grpcClient(t, Config{ConcealExisting: true})
+		defer stop()
+
+		t.Log("\ttest:0\tshould answer taken and new emails with the same empty user.")
+		{
+			u, err := c.Register(ctx, taken)
+			assert.Nil(t, err)
+			assert.Empty(t, u.GetId())
+			assert.Empty(t, u.GetEmail())
+
+			n, err := c.Register(ctx, &registrationpb.Form{Email: "new@domain.zone", Password: "qwerty", PasswordConfirmation: "qwerty"})
+			assert.Nil(t, err)
+			assert.True(t, proto.Equal(u, n))
+		}
+
+		t.Log("\ttest:1\tshould keep reporting other invalid fields.")
+		{
+			_, err := c.Register(ctx, &registrationpb.Form{Email: "exists@domain.zone", Password: "qwerty", PasswordConfirmation: "other"})
+			st := status.Convert(err)
+			assert.Equal(t, codes.InvalidArgument, st.Code())
+
+			br, ok := st.Details()[0].(*errdetails.BadRequest)
+			assert.True(t, ok)
+			assert.Len(t, br.GetFieldViolations(), 1)
+			assert.Equal(t, "password", br.GetFieldViolations()[0].GetField())
+		}
+	}
+}
+
+// grpcClient serves gRPC server of cfg on in-memory listener, returning a
+// client of it and a func stopping both.
+func grpcClient(t *testing.T, cfg Config) (registrationpb.RegistrationClient, func()) {
+	_, g, err := NewServers(cfg, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+	assert.Nil(t, err)
+
+	l := bufconn.Listen(1 << 20)
+	go g.Serve(l)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+
+	return registrationpb.NewRegistrationClient(conn), func() {
+		conn.Close()
+		g.Stop()
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// DefaultPingTimeout limits dependency checks when none is configured.
+const DefaultPingTimeout = 2 * time.Second
+
+// Pinger reports whether a dependency is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// WriteChecker reports whether a dependency accepts writes.
+type WriteChecker interface {
+	CheckWrite(ctx context.Context) error
+}
+
+// ping runs check giving up after timeout, so a hanging dependency is
+// reported as unreachable.
+func ping(ctx context.Context, check func(context.Context) error, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultPingTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return check(ctx)
+}
+
+// HealthHandler reports the process is alive.
+type HealthHandler struct{}
+
+// ServeHTTP implements http.Handler.
+func (HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Statuses of readiness checks.
+const (
+	CheckOK     = "ok"
+	CheckFailed = "failed"
+)
+
+// ReadyResponse is a readiness report.
+type ReadyResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult is a result of a single readiness check. Errors are logged
+// only, as they may name hosts and users of dependencies.
+type CheckResult struct {
+	Status string `json:"status"`
+}
+
+// add records result of the check named name failing the report on err.
+func (r *ReadyResponse) add(name string, err error) {
+	if r.Checks == nil {
+		r.Checks = map[string]CheckResult{}
+	}
+
+	if err != nil {
+		r.Status = CheckFailed
+		r.Checks[name] = CheckResult{Status: CheckFailed}
+		return
+	}
+	r.Checks[name] = CheckResult{Status: CheckOK}
+}
+
+// ReadyHandler reports whether the service can serve requests with status
+// of every check in the body.
+type ReadyHandler struct {
+	// Pinger is optional, without it the service is always ready.
+	Pinger Pinger
+	// Writer is optional, it makes a write to check the dependency is not
+	// read-only. The write mutates the dependency, so it is opt-in.
+	Writer WriteChecker
+	// Timeout limits every check, default is used when zero.
+	Timeout time.Duration
+	// Logger is optional, when set errors of failed checks are logged.
+	Logger Logger
+}
+
+// ServeHTTP implements http.Handler.
+func (h *ReadyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp := ReadyResponse{Status: CheckOK}
+	if h.Pinger != nil {
+		resp.add("ping", h.check(r.Context(), "ping", h.Pinger.Ping))
+	}
+	if h.Writer != nil {
+		resp.add("write", h.check(r.Context(), "write", h.Writer.CheckWrite))
+	}
+
+	status := http.StatusOK
+	if resp.Status != CheckOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// check runs check named name within the timeout, logging its failure.
+func (h *ReadyHandler) check(ctx context.Context, name string, check func(context.Context) error) error {
+	err := ping(ctx, check, h.Timeout)
+	if err != nil && h.Logger != nil {
+		h.Logger.Error("ReadyHandler: check failed", append([]interface{}{"check", name, "error", err.Error()}, LogContext.Fields(ctx)...)...)
+	}
+
+	return err
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	t.Log("with initialized server.")
+	{
+		srv, err := NewServer(Config{}, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		t.Log("\ttest:0\tshould report the process is alive.")
+		{
+			resp, err := http.Get(fmt.Sprintf("%s/healthz", s.URL))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+
+		t.Log("\ttest:1\tshould report ready with reachable repository.")
+		{
+			resp, err := http.Get(fmt.Sprintf("%s/readyz", s.URL))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	}
+
+	t.Log("with unreachable repository.")
+	{
+		repo := &unreachableStorage{MemStore: testStorage()}
+		srv, err := NewServer(Config{}, ioutil.Discard, repo, prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		t.Log("\ttest:0\tshould report not ready.")
+		{
+			resp, err := http.Get(fmt.Sprintf("%s/readyz", s.URL))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		}
+	}
+}
+
+func TestReadyTimeout(t *testing.T) {
+	t.Log("with repository hanging on ping.")
+	{
+		h := ReadyHandler{Pinger: hangingPinger{}, Timeout: 20 * time.Millisecond}
+
+		t.Log("\ttest:0\tshould report not ready after the timeout.")
+		{
+			start := time.Now()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.True(t, time.Since(start) < time.Second)
+		}
+	}
+}
+
+func TestReadyErrors(t *testing.T) {
+	t.Log("with repository failing pings with a driver error.")
+	{
+		l := &fakeLogger{}
+		h := ReadyHandler{Pinger: &unreachableStorage{MemStore: testStorage()}, Logger: l}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+		t.Log("\ttest:0\tshould report only the check and its status.")
+		{
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.JSONEq(t, `{"status": "failed", "checks": {"ping": {"status": "failed"}}}`, w.Body.String())
+		}
+
+		t.Log("\ttest:1\tshould log the error.")
+		{
+			assert.Len(t, l.errors, 1)
+			assert.Equal(t, "ping", l.errors[0]["check"])
+			assert.Equal(t, "connection refused", l.errors[0]["error"])
+		}
+	}
+}
+
+func TestReadyWriteCheck(t *testing.T) {
+	t.Log("with repository passing pings but failing writes.")
+	{
+		repo := &readOnlyStorage{MemStore: testStorage()}
+
+		t.Log("\ttest:0\tshould report not ready with status of every check.")
+		{
+			srv, err := NewServer(Config{ReadyWriteCheck: true}, ioutil.Discard, repo, prometheus.NewRegistry())
+			assert.Nil(t, err)
+			s := httptest.NewServer(srv.Handler)
+			defer s.Close()
+
+			resp, err := http.Get(fmt.Sprintf("%s/readyz", s.URL))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			assert.JSONEq(t, `{"status": "failed", "checks": {"ping": {"status": "ok"}, "write": {"status": "failed"}}}`, string(body))
+		}
+
+		t.Log("\ttest:1\tshould not write without the flag.")
+		{
+			srv, err := NewServer(Config{}, ioutil.Discard, repo, prometheus.NewRegistry())
+			assert.Nil(t, err)
+			s := httptest.NewServer(srv.Handler)
+			defer s.Close()
+
+			resp, err := http.Get(fmt.Sprintf("%s/readyz", s.URL))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			assert.JSONEq(t, `{"status": "ok", "checks": {"ping": {"status": "ok"}}}`, string(body))
+		}
+	}
+
+	t.Log("with writable repository.")
+	{
+		srv, err := NewServer(Config{ReadyWriteCheck: true}, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		t.Log("\ttest:0\tshould report ready after the write.")
+		{
+			resp, err := http.Get(fmt.Sprintf("%s/readyz", s.URL))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			assert.JSONEq(t, `{"status": "ok", "checks": {"ping": {"status": "ok"}, "write": {"status": "ok"}}}`, string(body))
+		}
+	}
+}
+
+// hangingPinger blocks until the ping is cancelled.
+type hangingPinger struct{}
+
+func (hangingPinger) Ping(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// unreachableStorage fails pings.
+type unreachableStorage struct {
+	*storage.MemStore
+}
+
+func (unreachableStorage) Ping(context.Context) error {
+	return errors.New("connection refused")
+}
+
+// readOnlyStorage passes pings and fails writes.
+type readOnlyStorage struct {
+	*storage.MemStore
+}
+
+func (readOnlyStorage) CheckWrite(context.Context) error {
+	return errors.New("read-only transaction")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// unmatchedRoute labels requests matching no route, so unknown paths do not
+// grow the number of series.
+const unmatchedRoute = "unmatched"
+
+// WithHTTPMetrics instruments requests served by mux with request count,
+// duration and in-flight metrics of meters from mp, labeled by the matched
+// route pattern and the response status.
+func WithHTTPMetrics(mux *http.ServeMux, mp metric.MeterProvider) (http.Handler, error) {
+	meter := mp.Meter(tracerName)
+	requests, err := meter.Int64Counter("http.server.request.count",
+		metric.WithDescription("Number of handled HTTP requests."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of handling HTTP requests."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	inFlight, err := meter.Int64UpDownCounter("http.server.active_requests",
+		metric.WithDescription("Number of HTTP requests being handled."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := unmatchedRoute
+		if _, pattern := mux.Handler(r); pattern != "" {
+			route = pattern
+		}
+		routeAttr := attribute.String("http.route", route)
+
+		inFlight.Add(r.Context(), 1, metric.WithAttributes(routeAttr))
+		defer inFlight.Add(r.Context(), -1, metric.WithAttributes(routeAttr))
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		mux.ServeHTTP(sw, r)
+
+		attrs := metric.WithAttributes(routeAttr, attribute.Int("http.response.status_code", sw.status))
+		requests.Add(r.Context(), 1, attrs)
+		duration.Record(r.Context(), time.Since(start).Seconds(), attrs)
+	}), nil
+}
+
+// statusWriter passes the response through keeping its status.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	t.Log("with instrumented mux.")
+	{
+		reader := sdkmetric.NewManualReader()
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+		var inFlight int64
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			inFlight = sumOf(t, collect(t, reader), "http.server.active_requests")
+			w.WriteHeader(http.StatusNotFound)
+		})
+		mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+
+		h, err := WithHTTPMetrics(mux, mp)
+		assert.Nil(t, err)
+
+		t.Log("\ttest:0\tshould count a request with its route and status.")
+		{
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+			rm := collect(t, reader)
+			counts := points(t, rm, "http.server.request.count")
+			assert.Len(t, counts, 1)
+			assert.Equal(t, int64(1), counts[0].Value)
+			assert.Equal(t, attribute.NewSet(
+				attribute.String("http.route", "GET /users/{id}"),
+				attribute.Int("http.response.status_code", http.StatusNotFound),
+			), counts[0].Attributes)
+		}
+
+		t.Log("\ttest:1\tshould track the request in flight while it is handled.")
+		{
+			assert.Equal(t, int64(1), inFlight)
+			assert.Equal(t, int64(0), sumOf(t, collect(t, reader), "http.server.active_requests"))
+		}
+
+		t.Log("\ttest:2\tshould record the duration.")
+		{
+			rm := collect(t, reader)
+			for _, m := range rm.ScopeMetrics[0].Metrics {
+				if m.Name == "http.server.request.duration" {
+					hist := m.Data.(metricdata.Histogram[float64])
+					assert.Len(t, hist.DataPoints, 1)
+					assert.Equal(t, uint64(1), hist.DataPoints[0].Count)
+				}
+			}
+		}
+
+		t.Log("\ttest:3\tshould default status to ok when handler only writes.")
+		{
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+			for _, p := range points(t, collect(t, reader), "http.server.request.count") {
+				if route, _ := p.Attributes.Value("http.route"); route.AsString() == "GET /healthz" {
+					status, _ := p.Attributes.Value("http.response.status_code")
+					assert.Equal(t, int64(http.StatusOK), status.AsInt64())
+				}
+			}
+		}
+
+		t.Log("\ttest:4\tshould label unknown paths as unmatched.")
+		{
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unknown/path", nil))
+
+			var found bool
+			for _, p := range points(t, collect(t, reader), "http.server.request.count") {
+				route, _ := p.Attributes.Value("http.route")
+				found = found || route.AsString() == unmatchedRoute
+			}
+			assert.True(t, found)
+		}
+	}
+}
+
+// collect reads metrics recorded so far.
+func collect(t *testing.T, reader sdkmetric.Reader) metricdata.ResourceMetrics {
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &rm))
+	return rm
+}
+
+// points returns data points of the int64 sum named name.
+func points(t *testing.T, rm metricdata.ResourceMetrics, name string) []metricdata.DataPoint[int64] {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data.(metricdata.Sum[int64]).DataPoints
+			}
+		}
+	}
+
+	t.Fatalf("metric %s not recorded", name)
+	return nil
+}
+
+// sumOf adds up data points of the int64 sum named name.
+func sumOf(t *testing.T, rm metricdata.ResourceMetrics, name string) int64 {
+	var sum int64
+	for _, p := range points(t, rm, name) {
+		sum += p.Value
+	}
+
+	return sum
+}
@@ -1,82 +1,462 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
-	"flag"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/pkg/errors"
-	"github.com/newtondev/service_object/pkg/storage"
-	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/audit"
 	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/newtondev/service_object/pkg/emailcache"
+	"github.com/newtondev/service_object/pkg/entities"
 	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/hasher"
+	"github.com/newtondev/service_object/pkg/i18n"
+	"github.com/newtondev/service_object/pkg/idempotency"
+	"github.com/newtondev/service_object/pkg/lockout"
+	"github.com/newtondev/service_object/pkg/mailer"
+	"github.com/newtondev/service_object/pkg/ratelimit"
+	"github.com/newtondev/service_object/pkg/storage"
+	"github.com/newtondev/service_object/pkg/strength"
+	"github.com/newtondev/service_object/pkg/token"
+	"github.com/newtondev/service_object/pkg/transport"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
 	"gopkg.in/go-playground/validator.v9"
 )
 
 func main() {
-	var (
-		addr  = flag.String("addr", ":8080", "address of the http server")
-		debug = flag.Bool("debug", false, "enable debug")
-	)
+	load := LoadConfig
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		load = func() (Config, error) { return LoadConfigFile(path) }
+	}
+
+	cfg, err := load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
 
 	stdout := ioutil.Discard
-	if *debug {
+	if cfg.Debug {
 		stdout = os.Stdout
 	}
 
-	r := storage.MemStore{}
-	s := NewServer(*addr, stdout, &r)
-	if err := s.ListenAndServe(); err != nil {
+	r, err := newRepository(cfg)
+	if err != nil {
+		log.Fatalf("prepare repository: %v", err)
+	}
+
+	if p, ok := r.(Pinger); ok {
+		if err := ping(context.Background(), p.Ping, DefaultPingTimeout); err != nil {
+			log.Fatalf("ping repository: %v", err)
+		}
+	}
+
+	s, g, err := NewServers(cfg, stdout, r, prometheus.NewRegistry())
+	if err != nil {
+		log.Fatalf("prepare server: %v", err)
+	}
+
+	if cfg.GRPCAddr != "" {
+		gl, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			log.Fatalf("listen grpc: %v", err)
+		}
+
+		go func() {
+			if err := g.Serve(gl); err != nil {
+				log.Fatalf("start grpc server: %v", err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+
+		log.Println("shutting down server")
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		g.GracefulStop()
+		if err := s.Shutdown(ctx); err != nil {
+			log.Printf("shutdown server: %v", err)
+			return
+		}
+		log.Println("server stopped")
+	}()
+
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+
+	if err := serve(s, l, cfg); err != http.ErrServerClosed {
 		log.Fatalf("start server: %v", err)
 	}
+	<-done
+}
+
+// serve accepts connections on l, over TLS when certificate and key are
+// configured.
+func serve(s *http.Server, l net.Listener, cfg Config) error {
+	if cfg.TLSCert != "" {
+		return s.ServeTLS(l, cfg.TLSCert, cfg.TLSKey)
+	}
+
+	return s.Serve(l)
+}
+
+// tracerName identifies spans started by the service.
+const tracerName = "github.com/newtondev/service_object"
+
+// newRepository prepares postgres storage when DSN is configured and memory
+// storage otherwise, known emails are cached in redis when it is configured.
+func newRepository(cfg Config) (Repository, error) {
+	r, err := newStorage(cfg)
+	if err != nil || cfg.RedisAddr == "" {
+		return r, err
+	}
+
+	cache := emailcache.NewRedis(redis.NewClient(&redis.Options{Addr: cfg.RedisAddr}))
+	return NewRepositoryWithCache(r, cache, cfg.UniqueCacheTTL), nil
+}
+
+// newStorage prepares postgres storage when DSN is configured and memory
+// storage otherwise.
+func newStorage(cfg Config) (Repository, error) {
+	h := newHasher(cfg)
+	if cfg.DBDSN == "" {
+		return &storage.MemStore{Hasher: h}, nil
+	}
+
+	db, err := sql.Open("postgres", cfg.DBDSN)
+	if err != nil {
+		return nil, errors.Wrap(err, "open database")
+	}
+
+	s := storage.NewPgStore(db)
+	s.Hasher = h
+	if err := s.Migrate(context.Background()); err != nil {
+		return nil, errors.Wrap(err, "migrate database")
+	}
+
+	return s, nil
+}
+
+// newHasher prepares bcrypt hasher, peppered when a pepper is configured.
+func newHasher(cfg Config) hasher.PasswordHasher {
+	h := hasher.NewBcrypt(cfg.BcryptCost)
+	if len(cfg.Pepper) == 0 {
+		return h
+	}
+
+	p := hasher.NewPeppered(h, cfg.PepperVersion, cfg.Pepper)
+	for v, pepper := range cfg.OldPeppers {
+		p.Peppers[v] = pepper
+	}
+
+	return p
+}
+
+// NewServer prepares http server. Metrics are registered in reg and served at /metrics.
+func NewServer(cfg Config, stdout io.Writer, r Repository, reg *prometheus.Registry) (*http.Server, error) {
+	s, _, err := NewServers(cfg, stdout, r, reg)
+	return s, err
 }
 
-// NewServer prepares http server.
-func NewServer(addr string, stdout io.Writer, r Repository) *http.Server {
+// NewServers prepares http and gRPC servers sharing the service and its
+// instrumented Registrator. Metrics are registered in reg and served at /metrics.
+func NewServers(cfg Config, stdout io.Writer, r Repository, reg *prometheus.Registry) (*http.Server, *grpc.Server, error) {
+	opts, err := grpcOptions(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	mux := http.NewServeMux()
+	logger := NewStdLogger(stdout, os.Stderr)
+
+	var v Validator = &PlayValidator{
+		Validator:      validator.New(),
+		Repository:     r,
+		BlockedDomains: cfg.BlockedDomains,
+		AllowedDomains: cfg.AllowedDomains,
+		MinPassword:    cfg.MinPassword,
+		MaxPassword:    cfg.MaxPassword,
+		MinEntropy:     cfg.MinEntropy,
+		RequireNames:   cfg.RequireNames,
+		Logger:         logger,
+	}
+	if cfg.RequireTerms {
+		v = CompositeValidator{v, TermsValidator{}}
+	}
+
+	srv, err := NewService(v, r, newHasher(cfg))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "new service")
+	}
+	// stores without a unique constraint rely on the lock against duplicates.
+	srv.Repository = NewRepositoryWithLock(r)
+	srv.Verifications = token.NewOneTime(token.RandomGenerator{}, token.DefaultTTL)
+	srv.Resets = token.NewOneTime(token.RandomGenerator{}, DefaultResetTTL)
+	srv.ConcealExisting = cfg.ConcealExisting
+	if cfg.LockoutThreshold > 0 {
+		srv.Attempts = lockout.NewMemory(cfg.LockoutThreshold, cfg.LockoutCooldown)
+	}
+	if h, ok := r.(PasswordHistory); ok && cfg.PasswordHistory > 0 {
+		srv.History, srv.HistoryDepth = h, cfg.PasswordHistory
+	}
+
+	// dropped database connections are retried, registrations stored before
+	// the connection dropped are looked up.
+	retry := NewRegistratorWithRetry(NewRegistratorWithSanitizing(srv), 3, 50*time.Millisecond, driver.ErrBadConn)
+	retry.Finder, retry.Hasher = r, srv.Hasher
+	var base Registrator = retry
+	if cfg.RequestTimeout > 0 {
+		base = NewRegistratorWithTimeout(base, cfg.RequestTimeout)
+	}
+	rm, err := NewRegistratorWithMetrics(base, reg)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "registrator with metrics")
+	}
+	var m Mailer = mailer.Noop{}
+	if cfg.SMTPAddr != "" {
+		s := mailer.NewSMTP(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+		s.Attempts, s.Backoff = cfg.SMTPAttempts, cfg.SMTPBackoff
+		m = s
+	}
+	welcome := NewRegistratorWithWelcome(rm, m, logger, DefaultWelcomeWorkers, DefaultWelcomeQueue)
+	welcome.NotifyExisting = cfg.NotifyExisting
+	welcome.VerifyURL = strings.TrimSuffix(cfg.PublicURL, "/") + "/verify"
+	srv.VerificationNotifier = welcome
+	srv.ResetNotifier = welcome
+	registrator := NewRegistratorWithLog(NewRegistratorWithTracing(NewRegistratorWithAudit(welcome, audit.NewMemory()), otel.Tracer(tracerName)), stdout, os.Stderr, false)
 
-	srv := &Service{
-		Validator: &PlayValidator{
-			Validator:  validator.New(),
-			Repository: r,
-		},
-		Repository: r,
+	schema, err := NewSchemaValidator(RegistrationSchema)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "registration schema")
 	}
 
+	translator := i18n.NewTranslator()
 	h := RegistrationHandler{
-		Registrator: NewRegistratorWithLog(srv, stdout, os.Stderr),
+		Registrator:     registrator,
+		MaxBodyBytes:    cfg.MaxBodyBytes,
+		Schema:          schema,
+		Translator:      translator,
+		ConcealExisting: cfg.ConcealExisting,
+	}
+	// without a secret no token can be verified, so authenticated routes stay closed.
+	var parser TokenParser
+	if len(cfg.JWTSecret) > 0 {
+		jwt := token.NewJWT(cfg.JWTSecret, token.DefaultTTL)
+		h.Tokenizer = jwt
+		parser = jwt
+	}
+
+	var register http.Handler = WithTimeout(&h, cfg.RequestTimeout)
+	if cfg.IdempotencyTTL > 0 {
+		register = WithIdempotency(register, idempotency.NewMemory(cfg.IdempotencyTTL), cfg.MaxBodyBytes)
+	}
+	if cfg.RateLimit > 0 {
+		register = WithRateLimit(register, ratelimit.NewMemory(cfg.RateLimit, cfg.RateBurst))
+	}
+
+	var batch http.Handler = WithTimeout(&BatchRegistrationHandler{
+		Registrator:     registrator,
+		MaxBodyBytes:    cfg.MaxBodyBytes,
+		MaxBatch:        cfg.MaxBatch,
+		Schema:          schema,
+		Translator:      translator,
+		ConcealExisting: cfg.ConcealExisting,
+	}, cfg.RequestTimeout)
+	if cfg.RateLimit > 0 {
+		batch = WithRateLimit(batch, ratelimit.NewMemory(cfg.RateLimit, cfg.RateBurst))
+	}
+
+	// dry runs reveal taken emails, so they are limited like registrations.
+	var dryRun http.Handler = WithTimeout(&DryRunHandler{
+		DryRunner:       srv,
+		MaxBodyBytes:    cfg.MaxBodyBytes,
+		Schema:          schema,
+		Translator:      translator,
+		ConcealExisting: cfg.ConcealExisting,
+	}, cfg.RequestTimeout)
+	if cfg.RateLimit > 0 {
+		dryRun = WithRateLimit(dryRun, ratelimit.NewMemory(cfg.RateLimit, cfg.RateBurst))
+	}
+
+	// availability answers whether an email is registered, so it is limited
+	// like registrations to keep it from enumerating users.
+	var available http.Handler = WithTimeout(&EmailAvailableHandler{AvailabilityChecker: srv}, cfg.RequestTimeout)
+	if cfg.RateLimit > 0 {
+		available = WithRateLimit(available, ratelimit.NewMemory(cfg.RateLimit, cfg.RateBurst))
+	}
+
+	// reset requests email users and confirmations guess tokens, so both are
+	// limited like registrations.
+	var reset http.Handler = &RequestResetHandler{PasswordResetter: srv, MaxBodyBytes: cfg.MaxBodyBytes}
+	var confirmReset http.Handler = &ConfirmResetHandler{PasswordResetter: srv, MaxBodyBytes: cfg.MaxBodyBytes}
+	if cfg.RateLimit > 0 {
+		reset = WithRateLimit(reset, ratelimit.NewMemory(cfg.RateLimit, cfg.RateBurst))
+		confirmReset = WithRateLimit(confirmReset, ratelimit.NewMemory(cfg.RateLimit, cfg.RateBurst))
+	}
+
+	// registrations are paused before rate limits and idempotency keys
+	// are spent.
+	sw := NewSwitch(!cfg.RegistrationDisabled)
+	mux.Handle("/register", WithSwitch(register, sw))
+	mux.Handle("POST /register/batch", WithSwitch(batch, sw))
+	mux.Handle("POST /register/validate", dryRun)
+	if !cfg.ConcealExisting {
+		mux.Handle("GET /register/email-available", available)
+	}
+	mux.Handle("GET /admin/registration", WithBearer(WithRole(&SwitchHandler{Switch: sw}, parser, entities.RoleAdmin)))
+	mux.Handle("PUT /admin/registration", WithBearer(WithRole(&SwitchHandler{Switch: sw}, parser, entities.RoleAdmin)))
+	mux.Handle("GET /users", WithBearer(WithRole(&ListUsersHandler{Lister: srv}, parser, entities.RoleAdmin)))
+	mux.Handle("GET /users/me", WithBearer(WithAuthentication(&MeHandler{Finder: srv}, parser)))
+	// users reach only their own account, admins any.
+	owner := func(h http.Handler) http.Handler {
+		return WithBearer(WithAuthentication(WithOwner(h, entities.RoleAdmin), parser))
+	}
+	mux.Handle("GET /users/{id}", owner(&UserHandler{Finder: srv}))
+	mux.Handle("PUT /users/{id}", owner(&UpdateUserHandler{Updater: srv, MaxBodyBytes: cfg.MaxBodyBytes}))
+	mux.Handle("DELETE /users/{id}", owner(&DeleteUserHandler{Deleter: srv}))
+	mux.Handle("GET /verify", &VerifyHandler{Verifier: srv})
+	mux.Handle("POST /password/reset", reset)
+	mux.Handle("POST /password/reset/confirm", confirmReset)
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.Handle("GET /healthz", HealthHandler{})
+	mux.Handle("GET /openapi.json", &OpenAPIHandler{Document: NewOpenAPI(cfg)})
+
+	ready := ReadyHandler{Logger: logger}
+	if p, ok := r.(Pinger); ok {
+		ready.Pinger = p
+	}
+	if w, ok := r.(WriteChecker); ok && cfg.ReadyWriteCheck {
+		ready.Writer = w
 	}
+	mux.Handle("GET /readyz", &ready)
 
-	mux.Handle("/register", &h)
+	// meters come from the global provider like tracers do, exporters are
+	// set up by whoever installs it.
+	instrumented, err := WithHTTPMetrics(mux, otel.GetMeterProvider())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "http metrics")
+	}
 
 	s := http.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:         cfg.Addr,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		Handler: Chain(instrumented,
+			WithRequestID,
+			func(h http.Handler) http.Handler { return WithRecovery(h, logger.Err) },
+			WithContextValues,
+			func(h http.Handler) http.Handler { return WithCORS(h, CORS{Origins: cfg.CORSOrigins}) },
+			func(h http.Handler) http.Handler { return WithNaming(h, cfg.JSONNaming) },
+		),
+	}
+	if cfg.TLSCert != "" {
+		s.TLSConfig = &tls.Config{MinVersion: cfg.TLSMinVersion}
 	}
+	// stop queueing welcome emails on shutdown, queued ones are sent while
+	// in-flight requests drain.
+	s.RegisterOnShutdown(welcome.Close)
 
-	return &s
+	return &s, NewGRPCServer(registrator, sw, cfg.ConcealExisting, opts...), nil
 }
 
 // Repository is a data access layer.
 type Repository interface {
 	Unique(ctx context.Context, email string) error
+	UniqueUsername(ctx context.Context, username string) error
 	Create(context.Context, *entities.Form) (*entities.User, error)
+	FindByEmail(ctx context.Context, email string) (*entities.User, error)
+	FindByID(ctx context.Context, id string) (*entities.User, error)
+	Delete(ctx context.Context, id string) error
+	SetVerified(ctx context.Context, id string) error
+	UpdatePassword(ctx context.Context, id string, hash string) error
+	Update(ctx context.Context, id string, f *entities.Form) (*entities.User, error)
+	List(ctx context.Context, offset, limit int) ([]entities.User, error)
+	Count(ctx context.Context) (int, error)
 }
 
 // Validator validation abstraction.
 type Validator interface {
 	Validate(context.Context, *entities.Form) error
+	ValidateUpdate(context.Context, *entities.User, *entities.Form) error
+	ValidatePassword(ctx context.Context, password string) error
 }
 
-// ValidationErrors holds validation errors.
+// ValidationErrors holds validation errors, messages are i18n keys.
 type ValidationErrors map[string]string
 
+// Translator translates messages to locales.
+type Translator interface {
+	Locale(acceptLanguage string) string
+	Translate(locale, msg string) string
+}
+
+// defaultTranslator formats messages not translated by handlers in English.
+var defaultTranslator = i18n.NewTranslator()
+
+// translate translates messages of validation errors to locale, other
+// errors are returned as they are.
+func translate(err error, t Translator, locale string) error {
+	var fe FieldErrors
+	if errors.As(err, &fe) {
+		translated := make(FieldErrors, len(fe))
+		for i, e := range fe {
+			e.Message = t.Translate(locale, e.Message)
+			translated[i] = e
+		}
+
+		return translated
+	}
+
+	var v ValidationErrors
+	if !errors.As(err, &v) {
+		return err
+	}
+
+	translated := make(ValidationErrors, len(v))
+	for field, msg := range v {
+		translated[field] = t.Translate(locale, msg)
+	}
+
+	return translated
+}
+
 // Error implements error interface
 func (v ValidationErrors) Error() string {
 	return constants.ValidationMsg
@@ -86,17 +466,107 @@ func (v ValidationErrors) Error() string {
 type Service struct {
 	Validator
 	Repository
+	Hasher hasher.PasswordHasher
+	// LowercaseEmail lowercases the whole email instead of only its domain.
+	LowercaseEmail bool
+	// Verifications is optional, when set registered users get a
+	// verification token delivered by VerificationNotifier once the
+	// registration is stored.
+	Verifications        OneTimeTokens
+	VerificationNotifier VerificationNotifier
+	// Resets issues password reset tokens, ResetNotifier is optional and
+	// delivers them.
+	Resets        OneTimeTokens
+	ResetNotifier ResetNotifier
+	// UnitOfWork is optional, when set the user is created, its verification
+	// issued and the registration audited in a single transaction.
+	UnitOfWork UnitOfWork
+	// Audit is optional, when set registrations are recorded within the
+	// transaction, so a failed record rolls the user back.
+	Audit AuditLogger
+	// Attempts is optional, when set emails failing to log in too often are
+	// locked out.
+	Attempts LoginAttempts
+	// History is optional, when set with a positive HistoryDepth changed
+	// passwords cannot reuse the current one or HistoryDepth previous ones.
+	History      PasswordHistory
+	HistoryDepth int
+	// ConcealExisting makes registrations of taken emails hash the password
+	// as new ones do, so response times do not reveal taken emails.
+	ConcealExisting bool
+
+	// dummyHash is compared for unknown emails, see compareDummy.
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+// NewService prepares service with its required dependencies, optional ones
+// are set on the returned service.
+func NewService(v Validator, r Repository, h hasher.PasswordHasher) (*Service, error) {
+	switch {
+	case v == nil:
+		return nil, errors.New("service validator is required")
+	case r == nil:
+		return nil, errors.New("service repository is required")
+	case h == nil:
+		return nil, errors.New("service hasher is required")
+	}
+
+	return &Service{Validator: v, Repository: r, Hasher: h}, nil
 }
 
 // Register hold registration domain logic.
 func (s *Service) Register(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	f.Email = entities.NormalizeEmail(f.Email, s.LowercaseEmail)
+
 	if err := s.Validator.Validate(ctx, f); err != nil {
+		if s.ConcealExisting && onlyTaken(err) {
+			s.Hasher.Hash(f.Password)
+		}
+
 		return nil, errors.Wrap(err, "validator validate")
 	}
 
-	user, err := s.Create(ctx, f)
+	var (
+		user         *entities.User
+		verification string
+	)
+	create := func(repo Repository) error {
+		var err error
+		if user, err = repo.Create(ctx, f); err != nil {
+			return errors.Wrap(err, "repository create")
+		}
+
+		if s.Verifications != nil {
+			if verification, err = s.GenerateVerification(ctx, user); err != nil {
+				return errors.Wrap(err, "generate verification")
+			}
+		}
+
+		if s.Audit != nil {
+			e := audit.Entry{Time: time.Now().UTC(), Email: user.Email, Outcome: audit.Success, SourceIP: SourceIP(ctx)}
+			if err := s.Audit.Record(ctx, e); err != nil {
+				return errors.Wrap(err, "audit record")
+			}
+		}
+
+		return nil
+	}
+
+	var err error
+	if s.UnitOfWork != nil {
+		err = InTransaction(ctx, s.UnitOfWork, create)
+	} else {
+		err = create(s.Repository)
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "repository create")
+		return nil, err
+	}
+
+	// rolled back registrations must not be verified, so tokens are
+	// delivered after the commit.
+	if verification != "" && s.VerificationNotifier != nil {
+		s.VerificationNotifier.NotifyVerification(ctx, user, verification)
 	}
 
 	return user, nil
@@ -107,63 +577,391 @@ type Registrator interface {
 	Register(context.Context, *entities.Form) (*entities.User, error)
 }
 
+// Tokenizer issues access tokens for users.
+type Tokenizer interface {
+	Generate(*entities.User) (string, error)
+}
+
+// DefaultMaxBodyBytes is a request body limit used when none is configured.
+const DefaultMaxBodyBytes = 1 << 20
+
 // RegistrationHandler for registration requrests.
 type RegistrationHandler struct {
 	Registrator
+	// Tokenizer is optional, when set the token is returned in the
+	// Authorization header.
+	Tokenizer Tokenizer
+	// MaxBodyBytes limits request body size, DefaultMaxBodyBytes is used when zero.
+	MaxBodyBytes int64
+	// Schema is optional, when set request bodies are validated against it
+	// before decoding.
+	Schema *SchemaValidator
+	// Translator is optional, when set validation messages are translated
+	// to the locale of the Accept-Language header, English is used otherwise.
+	Translator Translator
+	// ConcealExisting keeps taken emails from being revealed, registrations
+	// of new and taken emails are both accepted with a neutral response.
+	ConcealExisting bool
 }
 
 // ServerHTTP implements http.Handler.
 func (h *RegistrationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var f entities.Form
-	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	f, ok := decodeForm(w, r, h.MaxBodyBytes, h.Schema)
+	if !ok {
 		return
 	}
 
-	u, err := h.Register(r.Context(), &f)
+	u, err := h.Register(WithSourceIP(r.Context(), clientIP(r)), f)
+	if h.ConcealExisting {
+		if err = concealTaken(err); err == nil {
+			writeAccepted(w)
+			return
+		}
+	}
 	if err != nil {
-		switch v := errors.Cause(err).(type) {
-		case ValidationErrors:
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			json.NewEncoder(w).Encode(v)
-		default:
-			w.WriteHeader(http.StatusInternalServerError)
+		if h.Translator != nil {
+			err = translate(err, h.Translator, h.Translator.Locale(r.Header.Get("Accept-Language")))
 		}
+
+		encodeErrorFor(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(&u)
+	if h.Tokenizer != nil {
+		t, err := h.Tokenizer.Generate(u)
+		if err != nil {
+			encodeError(w, err)
+			return
+		}
+		w.Header().Set("Authorization", "Bearer "+t)
+	}
+
+	w.Header().Set("Location", "/users/"+u.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transport.NewUserResponse(u.Safe()))
+}
+
+// decodeForm decodes form from the JSON request body of at most limit bytes
+// validated against the optional schema and writes the error response when
+// it fails.
+func decodeForm(w http.ResponseWriter, r *http.Request, limit int64, schema *SchemaValidator) (*entities.Form, bool) {
+	body, ok := readJSON(w, r, limit)
+	if !ok {
+		return nil, false
+	}
+
+	f, status, e := parseForm(body, schema)
+	if f == nil {
+		writeError(w, status, e)
+		return nil, false
+	}
+
+	return f, true
+}
+
+// decodeJSON decodes v from the JSON request body of at most limit bytes,
+// rejecting unknown fields, and writes the error response when it fails.
+func decodeJSON(w http.ResponseWriter, r *http.Request, limit int64, v interface{}) bool {
+	body, ok := readJSON(w, r, limit)
+	if !ok {
+		return false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, decodeError(err))
+		return false
+	}
+
+	return true
+}
+
+// readJSON reads the JSON request body of at most limit bytes and writes the
+// error response when it fails.
+func readJSON(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	if !isJSON(r.Header.Get("Content-Type")) {
+		writeError(w, http.StatusUnsupportedMediaType, ErrorBody{Code: CodeUnsupportedMediaType, Message: "content type must be application/json"})
+		return nil, false
+	}
+
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if stderrors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, ErrorBody{Code: CodeBodyTooLarge, Message: err.Error()})
+			return nil, false
+		}
+
+		writeError(w, http.StatusBadRequest, ErrorBody{Code: CodeInvalidJSON, Message: err.Error()})
+		return nil, false
+	}
+
+	return body, true
+}
+
+// parseForm validates body against the optional schema and decodes form
+// from it. The status and error body are returned when it fails.
+func parseForm(body []byte, schema *SchemaValidator) (*entities.Form, int, ErrorBody) {
+	if schema != nil {
+		if err := schema.Validate(body); err != nil {
+			if _, ok := err.(ValidationErrors); ok {
+				status, e := errorStatus(err)
+				return nil, status, e
+			}
+
+			return nil, http.StatusBadRequest, decodeError(err)
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+
+	var req transport.RegisterRequest
+	if err := dec.Decode(&req); err != nil {
+		return nil, http.StatusBadRequest, decodeError(err)
+	}
+
+	return req.Form(), 0, ErrorBody{}
+}
+
+// isJSON reports whether content type is JSON with an optional utf-8 charset.
+func isJSON(contentType string) bool {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mt != "application/json" {
+		return false
+	}
+
+	charset, ok := params["charset"]
+	return !ok || strings.EqualFold(charset, "utf-8")
+}
+
+// decodeError describes why a JSON body could not be decoded.
+func decodeError(err error) ErrorBody {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case stderrors.Is(err, io.EOF):
+		return ErrorBody{Code: CodeEmptyBody, Message: "request body is empty"}
+	case stderrors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorBody{Code: CodeInvalidJSON, Message: "malformed JSON, body ends unexpectedly"}
+	case stderrors.As(err, &syntaxErr):
+		return ErrorBody{Code: CodeInvalidJSON, Message: fmt.Sprintf("malformed JSON at byte %d", syntaxErr.Offset)}
+	case stderrors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+
+		return ErrorBody{Code: CodeInvalidType, Message: fmt.Sprintf("%s must be %s, got %s", field, jsonKind(typeErr.Type), typeErr.Value)}
+	}
+
+	if field, ok := unknownField(err); ok {
+		return ErrorBody{Code: CodeUnknownField, Message: fmt.Sprintf("unknown field %q", field)}
+	}
+
+	return ErrorBody{Code: CodeInvalidJSON, Message: err.Error()}
+}
+
+// jsonKind names the JSON type values of t are decoded from.
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+
+	return t.String()
 }
 
+// unknownField extracts the field name from a decoder error caused by
+// DisallowUnknownFields.
+func unknownField(err error) (string, bool) {
+	const prefix = "json: unknown field "
+	msg := err.Error()
+	if !strings.HasPrefix(msg, prefix) {
+		return "", false
+	}
+
+	field, err := strconv.Unquote(strings.TrimPrefix(msg, prefix))
+	if err != nil {
+		return "", false
+	}
+
+	return field, true
+}
+
+// Default password length bounds in characters.
+const (
+	DefaultMinPassword = 3
+	DefaultMaxPassword = 16
+)
+
+// DefaultMaxName is a default max length of profile names in characters.
+const DefaultMaxName = 64
+
+// Max lengths of emails and their local parts in characters, RFC 5321
+// limits forward paths to 256 octets including the angle brackets.
+const (
+	MaxEmailLength      = 254
+	MaxEmailLocalLength = 64
+)
+
 // PlayValidator holds registration form validations.
 type PlayValidator struct {
 	Validator *validator.Validate
 	Repository
+	// BlockedDomains are disposable email domains rejected on registration.
+	BlockedDomains []string
+	// AllowedDomains are the only email domains accepted, all are accepted
+	// when empty.
+	AllowedDomains []string
+	// MinPassword and MaxPassword bound password length in characters,
+	// defaults are used when zero.
+	MinPassword int
+	MaxPassword int
+	// RequireNames rejects forms without first and last name, names are
+	// optional otherwise.
+	RequireNames bool
+	// MaxName bounds profile names length in characters, default is used
+	// when zero.
+	MaxName int
+	// Breaches is optional, when set passwords found in breaches are rejected.
+	Breaches BreachChecker
+	// MinEntropy is a password strength in bits passwords must reach, zero
+	// disables the check.
+	MinEntropy float64
+	// Estimator estimates password strength, shannon entropy is used when nil.
+	Estimator EntropyEstimator
+	// Logger is optional, when set failures of the uniqueness check are
+	// logged.
+	Logger Logger
+}
+
+// EntropyEstimator estimates how hard a password is to guess in bits.
+type EntropyEstimator interface {
+	Entropy(password string) float64
+}
+
+// BreachChecker tells whether a password is known to be compromised.
+type BreachChecker interface {
+	Breached(ctx context.Context, password string) (bool, error)
 }
 
 // Validate implements Validator.
 func (v *PlayValidator) Validate(ctx context.Context, f *entities.Form) error {
-	validations := make(ValidationErrors)
+	return v.validate(ctx, f, true, true)
+}
+
+// ValidateUpdate implements Validator, uniqueness is checked only when the
+// email changes. Usernames are set on registration only and are not checked.
+func (v *PlayValidator) ValidateUpdate(ctx context.Context, u *entities.User, f *entities.Form) error {
+	return v.validate(ctx, f, u.Email != f.Email, false)
+}
+
+// validate runs form validations, checking uniqueness of the email when
+// unique is set and of the username when username is set.
+func (v *PlayValidator) validate(ctx context.Context, f *entities.Form, unique, username bool) error {
+	var validations FieldErrors
+
+	// trailing whitespace is usually a copy-paste artifact.
+	password := strings.TrimRightFunc(f.Password, unicode.IsSpace)
+	confirmation := strings.TrimRightFunc(f.PasswordConfirmation, unicode.IsSpace)
 
 	err := v.Validator.Struct(f)
 	if err != nil {
 		if vs, ok := err.(validator.ValidationErrors); ok {
 			for _, v := range vs {
-				validations[v.Tag()] = fmt.Sprintf("%s is invalid", v.Tag())
+				validations.Set(v.Tag(), v.Tag(), i18n.Message(constants.Invalid, v.Tag()))
 			}
 		}
 	}
 
-	if f.Password != f.PasswordConfirmation {
-		validations["password"] = constants.PasswordMismatch
+	// the base email rule goes first, edge cases are checked on emails it accepts.
+	if !validations.Has("email") {
+		if msg := checkEmail(f.Email); msg != "" {
+			validations.Set("email", RuleEmail, msg)
+		}
+	}
+
+	if !validations.Has("email") && containsFold(v.BlockedDomains, emailDomain(f.Email)) {
+		validations.Set("email", RuleDisposable, constants.DisposableEmail)
+	}
+
+	if !validations.Has("email") && len(v.AllowedDomains) > 0 && !containsFold(v.AllowedDomains, emailDomain(f.Email)) {
+		validations.Set("email", RuleAllowedDomain, constants.DomainNotAllowed)
+	}
+
+	if msg := v.checkPassword(password); msg != "" {
+		validations.Set("password", RuleLength, msg)
+	} else if v.weak(password) {
+		validations.Set("password", RuleStrength, constants.PasswordWeak)
+	}
+
+	v.checkNames(f, &validations)
+
+	// length is reported first, a mismatch is pointless to fix before it.
+	if confirmation == "" {
+		validations.Set("password_confirmation", RuleRequired, constants.Required)
+	} else if !validations.Has("password") && password != confirmation {
+		validations.Set("password", RuleConfirmation, constants.PasswordMismatch)
+	}
+
+	if !validations.Has("password") && matchesEmail(password, f.Email) {
+		validations.Set("password", RuleNotEmail, constants.PasswordEmail)
 	}
 
-	if err := v.Repository.Unique(ctx, f.Email); err != nil {
-		if err != svcerrors.ErrEmailExists {
-			return errors.Wrap(err, "repository unique")
+	if !validations.Has("password") {
+		msg, err := v.checkBreach(ctx, password)
+		if err != nil {
+			return err
 		}
 
-		validations["email"] = constants.EmailExists
+		if msg != "" {
+			validations.Set("password", RuleBreached, msg)
+		}
+	}
+
+	if unique {
+		err := v.Repository.Unique(ctx, f.Email)
+		switch {
+		case errors.Is(err, svcerrors.ErrEmailExists):
+			validations.Set("email", RuleUnique, constants.EmailExists)
+		case err != nil:
+			err = v.uniqueError(ctx, err)
+			// invalid forms are reported as such even when storage fails.
+			if len(validations) == 0 {
+				return err
+			}
+		}
+	}
+
+	if username && f.Username != "" {
+		err := v.Repository.UniqueUsername(ctx, f.Username)
+		switch {
+		case errors.Is(err, svcerrors.ErrUsernameExists):
+			validations.Set("username", RuleUnique, constants.UsernameExists)
+		case err != nil:
+			if err = v.uniqueError(ctx, err); len(validations) == 0 {
+				return err
+			}
+		}
 	}
 
 	if len(validations) > 0 {
@@ -172,3 +970,168 @@ func (v *PlayValidator) Validate(ctx context.Context, f *entities.Form) error {
 
 	return nil
 }
+
+// uniqueError classifies a failure of the uniqueness check. Cancelled and
+// timed out requests are returned as they are, other failures are logged
+// and reported as ErrStorageUnavailable, as the form is not at fault.
+func (v *PlayValidator) uniqueError(ctx context.Context, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return errors.Wrap(err, "repository unique")
+	}
+
+	if v.Logger != nil {
+		v.Logger.Error("PlayValidator: Unique failed", append([]interface{}{"error", err.Error()}, LogContext.Fields(ctx)...)...)
+	}
+
+	return fmt.Errorf("repository unique: %w: %w", svcerrors.ErrStorageUnavailable, err)
+}
+
+// ValidatePassword implements Validator.
+func (v *PlayValidator) ValidatePassword(ctx context.Context, password string) error {
+	password = strings.TrimRightFunc(password, unicode.IsSpace)
+	if msg := v.checkPassword(password); msg != "" {
+		return FieldErrors{{Field: "password", Rule: RuleLength, Message: msg}}
+	}
+
+	if v.weak(password) {
+		return FieldErrors{{Field: "password", Rule: RuleStrength, Message: constants.PasswordWeak}}
+	}
+
+	msg, err := v.checkBreach(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	if msg != "" {
+		return FieldErrors{{Field: "password", Rule: RuleBreached, Message: msg}}
+	}
+
+	return nil
+}
+
+// weak reports whether password falls below the minimum entropy.
+func (v *PlayValidator) weak(password string) bool {
+	if v.MinEntropy <= 0 {
+		return false
+	}
+
+	var e EntropyEstimator = strength.Shannon{}
+	if v.Estimator != nil {
+		e = v.Estimator
+	}
+
+	return e.Entropy(password) < v.MinEntropy
+}
+
+// checkBreach returns a message when password was found in a breach.
+func (v *PlayValidator) checkBreach(ctx context.Context, password string) (string, error) {
+	if v.Breaches == nil {
+		return "", nil
+	}
+
+	breached, err := v.Breaches.Breached(ctx, password)
+	if err != nil {
+		return "", errors.Wrap(err, "breach checker")
+	}
+
+	if breached {
+		return constants.BreachedPassword, nil
+	}
+
+	return "", nil
+}
+
+// checkNames adds validation errors of profile names of the form.
+func (v *PlayValidator) checkNames(f *entities.Form, validations *FieldErrors) {
+	max := v.MaxName
+	if max <= 0 {
+		max = DefaultMaxName
+	}
+
+	names := []struct {
+		field, value string
+		required     bool
+	}{
+		{"first_name", f.FirstName, v.RequireNames},
+		{"last_name", f.LastName, v.RequireNames},
+		{"display_name", f.DisplayName, false},
+		{"username", f.Username, false},
+	}
+	for _, n := range names {
+		switch {
+		case n.required && strings.TrimSpace(n.value) == "":
+			validations.Set(n.field, RuleRequired, constants.Required)
+		case utf8.RuneCountInString(n.value) > max:
+			validations.Set(n.field, RuleLength, i18n.Message(constants.NameLength, max))
+		}
+	}
+}
+
+// checkPassword returns a message when password length is out of bounds.
+func (v *PlayValidator) checkPassword(password string) string {
+	min, max := v.MinPassword, v.MaxPassword
+	if min <= 0 {
+		min = DefaultMinPassword
+	}
+	if max <= 0 {
+		max = DefaultMaxPassword
+	}
+
+	if n := utf8.RuneCountInString(password); n < min || n > max {
+		return i18n.Message(constants.PasswordLength, min, max)
+	}
+
+	return ""
+}
+
+// checkEmail returns validation message of edge cases the email rule lets
+// through: over-long addresses, misplaced dots in quoted local parts and
+// one letter top-level domains.
+func checkEmail(email string) string {
+	if utf8.RuneCountInString(email) > MaxEmailLength {
+		return i18n.Message(constants.EmailLength, MaxEmailLength)
+	}
+
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return ""
+	}
+	local, domain := email[:i], email[i+1:]
+
+	if utf8.RuneCountInString(local) > MaxEmailLocalLength {
+		return i18n.Message(constants.EmailLocalLength, MaxEmailLocalLength)
+	}
+
+	local = strings.Trim(local, `"`)
+	if strings.Contains(local, "..") || strings.HasPrefix(local, ".") || strings.HasSuffix(local, ".") {
+		return constants.EmailDots
+	}
+
+	if j := strings.LastIndex(domain, "."); j < 0 || len(domain)-j-1 < 2 {
+		return constants.EmailTLD
+	}
+
+	return ""
+}
+
+// matchesEmail reports whether password equals the email or its local part
+// ignoring case.
+func matchesEmail(password, email string) bool {
+	email = strings.TrimSpace(email)
+	local := email
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		local = email[:i]
+	}
+
+	return strings.EqualFold(password, email) || strings.EqualFold(password, local)
+}
+
+// emailDomain returns domain part of the email.
+func emailDomain(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return ""
+	}
+
+	return email[i+1:]
+}
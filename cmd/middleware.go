@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/idempotency"
+	"github.com/newtondev/service_object/pkg/token"
+)
+
+// Middleware wraps a handler with a cross-cutting concern.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with middlewares, the first one is the outermost and sees
+// requests first.
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+
+	return h
+}
+
+// WithRecovery recovers panics of h logging the panic with its full stack
+// to errlog and responding with internal server error and the error envelope.
+// http.ErrAbortHandler is passed through to abort the response silently.
+func WithRecovery(h http.Handler, errlog *log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			errlog.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, p, debug.Stack())
+			writeError(w, http.StatusInternalServerError, ErrorBody{Code: CodeInternal, Message: http.StatusText(http.StatusInternalServerError)})
+		}()
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// WithTimeout cancels the request context of h after timeout and responds
+// with service unavailable and the error envelope. Zero timeout leaves h unchanged.
+func WithTimeout(h http.Handler, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		return h
+	}
+
+	return http.TimeoutHandler(h, timeout, errorJSON(ErrorBody{Code: CodeTimeout, Message: "request timed out"}))
+}
+
+// LimiterStore tracks request rates per key.
+type LimiterStore interface {
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+}
+
+// WithRateLimit rejects requests of client IPs exceeding limits tracked by
+// store with too many requests and a Retry-After header.
+func WithRateLimit(h http.Handler, store LimiterStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, retry, err := store.Allow(r.Context(), clientIP(r))
+		if err != nil {
+			encodeError(w, err)
+			return
+		}
+
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, ErrorBody{Code: CodeRateLimited, Message: "too many requests"})
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// TokenParser verifies access tokens.
+type TokenParser interface {
+	Parse(string) (*token.Claims, error)
+}
+
+// WithRole lets through only requests with a bearer token verified by
+// parser and carrying role. Requests without a valid token get unauthorized
+// and tokens of other roles get forbidden. Nil parser rejects every request.
+func WithRole(h http.Handler, parser TokenParser, role string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := bearerClaims(w, r, parser)
+		if !ok {
+			return
+		}
+
+		if c.Role != role {
+			writeError(w, http.StatusForbidden, ErrorBody{Code: CodeForbidden, Message: http.StatusText(http.StatusForbidden)})
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+type userIDKey struct{}
+
+// WithAuthentication lets through only requests with a bearer token verified
+// by parser, storing the id of the token user in the request context.
+// Requests without a valid token get unauthorized, as with nil parser.
+func WithAuthentication(h http.Handler, parser TokenParser) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := bearerClaims(w, r, parser)
+		if !ok {
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDKey{}, c.UserID())
+		ctx = context.WithValue(ctx, roleKey{}, c.Role)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// UserIDFromContext returns id of the authenticated user carried by ctx.
+func UserIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey{}).(string)
+	return id
+}
+
+type roleKey struct{}
+
+// RoleFromContext returns role of the authenticated user carried by ctx.
+func RoleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(roleKey{}).(string)
+	return role
+}
+
+// WithOwner lets through only requests of the user named by the id path
+// value, or of users with role, as authenticated by WithAuthentication.
+// Requests of other users get forbidden and unauthenticated ones
+// unauthorized.
+func WithOwner(h http.Handler, role string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := UserIDFromContext(r.Context())
+		if id == "" {
+			writeError(w, http.StatusUnauthorized, ErrorBody{Code: CodeUnauthorized, Message: "missing access token"})
+			return
+		}
+
+		if id != r.PathValue("id") && RoleFromContext(r.Context()) != role {
+			writeError(w, http.StatusForbidden, ErrorBody{Code: CodeForbidden, Message: http.StatusText(http.StatusForbidden)})
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+type bearerKey struct{}
+
+// WithBearer extracts the token of a "Bearer <token>" Authorization header
+// into the request context before h verifies it. The scheme is matched case
+// insensitively and extra whitespace is ignored, missing and malformed
+// headers get unauthorized.
+func WithBearer(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, ok := bearerToken(w, r)
+		if !ok {
+			return
+		}
+
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), bearerKey{}, t)))
+	})
+}
+
+// BearerFromContext returns the bearer token of the request carried by ctx.
+func BearerFromContext(ctx context.Context) string {
+	t, _ := ctx.Value(bearerKey{}).(string)
+	return t
+}
+
+// bearerToken returns the token of the Authorization header of r, writing
+// unauthorized when it is missing or malformed.
+func bearerToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	fields := strings.Fields(r.Header.Get("Authorization"))
+	switch {
+	case len(fields) == 0:
+		writeError(w, http.StatusUnauthorized, ErrorBody{Code: CodeUnauthorized, Message: "missing access token"})
+		return "", false
+	case len(fields) != 2 || !strings.EqualFold(fields[0], "Bearer"):
+		writeError(w, http.StatusUnauthorized, ErrorBody{Code: CodeUnauthorized, Message: "malformed authorization header"})
+		return "", false
+	}
+
+	return fields[1], true
+}
+
+// bearerClaims returns claims of the bearer token of r verified by parser,
+// writing unauthorized when it is missing or invalid. The token extracted
+// by WithBearer is used when present.
+func bearerClaims(w http.ResponseWriter, r *http.Request, parser TokenParser) (*token.Claims, bool) {
+	t := BearerFromContext(r.Context())
+	if t == "" {
+		var ok bool
+		if t, ok = bearerToken(w, r); !ok {
+			return nil, false
+		}
+	}
+
+	if parser == nil {
+		writeError(w, http.StatusUnauthorized, ErrorBody{Code: CodeUnauthorized, Message: "missing access token"})
+		return nil, false
+	}
+
+	c, err := parser.Parse(t)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, ErrorBody{Code: CodeUnauthorized, Message: "invalid access token"})
+		return nil, false
+	}
+
+	return c, true
+}
+
+// clientIP returns IP address of the request peer.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// IdempotencyStore remembers responses per Idempotency-Key.
+type IdempotencyStore interface {
+	// Reserve returns the response recorded for key, otherwise marks key in
+	// flight until Put or Release. Keys in flight get ErrRequestInFlight.
+	Reserve(ctx context.Context, key string) (*idempotency.Response, error)
+	Put(ctx context.Context, key string, resp *idempotency.Response) error
+	Release(ctx context.Context, key string) error
+}
+
+// WithIdempotency replays the response recorded for a repeated
+// Idempotency-Key header instead of calling h again. Keys are scoped to the
+// client IP and bound to the request body, reusing a key for another body
+// gets unprocessable entity and repeating it while the first request is in
+// flight gets conflict. Server errors are not recorded so clients can retry
+// them, and issued Authorization headers are never replayed. Bodies over
+// limit bytes are passed to h as they are, DefaultMaxBodyBytes is used when
+// limit is zero.
+func WithIdempotency(h http.Handler, store IdempotencyStore, limit int64) http.Handler {
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		key = clientIP(r) + " " + r.Method + " " + r.URL.Path + " " + key
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrorBody{Code: CodeInvalidJSON, Message: "failed to read request body"})
+			return
+		}
+		if int64(len(body)) > limit {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			h.ServeHTTP(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(sum[:])
+
+		resp, err := store.Reserve(r.Context(), key)
+		if err != nil {
+			encodeError(w, err)
+			return
+		}
+
+		if resp != nil {
+			if resp.Fingerprint != fingerprint {
+				writeError(w, http.StatusUnprocessableEntity, ErrorBody{Code: CodeIdempotencyMismatch, Message: "idempotency key was used for another request"})
+				return
+			}
+
+			for k, v := range resp.Header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(resp.Status)
+			w.Write(resp.Body)
+			return
+		}
+
+		// panics and server errors leave the key free for retries.
+		recorded := false
+		defer func() {
+			if !recorded {
+				store.Release(context.WithoutCancel(r.Context()), key)
+			}
+		}()
+
+		rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+
+		if rec.status < http.StatusInternalServerError {
+			header := w.Header().Clone()
+			header.Del("Authorization")
+
+			recorded = store.Put(context.WithoutCancel(r.Context()), key, &idempotency.Response{
+				Status:      rec.status,
+				Header:      header,
+				Body:        rec.body.Bytes(),
+				Fingerprint: fingerprint,
+			}) == nil
+		}
+	})
+}
+
+// recordingWriter passes the response through keeping a copy of status and body.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
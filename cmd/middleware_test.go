@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/idempotency"
+	"github.com/newtondev/service_object/pkg/ratelimit"
+	"github.com/newtondev/service_object/pkg/storage"
+	"github.com/newtondev/service_object/pkg/token"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Log("with slow repository.")
+	{
+		repo := &slowStorage{MemStore: testStorage(), delay: time.Second, cancelled: make(chan struct{})}
+		srv, err := NewServer(Config{JWTSecret: testSecret, RequestTimeout: 50 * time.Millisecond}, ioutil.Discard, repo, prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		t.Log("\ttest:0\tshould respond with service unavailable and cancel the repository call.")
+		{
+			resp, err := http.Post(fmt.Sprintf("%s/register", s.URL), "application/json", strings.NewReader(`{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+			select {
+			case <-repo.cancelled:
+			case <-time.After(time.Second):
+				t.Error("repository context was not cancelled")
+			}
+		}
+	}
+}
+
+// slowStorage delays uniqueness checks until delay passes or ctx is done.
+type slowStorage struct {
+	*storage.MemStore
+	delay     time.Duration
+	cancelled chan struct{}
+}
+
+func (s *slowStorage) Unique(ctx context.Context, email string) error {
+	select {
+	case <-time.After(s.delay):
+		return s.MemStore.Unique(ctx, email)
+	case <-ctx.Done():
+		close(s.cancelled)
+		return ctx.Err()
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Log("with rate limited handler.")
+	{
+		h := WithRateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), ratelimit.NewMemory(1, 2))
+
+		t.Log("\ttest:0\tshould allow requests within the burst.")
+		{
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest("POST", "/register", nil))
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+		}
+
+		t.Log("\ttest:1\tshould reject requests over the threshold.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/register", nil))
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+			assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		}
+
+		t.Log("\ttest:2\tshould limit client IPs independently.")
+		{
+			req := httptest.NewRequest("POST", "/register", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	t.Log("with idempotent registration server.")
+	{
+		repo := testStorage()
+		srv, err := NewServer(Config{IdempotencyTTL: time.Minute, JWTSecret: testSecret}, ioutil.Discard, repo, prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		register := func(key, email string) (*http.Response, string) {
+			req, err := http.NewRequest("POST", fmt.Sprintf("%s/register", s.URL), strings.NewReader(fmt.Sprintf(`{"email": "%s", "password": "qwerty", "password_confirmation": "qwerty"}`, email)))
+			assert.Nil(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Idempotency-Key", key)
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.Nil(t, err)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.Nil(t, err)
+
+			return resp, string(body)
+		}
+
+		t.Log("\ttest:0\tshould process request with a new key.")
+		first, body := register("key-1", "new@domain.zone")
+		{
+			assert.Equal(t, http.StatusCreated, first.StatusCode)
+			assert.Empty(t, first.Header.Get("Idempotent-Replayed"))
+			assert.Len(t, repo.Users, 2)
+		}
+
+		t.Log("\ttest:1\tshould return the original response for a repeated key.")
+		{
+			resp, replayed := register("key-1", "new@domain.zone")
+			assert.Equal(t, http.StatusCreated, resp.StatusCode)
+			assert.Equal(t, "true", resp.Header.Get("Idempotent-Replayed"))
+			assert.Equal(t, first.Header.Get("Location"), resp.Header.Get("Location"))
+			assert.Equal(t, body, replayed)
+			assert.Len(t, repo.Users, 2)
+		}
+
+		t.Log("\ttest:2\tshould process another key normally.")
+		{
+			resp, _ := register("key-2", "other@domain.zone")
+			assert.Equal(t, http.StatusCreated, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get("Idempotent-Replayed"))
+			assert.Len(t, repo.Users, 3)
+		}
+
+		t.Log("\ttest:3\tshould not replay the issued access token.")
+		{
+			assert.NotEmpty(t, first.Header.Get("Authorization"))
+
+			resp, _ := register("key-1", "new@domain.zone")
+			assert.Equal(t, "true", resp.Header.Get("Idempotent-Replayed"))
+			assert.Empty(t, resp.Header.Get("Authorization"))
+		}
+
+		t.Log("\ttest:4\tshould reject a repeated key with another body.")
+		{
+			resp, body := register("key-1", "third@domain.zone")
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get("Idempotent-Replayed"))
+			assert.JSONEq(t, `{"error":{"code":"idempotency_key_mismatch","message":"idempotency key was used for another request"}}`, body)
+			assert.Len(t, repo.Users, 3)
+		}
+	}
+
+	t.Log("with idempotent handler blocking until released.")
+	{
+		release := make(chan struct{})
+		started := make(chan struct{}, 1)
+		var calls int32
+		h := WithIdempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			started <- struct{}{}
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		}), idempotency.NewMemory(time.Minute), 0)
+
+		serve := func(ip string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("POST", "/register", strings.NewReader(`{}`))
+			r.RemoteAddr = ip + ":1234"
+			r.Header.Set("Idempotency-Key", "key")
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w
+		}
+
+		t.Log("\ttest:0\tshould reject a repeated key while the first request is in flight.")
+		{
+			done := make(chan *httptest.ResponseRecorder)
+			go func() { done <- serve("192.0.2.1") }()
+			<-started
+
+			w := serve("192.0.2.1")
+			assert.Equal(t, http.StatusConflict, w.Code)
+			assert.JSONEq(t, `{"error":{"code":"request_in_flight","message":"request with the idempotency key is in progress"}}`, w.Body.String())
+
+			close(release)
+			assert.Equal(t, http.StatusCreated, (<-done).Code)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		}
+
+		t.Log("\ttest:1\tshould scope keys to the client.")
+		{
+			w := serve("192.0.2.2")
+			<-started
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+			assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	t.Log("with middlewares.")
+	{
+		var order []string
+		mark := func(name string) Middleware {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, name)
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+		h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order = append(order, "handler")
+		}), mark("first"), mark("second"))
+
+		t.Log("\ttest:0\tshould run middlewares in order before the handler.")
+		{
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, []string{"first", "second", "handler"}, order)
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	t.Log("with panicking handler.")
+	{
+		var errlog strings.Builder
+		h := WithRecovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}), log.New(&errlog, "", 0))
+		s := httptest.NewServer(WithRequestID(h))
+		defer s.Close()
+
+		t.Log("\ttest:0\tshould respond with internal error and the error envelope.")
+		{
+			resp, err := http.Get(s.URL)
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+			assert.NotEmpty(t, resp.Header.Get(RequestIDHeader))
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			assert.JSONEq(t, `{"error": {"code": "internal_error", "message": "Internal Server Error"}}`, string(body))
+			assert.Contains(t, errlog.String(), "panic serving GET /: boom")
+			assert.Contains(t, errlog.String(), "goroutine")
+		}
+
+		t.Log("\ttest:1\tshould keep serving requests.")
+		{
+			resp, err := http.Get(s.URL)
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		}
+	}
+}
+
+func TestRegistrationPanic(t *testing.T) {
+	t.Log("with repository panicking on uniqueness checks.")
+	{
+		repo := &panickingStorage{MemStore: testStorage()}
+		srv, err := NewServer(Config{RequestTimeout: time.Second}, ioutil.Discard, repo, prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		t.Log("\ttest:0\tshould respond with internal error and the error envelope.")
+		{
+			resp, err := http.Post(fmt.Sprintf("%s/register", s.URL), "application/json", strings.NewReader(`{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			assert.JSONEq(t, `{"error": {"code": "internal_error", "message": "Internal Server Error"}}`, string(body))
+		}
+
+		t.Log("\ttest:1\tshould keep serving requests.")
+		{
+			resp, err := http.Get(fmt.Sprintf("%s/healthz", s.URL))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	}
+}
+
+// panickingStorage panics on uniqueness checks.
+type panickingStorage struct {
+	*storage.MemStore
+}
+
+func (s *panickingStorage) Unique(ctx context.Context, email string) error {
+	panic("unique")
+}
+
+func TestBearer(t *testing.T) {
+	t.Log("with handler behind bearer middleware.")
+	{
+		var got string
+		h := WithBearer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = BearerFromContext(r.Context())
+		}))
+		serve := func(auth string) *httptest.ResponseRecorder {
+			got = ""
+			r := httptest.NewRequest("GET", "/", nil)
+			if auth != "" {
+				r.Header.Set("Authorization", auth)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w
+		}
+
+		t.Log("\ttest:0\tshould store the token of well-formed headers.")
+		{
+			for _, auth := range []string{"Bearer abc.def", "bearer abc.def", "  BEARER \t abc.def  "} {
+				w := serve(auth)
+				assert.Equal(t, http.StatusOK, w.Code, auth)
+				assert.Equal(t, "abc.def", got, auth)
+			}
+		}
+
+		t.Log("\ttest:1\tshould reject missing header.")
+		{
+			for _, auth := range []string{"", "   "} {
+				w := serve(auth)
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+				assert.JSONEq(t, `{"error":{"code":"unauthorized","message":"missing access token"}}`, w.Body.String())
+				assert.Empty(t, got)
+			}
+		}
+
+		t.Log("\ttest:2\tshould reject malformed header before verification.")
+		{
+			for _, auth := range []string{"Basic abc.def", "Bearer", "Bearer abc def", "abc.def"} {
+				w := serve(auth)
+				assert.Equal(t, http.StatusUnauthorized, w.Code, auth)
+				assert.JSONEq(t, `{"error":{"code":"unauthorized","message":"malformed authorization header"}}`, w.Body.String())
+				assert.Empty(t, got)
+			}
+		}
+	}
+
+	t.Log("with role check behind bearer middleware.")
+	{
+		h := WithBearer(WithRole(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), token.NewJWT(testSecret, 0), entities.RoleAdmin))
+
+		t.Log("\ttest:0\tshould verify the extracted token.")
+		{
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", "  bearer  "+strings.TrimPrefix(bearer(t, entities.RoleAdmin), "Bearer "))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/newtondev/service_object/pkg/transport"
+)
+
+// WithNaming renames keys of JSON responses of h to naming. Field names of
+// validation errors are kept, as requests are still decoded in snake_case.
+func WithNaming(h http.Handler, naming transport.Naming) http.Handler {
+	if naming == transport.SnakeCase || naming == "" {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := &bufferingWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(buf, r)
+
+		// some handlers leave the content type to be sniffed, so bodies
+		// without one are transformed when they are valid JSON.
+		body := buf.body.Bytes()
+		if ct := w.Header().Get("Content-Type"); ct == "" || isJSON(ct) {
+			if b, err := naming.Transform(body, "fields"); err == nil {
+				body = b
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			}
+		}
+
+		w.WriteHeader(buf.status)
+		w.Write(body)
+	})
+}
+
+// bufferingWriter holds back status and body of the response until they
+// are written by the caller.
+type bufferingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferingWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferingWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNaming(t *testing.T) {
+	for _, tt := range []struct {
+		naming transport.Naming
+		user   string
+		err    string
+	}{
+		{
+			naming: transport.SnakeCase,
+			user:   `{"id":"2","email":"new@domain.zone","verified":false,"role":"user","first_name":"Ada","created_at":"2020-01-02T03:04:05Z","updated_at":"2020-01-02T03:04:05Z"}`,
+			err:    `{"error":{"code":"validation_failed","message":"you have validation errors","fields":{"password_confirmation":"required"}}}`,
+		},
+		{
+			naming: transport.CamelCase,
+			user:   `{"id":"2","email":"new@domain.zone","verified":false,"role":"user","firstName":"Ada","createdAt":"2020-01-02T03:04:05Z","updatedAt":"2020-01-02T03:04:05Z"}`,
+			err:    `{"error":{"code":"validation_failed","message":"you have validation errors","fields":{"password_confirmation":"required"}}}`,
+		},
+	} {
+		t.Run(string(tt.naming), func(t *testing.T) {
+			srv, err := NewServer(Config{JSONNaming: tt.naming}, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+			assert.Nil(t, err)
+
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, jsonRequest("POST", "/register", `{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty", "first_name": "Ada"}`))
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.JSONEq(t, tt.user, w.Body.String())
+
+			w = httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, jsonRequest("POST", "/register", `{"email": "other@domain.zone", "password": "qwerty"}`))
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			assert.JSONEq(t, tt.err, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/openapi"
+	"github.com/newtondev/service_object/pkg/transport"
+)
+
+// NewOpenAPI describes the registration API. Schemas are reflected from the
+// wire types, limits configured at runtime are taken from cfg.
+func NewOpenAPI(cfg Config) *openapi.Document {
+	req := openapi.SchemaOf(transport.RegisterRequest{}, entities.Form{})
+	req.Required = append(req.Required, "password", "password_confirmation")
+	maxEmail := MaxEmailLength
+	req.Properties["email"].MaxLength = &maxEmail
+	req.Properties["password"].MinLength = &cfg.MinPassword
+	req.Properties["password"].MaxLength = &cfg.MaxPassword
+
+	max := DefaultMaxName
+	for _, name := range []string{"first_name", "last_name", "display_name", "username"} {
+		req.Properties[name].MaxLength = &max
+	}
+	if cfg.RequireNames {
+		req.Required = append(req.Required, "first_name", "last_name")
+	}
+	if cfg.RequireTerms {
+		req.Required = append(req.Required, "accepted_terms")
+	}
+
+	errorResponse := func(description string) *openapi.Response {
+		return &openapi.Response{Description: description, Content: openapi.JSON(openapi.Ref("ErrorResponse"))}
+	}
+
+	register := map[string]*openapi.Response{
+		"201": {Description: "user is registered", Content: openapi.JSON(openapi.Ref("UserResponse"))},
+		"422": errorResponse("form is invalid"),
+		"500": errorResponse("registration failed"),
+	}
+	if cfg.ConcealExisting {
+		delete(register, "201")
+		register["202"] = &openapi.Response{Description: "registration is accepted, the email may already be taken", Content: openapi.JSON(openapi.Ref("AcceptedResponse"))}
+	}
+
+	return &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info:    openapi.Info{Title: "service_object", Version: "1.0.0"},
+		Paths: map[string]openapi.PathItem{
+			"/register": {
+				"post": {
+					Summary:     "Register a user",
+					RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("RegisterRequest"))},
+					Responses:   register,
+				},
+			},
+			"/register/validate": {
+				"post": {
+					Summary:     "Validate a registration without registering the user",
+					RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("RegisterRequest"))},
+					Responses: map[string]*openapi.Response{
+						"200": {Description: "form is valid", Content: openapi.JSON(openapi.Ref("DryRunResponse"))},
+						"422": errorResponse("form is invalid"),
+						"500": errorResponse("validation failed"),
+					},
+				},
+			},
+		},
+		Components: openapi.Components{
+			Schemas: map[string]*openapi.Schema{
+				"RegisterRequest":  req,
+				"UserResponse":     openapi.SchemaOf(transport.UserResponse{}),
+				"DryRunResponse":   openapi.SchemaOf(DryRunResponse{}),
+				"ErrorResponse":    openapi.SchemaOf(ErrorResponse{}),
+				"AcceptedResponse": openapi.SchemaOf(AcceptedResponse{}),
+			},
+		},
+	}
+}
+
+// OpenAPIHandler serves the OpenAPI document.
+type OpenAPIHandler struct {
+	Document *openapi.Document
+}
+
+// ServeHTTP implements http.Handler.
+func (h *OpenAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Document)
+}
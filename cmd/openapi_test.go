@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/openapi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPI(t *testing.T) {
+	t.Log("with initialized server.")
+	{
+		cfg := Config{MinPassword: 8, MaxPassword: 64}
+		srv, err := NewServer(cfg, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/openapi.json", s.URL))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+
+		var doc openapi.Document
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&doc))
+
+		t.Log("\ttest:0\tshould serve the document.")
+		{
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			assert.Equal(t, openapi.Version, doc.OpenAPI)
+		}
+
+		t.Log("\ttest:1\tshould describe registration responses.")
+		{
+			op := doc.Paths["/register"]["post"]
+			assert.NotNil(t, op)
+			assert.Equal(t, "#/components/schemas/RegisterRequest", op.RequestBody.Content["application/json"].Schema.Ref)
+			for _, code := range []string{"201", "422", "500"} {
+				assert.Contains(t, op.Responses, code)
+			}
+		}
+
+		t.Log("\ttest:2\tshould constrain email and password.")
+		{
+			req := doc.Components.Schemas["RegisterRequest"]
+			assert.Subset(t, req.Required, []string{"email", "password", "password_confirmation"})
+
+			email := req.Properties["email"]
+			assert.Equal(t, "string", email.Type)
+			assert.Equal(t, "email", email.Format)
+
+			password := req.Properties["password"]
+			assert.Equal(t, "string", password.Type)
+			assert.Equal(t, 8, *password.MinLength)
+			assert.Equal(t, 64, *password.MaxLength)
+		}
+
+		t.Log("\ttest:3\tshould leave the password out of the user schema.")
+		{
+			user := doc.Components.Schemas["UserResponse"]
+			assert.Contains(t, user.Properties, "email")
+			assert.NotContains(t, user.Properties, "password")
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/pkg/errors"
+)
+
+// PasswordHistory keeps previous password hashes of users.
+type PasswordHistory interface {
+	PasswordHistory(ctx context.Context, id string, limit int) ([]string, error)
+	AddPasswordHistory(ctx context.Context, id string, hash string) error
+}
+
+// keepsHistory reports whether reuse of previous passwords is checked.
+func (s *Service) keepsHistory() bool {
+	return s.History != nil && s.HistoryDepth > 0
+}
+
+// checkHistory rejects password of the user matching one of hashes or of
+// its previous password hashes.
+func (s *Service) checkHistory(ctx context.Context, id, password string, hashes ...string) error {
+	if !s.keepsHistory() {
+		return nil
+	}
+
+	previous, err := s.History.PasswordHistory(ctx, id, s.HistoryDepth)
+	if err != nil {
+		return errors.Wrap(err, "password history")
+	}
+
+	for _, hash := range append(hashes, previous...) {
+		if s.Hasher.Compare(hash, password) == nil {
+			return FieldErrors{{Field: "password", Rule: RuleReused, Message: constants.PasswordReused}}
+		}
+	}
+
+	return nil
+}
+
+// rememberPassword records the password hash the user is about to replace.
+// It is recorded first, so a failed replacement leaves only the current
+// hash in the history.
+func (s *Service) rememberPassword(ctx context.Context, id, hash string) error {
+	if !s.keepsHistory() {
+		return nil
+	}
+
+	if err := s.History.AddPasswordHistory(ctx, id, hash); err != nil {
+		return errors.Wrap(err, "add password history")
+	}
+
+	return nil
+}
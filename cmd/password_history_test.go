@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/newtondev/service_object/pkg/token"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordHistory(t *testing.T) {
+	t.Log("with history of two previous passwords.")
+	{
+		ctx := context.Background()
+		repo := testStorage()
+		u, err := repo.Create(ctx, testForm("history@domain.zone", "first"))
+		assert.Nil(t, err)
+
+		s := Service{
+			Validator:    noopValidator{},
+			Repository:   repo,
+			Hasher:       fakeHasher{},
+			Resets:       token.NewOneTime(&sequenceGenerator{}, time.Hour),
+			History:      repo,
+			HistoryDepth: 2,
+		}
+		reset := func(password string) error {
+			tok, err := s.RequestReset(ctx, "history@domain.zone")
+			assert.Nil(t, err)
+
+			return s.ConfirmReset(ctx, tok, password)
+		}
+		reused := FieldErrors{{Field: "password", Rule: RuleReused, Message: constants.PasswordReused}}
+
+		t.Log("\ttest:0\tshould reject reset to the current password keeping the token.")
+		{
+			tok, err := s.RequestReset(ctx, "history@domain.zone")
+			assert.Nil(t, err)
+
+			assert.Equal(t, reused, s.ConfirmReset(ctx, tok, "first"))
+			assert.Nil(t, s.ConfirmReset(ctx, tok, "second"))
+		}
+
+		t.Log("\ttest:1\tshould reject reset to a previous password.")
+		{
+			assert.Equal(t, reused, reset("first"))
+		}
+
+		t.Log("\ttest:2\tshould accept a genuinely new password.")
+		{
+			assert.Nil(t, reset("third"))
+
+			_, err := s.Authenticate(ctx, "history@domain.zone", "third")
+			assert.Nil(t, err)
+		}
+
+		t.Log("\ttest:3\tshould reject update to a previous password.")
+		{
+			f := testForm("history@domain.zone", "second")
+			_, err := s.Update(ctx, u.ID, f)
+			assert.Equal(t, reused, errors.Cause(err))
+		}
+
+		t.Log("\ttest:4\tshould accept update resubmitting the current password.")
+		{
+			f := testForm("history@domain.zone", "third")
+			f.FirstName = "Ada"
+			updated, err := s.Update(ctx, u.ID, f)
+			assert.Nil(t, err)
+			assert.Equal(t, "Ada", updated.FirstName)
+		}
+
+		t.Log("\ttest:5\tshould accept passwords older than the history.")
+		{
+			assert.Nil(t, reset("fourth"))
+			assert.Nil(t, reset("first"))
+		}
+	}
+}
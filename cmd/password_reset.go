@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/pkg/errors"
+)
+
+// DefaultResetTTL is a lifetime of password reset tokens.
+const DefaultResetTTL = time.Hour
+
+// PasswordResetter abstraction for password recovery.
+type PasswordResetter interface {
+	RequestReset(ctx context.Context, email string) (string, error)
+	ConfirmReset(ctx context.Context, token, password string) error
+}
+
+// ResetNotifier delivers password reset tokens to users.
+type ResetNotifier interface {
+	NotifyReset(ctx context.Context, u *entities.User, token string)
+}
+
+// RequestReset issues a reset token for user with the email and delivers it
+// by ResetNotifier when set. Missing users get an empty token and no error
+// to avoid user enumeration.
+func (s *Service) RequestReset(ctx context.Context, email string) (string, error) {
+	user, err := s.FindByEmail(ctx, entities.NormalizeEmail(email, s.LowercaseEmail))
+	if err != nil {
+		if errors.Is(err, svcerrors.ErrUserNotFound) {
+			return "", nil
+		}
+
+		return "", errors.Wrap(err, "repository find by email")
+	}
+
+	token, err := s.Resets.Issue(user.ID)
+	if err != nil {
+		return "", errors.Wrap(err, "resets issue")
+	}
+
+	if s.ResetNotifier != nil {
+		s.ResetNotifier.NotifyReset(ctx, user, token)
+	}
+
+	return token, nil
+}
+
+// ConfirmReset replaces password of the token user. Reused passwords are
+// rejected before the token is consumed, so it can be retried.
+func (s *Service) ConfirmReset(ctx context.Context, token, password string) error {
+	if err := s.Validator.ValidatePassword(ctx, password); err != nil {
+		return errors.Wrap(err, "validator validate password")
+	}
+
+	var current string
+	if s.keepsHistory() {
+		id, err := s.Resets.Peek(token)
+		if err != nil {
+			return err
+		}
+
+		user, err := s.FindByID(ctx, id)
+		if err != nil {
+			return errors.Wrap(err, "repository find by id")
+		}
+		current = user.Password
+
+		if err := s.checkHistory(ctx, id, password, current); err != nil {
+			return err
+		}
+	}
+
+	id, err := s.Resets.Consume(token)
+	if err != nil {
+		return err
+	}
+
+	hash, err := s.Hasher.Hash(password)
+	if err != nil {
+		return errors.Wrap(err, "hasher hash")
+	}
+
+	if err := s.rememberPassword(ctx, id, current); err != nil {
+		return err
+	}
+
+	if err := s.UpdatePassword(ctx, id, hash); err != nil {
+		return errors.Wrap(err, "repository update password")
+	}
+
+	return nil
+}
+
+// ResetRequest is a password reset request.
+type ResetRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetConfirmation is a password reset confirmation.
+type ResetConfirmation struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// RequestResetHandler for password reset requests.
+type RequestResetHandler struct {
+	PasswordResetter
+	// MaxBodyBytes limits request body size, DefaultMaxBodyBytes is used when zero.
+	MaxBodyBytes int64
+}
+
+// ServeHTTP implements http.Handler.
+func (h *RequestResetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req ResetRequest
+	if !decodeJSON(w, r, h.MaxBodyBytes, &req) {
+		return
+	}
+
+	if _, err := h.RequestReset(r.Context(), req.Email); err != nil {
+		encodeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmResetHandler for password reset confirmations.
+type ConfirmResetHandler struct {
+	PasswordResetter
+	// MaxBodyBytes limits request body size, DefaultMaxBodyBytes is used when zero.
+	MaxBodyBytes int64
+}
+
+// ServeHTTP implements http.Handler.
+func (h *ConfirmResetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var c ResetConfirmation
+	if !decodeJSON(w, r, h.MaxBodyBytes, &c) {
+		return
+	}
+
+	if err := h.ConfirmReset(r.Context(), c.Token, c.Password); err != nil {
+		encodeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/token"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+func TestPasswordReset(t *testing.T) {
+	t.Log("with service issuing reset tokens.")
+	{
+		ctx := context.Background()
+		repo := testStorage()
+		clock := &fakeClock{now: time.Now()}
+		resets := token.NewOneTime(&sequenceGenerator{}, DefaultResetTTL)
+		resets.Clock = clock
+		s := Service{Validator: noopValidator{}, Repository: repo, Hasher: fakeHasher{}, Resets: resets}
+
+		t.Log("\ttest:0\tshould not issue token for missing user.")
+		{
+			tok, err := s.RequestReset(ctx, "missing@domain.zone")
+			assert.Nil(t, err)
+			assert.Empty(t, tok)
+		}
+
+		tok, err := s.RequestReset(ctx, "exists@domain.zone")
+		assert.Nil(t, err)
+
+		t.Log("\ttest:1\tshould replace password with valid token.")
+		{
+			assert.Nil(t, s.ConfirmReset(ctx, tok, "newpass"))
+
+			u, err := s.Authenticate(ctx, "exists@domain.zone", "newpass")
+			assert.Nil(t, err)
+			assert.Equal(t, "1", u.ID)
+		}
+
+		t.Log("\ttest:2\tshould reject reused token.")
+		{
+			assert.Equal(t, svcerrors.ErrInvalidToken, s.ConfirmReset(ctx, tok, "other"))
+		}
+
+		t.Log("\ttest:3\tshould reject expired token.")
+		{
+			tok, err := s.RequestReset(ctx, "exists@domain.zone")
+			assert.Nil(t, err)
+			clock.Advance(DefaultResetTTL + time.Second)
+
+			assert.Equal(t, svcerrors.ErrTokenExpired, s.ConfirmReset(ctx, tok, "other"))
+		}
+
+		t.Log("\ttest:4\tshould validate new password.")
+		{
+			s.Validator = &PlayValidator{Validator: validator.New()}
+			tok, err := s.RequestReset(ctx, "exists@domain.zone")
+			assert.Nil(t, err)
+
+			err = s.ConfirmReset(ctx, tok, "x")
+			_, ok := errors.Cause(err).(FieldErrors)
+			assert.True(t, ok)
+		}
+	}
+}
+
+func TestRequestResetHandler(t *testing.T) {
+	t.Log("with handler emailing reset tokens.")
+	{
+		m := &fakeMailer{}
+		s := &Service{Validator: noopValidator{}, Repository: testStorage(), Hasher: fakeHasher{}, Resets: token.NewOneTime(&sequenceGenerator{}, DefaultResetTTL)}
+		rw := NewRegistratorWithWelcome(s, m, &fakeLogger{}, 1, 2)
+		s.ResetNotifier = rw
+		h := &RequestResetHandler{PasswordResetter: s}
+
+		request := func(email string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/password/reset", strings.NewReader(`{"email": "`+email+`"}`))
+			r.Header.Set("Content-Type", "application/json")
+			h.ServeHTTP(w, r)
+			return w
+		}
+
+		unknown := request("missing@domain.zone")
+		known := request("exists@domain.zone")
+		rw.Close()
+
+		t.Log("\ttest:0\tshould accept requests of known and unknown emails alike.")
+		{
+			assert.Equal(t, http.StatusAccepted, unknown.Code)
+			assert.Equal(t, http.StatusAccepted, known.Code)
+			assert.Equal(t, unknown.Body.String(), known.Body.String())
+		}
+
+		t.Log("\ttest:1\tshould email the token only to the known user.")
+		{
+			assert.Len(t, m.sent, 1)
+			assert.Equal(t, "exists@domain.zone", m.sent[0].to)
+			assert.Equal(t, ResetSubject, m.sent[0].subject)
+			assert.Contains(t, m.sent[0].body, "\n\ntoken-1\n\n")
+		}
+
+		t.Log("\ttest:2\tshould reset the password with the emailed token.")
+		{
+			assert.Nil(t, s.ConfirmReset(context.Background(), "token-1", "newpass"))
+
+			_, err := s.Authenticate(context.Background(), "exists@domain.zone", "newpass")
+			assert.Nil(t, err)
+		}
+	}
+
+	t.Log("with reset handlers limiting bodies to 64 bytes.")
+	{
+		s := &Service{Validator: noopValidator{}, Repository: testStorage(), Hasher: fakeHasher{}, Resets: token.NewOneTime(&sequenceGenerator{}, DefaultResetTTL)}
+		handlers := map[string]http.Handler{
+			"/password/reset":         &RequestResetHandler{PasswordResetter: s, MaxBodyBytes: 64},
+			"/password/reset/confirm": &ConfirmResetHandler{PasswordResetter: s, MaxBodyBytes: 64},
+		}
+		tests := []struct {
+			contentType string
+			body        string
+			status      int
+		}{
+			{contentType: "text/plain", body: `{"email": "exists@domain.zone"}`, status: http.StatusUnsupportedMediaType},
+			{contentType: "application/json", body: `{"email": "` + strings.Repeat("a", 64) + `@domain.zone"}`, status: http.StatusRequestEntityTooLarge},
+			{contentType: "application/json", body: `{"admin": true}`, status: http.StatusBadRequest},
+			{contentType: "application/json", body: ``, status: http.StatusBadRequest},
+		}
+
+		t.Log("\ttest:0\tshould reject bodies like other json handlers.")
+		{
+			for path, h := range handlers {
+				for _, tt := range tests {
+					w := httptest.NewRecorder()
+					r := httptest.NewRequest("POST", path, strings.NewReader(tt.body))
+					r.Header.Set("Content-Type", tt.contentType)
+					h.ServeHTTP(w, r)
+					assert.Equal(t, tt.status, w.Code, "%s %s", path, tt.body)
+				}
+			}
+		}
+	}
+
+	t.Log("with rate limited server.")
+	{
+		srv, err := NewServer(Config{RateLimit: 1, RateBurst: 2}, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+
+		post := func(path, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", path, strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			srv.Handler.ServeHTTP(w, r)
+			return w
+		}
+
+		t.Log("\ttest:0\tshould limit reset requests of a client.")
+		{
+			assert.Equal(t, http.StatusAccepted, post("/password/reset", `{"email": "exists@domain.zone"}`).Code)
+			assert.Equal(t, http.StatusAccepted, post("/password/reset", `{"email": "exists@domain.zone"}`).Code)
+			assert.Equal(t, http.StatusTooManyRequests, post("/password/reset", `{"email": "exists@domain.zone"}`).Code)
+		}
+
+		t.Log("\ttest:1\tshould limit reset confirmations of a client.")
+		{
+			assert.NotEqual(t, http.StatusTooManyRequests, post("/password/reset/confirm", `{"token": "guess-1", "password": "qwerty"}`).Code)
+			assert.NotEqual(t, http.StatusTooManyRequests, post("/password/reset/confirm", `{"token": "guess-2", "password": "qwerty"}`).Code)
+			assert.Equal(t, http.StatusTooManyRequests, post("/password/reset/confirm", `{"token": "guess-3", "password": "qwerty"}`).Code)
+		}
+	}
+}
+
+// fakeClock is a clock.Clock moved forward by tests.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+)
+
+// Switch pauses and resumes registrations at runtime.
+type Switch struct {
+	disabled atomic.Bool
+}
+
+// NewSwitch prepares switch with registrations enabled or disabled.
+func NewSwitch(enabled bool) *Switch {
+	s := &Switch{}
+	s.Set(enabled)
+
+	return s
+}
+
+// Enabled reports whether registrations are accepted, nil switch is always
+// enabled.
+func (s *Switch) Enabled() bool {
+	return s == nil || !s.disabled.Load()
+}
+
+// Set enables or disables registrations.
+func (s *Switch) Set(enabled bool) {
+	s.disabled.Store(!enabled)
+}
+
+// WithSwitch rejects requests with service unavailable while registrations
+// are disabled, so h and the repository behind it are not reached.
+func WithSwitch(h http.Handler, s *Switch) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Enabled() {
+			writeError(w, http.StatusServiceUnavailable, ErrorBody{Code: CodeRegistrationDisabled, Message: svcerrors.ErrRegistrationDisabled.Error()})
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// SwitchState is a state of the registration switch.
+type SwitchState struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// SwitchHandler reports the registration switch on GET and flips it on PUT.
+type SwitchHandler struct {
+	Switch *Switch
+}
+
+// ServeHTTP implements http.Handler.
+func (h *SwitchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		body, ok := readJSON(w, r, 0)
+		if !ok {
+			return
+		}
+
+		var state SwitchState
+		if err := json.Unmarshal(body, &state); err != nil {
+			writeError(w, http.StatusBadRequest, ErrorBody{Code: CodeInvalidJSON, Message: err.Error()})
+			return
+		}
+		if state.Enabled == nil {
+			writeError(w, http.StatusBadRequest, ErrorBody{Code: CodeInvalidJSON, Message: "enabled is required"})
+			return
+		}
+
+		h.Switch.Set(*state.Enabled)
+	}
+
+	enabled := h.Switch.Enabled()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SwitchState{Enabled: &enabled})
+}
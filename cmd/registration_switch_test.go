@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationSwitch(t *testing.T) {
+	t.Log("with server started with registrations disabled.")
+	{
+		repo := testStorage()
+		srv, err := NewServer(Config{JWTSecret: testSecret, RegistrationDisabled: true}, ioutil.Discard, repo, prometheus.NewRegistry())
+		assert.Nil(t, err)
+
+		form := `{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`
+		serve := func(r *http.Request, auth string) *httptest.ResponseRecorder {
+			if auth != "" {
+				r.Header.Set("Authorization", auth)
+			}
+
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, r)
+			return w
+		}
+
+		t.Log("\ttest:0\tshould reject registrations without touching the store.")
+		{
+			w := serve(jsonRequest("POST", "/register", form), "")
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, CodeRegistrationDisabled, e.Error.Code)
+			assert.Equal(t, "registrations are disabled", e.Error.Message)
+
+			w = serve(jsonRequest("POST", "/register/batch", "["+form+"]"), "")
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.Len(t, repo.Users, 1)
+		}
+
+		t.Log("\ttest:1\tshould let only admins flip the switch.")
+		{
+			w := serve(jsonRequest("PUT", "/admin/registration", `{"enabled": true}`), bearer(t, entities.RoleUser))
+			assert.Equal(t, http.StatusForbidden, w.Code)
+
+			w = serve(jsonRequest("PUT", "/admin/registration", `{}`), bearer(t, entities.RoleAdmin))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			w = serve(jsonRequest("GET", "/admin/registration", ""), bearer(t, entities.RoleAdmin))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"enabled": false}`, w.Body.String())
+		}
+
+		t.Log("\ttest:2\tshould accept registrations once enabled.")
+		{
+			w := serve(jsonRequest("PUT", "/admin/registration", `{"enabled": true}`), bearer(t, entities.RoleAdmin))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"enabled": true}`, w.Body.String())
+
+			w = serve(jsonRequest("POST", "/register", form), "")
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.Len(t, repo.Users, 2)
+		}
+
+		t.Log("\ttest:3\tshould reject registrations once disabled again.")
+		{
+			w := serve(jsonRequest("PUT", "/admin/registration", `{"enabled": false}`), bearer(t, entities.RoleAdmin))
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			w = serve(jsonRequest("POST", "/register", `{"email": "other@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`), "")
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.Len(t, repo.Users, 2)
+		}
+	}
+}
@@ -1,50 +1,85 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/stretchr/testify/assert"
-	"github.com/newtondev/service_object/pkg/storage"
+	"github.com/newtondev/service_object/pkg/constants"
 	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/i18n"
+	"github.com/newtondev/service_object/pkg/storage"
+	"github.com/newtondev/service_object/pkg/token"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
 )
 
+var testSecret = []byte("secret")
+
+// testNow is the time of the clock used by testStorage.
+var testNow = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
 func TestRegistration(t *testing.T) {
 	t.Log("with initialized server.")
 	{
-		s := NewServer("127.0.0.1:8080", ioutil.Discard, testStorage())
-		go func() {
-			assert.Nil(t, s.ListenAndServe())
-		}()
+		repo := testStorage()
+		s, err := NewServer(Config{Addr: "127.0.0.1:8080", JWTSecret: testSecret}, ioutil.Discard, repo, prometheus.NewRegistry())
+		assert.Nil(t, err)
+		l, err := net.Listen("tcp", s.Addr)
+		assert.Nil(t, err)
+		go s.Serve(l)
 		defer s.Close()
 
 		t.Log("\ttest:0\tshould return bad request if the body is invalid.")
 		{
 			req, err := http.NewRequest("POST", fmt.Sprintf("http://%s/register", s.Addr), strings.NewReader("invalid"))
 			assert.Nil(t, err)
+			req.Header.Set("Content-Type", "application/json")
 
 			resp, err := http.DefaultClient.Do(req)
 			assert.Nil(t, err)
 			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&e))
+			assert.Equal(t, CodeInvalidJSON, e.Error.Code)
 		}
 
 		t.Log("\ttest:1\tshould register user with valid body.")
 		{
 			req, err := http.NewRequest("POST", fmt.Sprintf("http://%s/register", s.Addr), strings.NewReader(`{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`))
 			assert.Nil(t, err)
+			req.Header.Set("Content-Type", "application/json")
 
 			resp, err := http.DefaultClient.Do(req)
 			assert.Nil(t, err)
-			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+			var u map[string]interface{}
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&u))
+			assert.Equal(t, "new@domain.zone", u["email"])
+			assert.Equal(t, fmt.Sprintf("/users/%v", u["id"]), resp.Header.Get("Location"))
+			assert.Equal(t, "/users/2", resp.Header.Get("Location"))
+			assert.NotContains(t, u, "password")
+			assert.Equal(t, "hashed:qwerty", repo.Users[1].Password)
+
+			c, err := token.NewJWT(testSecret, 0).Parse(strings.TrimPrefix(resp.Header.Get("Authorization"), "Bearer "))
+			assert.Nil(t, err)
+			assert.Equal(t, "new@domain.zone", c.Email)
 		}
 
 		t.Log("\ttest:2\tshould validate email uniqueness.")
 		{
 			req, err := http.NewRequest("POST", fmt.Sprintf("http://%s/register", s.Addr), strings.NewReader(`{"email":"exists@domain.zone"}`))
 			assert.Nil(t, err)
+			req.Header.Set("Content-Type", "application/json")
 
 			resp, err := http.DefaultClient.Do(req)
 			assert.Nil(t, err)
@@ -55,34 +90,298 @@ func TestRegistration(t *testing.T) {
 		{
 			req, err := http.NewRequest("POST", fmt.Sprintf("http://%s/register", s.Addr), strings.NewReader(`{"email":"new@domain.zone", "password": "qwerty", "password_confirmation": "other"}`))
 			assert.Nil(t, err)
+			req.Header.Set("Content-Type", "application/json")
 
 			resp, err := http.DefaultClient.Do(req)
 			assert.Nil(t, err)
 			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&e))
+			assert.Equal(t, CodeValidationFailed, e.Error.Code)
+			assert.Equal(t, constants.PasswordMismatch, e.Error.Fields["password"])
 		}
 
 		t.Log("\ttest:4\tshould validate email.")
 		{
 			req, err := http.NewRequest("POST", fmt.Sprintf("http://%s/register", s.Addr), strings.NewReader(`{"email":"invalid", "password": "qwerty"}`))
 			assert.Nil(t, err)
+			req.Header.Set("Content-Type", "application/json")
 
 			resp, err := http.DefaultClient.Do(req)
 			assert.Nil(t, err)
 			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
 		}
+
+		t.Log("\ttest:5\tshould validate uniqueness of normalized email.")
+		{
+			req, err := http.NewRequest("POST", fmt.Sprintf("http://%s/register", s.Addr), strings.NewReader(`{"email":" exists@DOMAIN.zone ", "password": "qwerty", "password_confirmation": "qwerty"}`))
+			assert.Nil(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+
+		t.Log("\ttest:6\tshould expose registration metrics.")
+		{
+			resp, err := http.Get(fmt.Sprintf("http://%s/metrics", s.Addr))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.Nil(t, err)
+			assert.Contains(t, string(body), "registration_attempts_total 5")
+		}
 	}
 }
 
+func TestNewService(t *testing.T) {
+	t.Log("with service dependencies.")
+	{
+		v, repo, h := noopValidator{}, testStorage(), fakeHasher{}
+
+		t.Log("\ttest:0\tshould prepare service with every dependency.")
+		{
+			s, err := NewService(v, repo, h)
+			assert.Nil(t, err)
+			assert.Equal(t, repo, s.Repository)
+		}
+
+		t.Log("\ttest:1\tshould reject missing dependencies.")
+		{
+			_, err := NewService(nil, repo, h)
+			assert.EqualError(t, err, "service validator is required")
+
+			_, err = NewService(v, nil, h)
+			assert.EqualError(t, err, "service repository is required")
+
+			_, err = NewService(v, repo, nil)
+			assert.EqualError(t, err, "service hasher is required")
+		}
+
+		t.Log("\ttest:2\tshould fail server without repository instead of panicking.")
+		{
+			_, err := NewServer(Config{}, ioutil.Discard, nil, prometheus.NewRegistry())
+			assert.NotNil(t, err)
+		}
+	}
+}
+
+func TestRegistrationUnknownFields(t *testing.T) {
+	t.Log("with registration handler.")
+	{
+		h := RegistrationHandler{Registrator: fakeRegistrator{}}
+
+		t.Log("\ttest:0\tshould reject unknown field naming it.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register", `{"email": "a@domain.zone", "passwrod": "qwerty"}`))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, CodeUnknownField, e.Error.Code)
+			assert.Contains(t, e.Error.Message, `"passwrod"`)
+		}
+
+		t.Log("\ttest:1\tshould accept body with known fields.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register", `{"email": "a@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`))
+			assert.Equal(t, http.StatusCreated, w.Code)
+		}
+	}
+}
+
+func TestRegistrationBodyLimit(t *testing.T) {
+	t.Log("with limited registration handler.")
+	{
+		h := RegistrationHandler{Registrator: fakeRegistrator{}, MaxBodyBytes: 64}
+
+		t.Log("\ttest:0\tshould reject body over the limit.")
+		{
+			body := fmt.Sprintf(`{"email": "%s@domain.zone"}`, strings.Repeat("a", 64))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register", body))
+			assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, CodeBodyTooLarge, e.Error.Code)
+		}
+
+		t.Log("\ttest:1\tshould accept body within the limit.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register", `{"email": "a@domain.zone"}`))
+			assert.Equal(t, http.StatusCreated, w.Code)
+		}
+	}
+}
+
+func TestRegistrationDecodeErrors(t *testing.T) {
+	t.Log("with registration handler.")
+	{
+		h := RegistrationHandler{Registrator: fakeRegistrator{}}
+
+		tests := []struct {
+			name    string
+			body    string
+			code    string
+			message string
+		}{
+			{name: "empty body", body: "", code: CodeEmptyBody, message: "request body is empty"},
+			{name: "syntax error", body: `{"email": x}`, code: CodeInvalidJSON, message: "malformed JSON at byte 11"},
+			{name: "truncated body", body: `{"email": "a@domain.zone"`, code: CodeInvalidJSON, message: "malformed JSON, body ends unexpectedly"},
+			{name: "field type", body: `{"email": 42}`, code: CodeInvalidType, message: "email must be string, got number"},
+			{name: "body type", body: `["a@domain.zone"]`, code: CodeInvalidType, message: "body must be object, got array"},
+			{name: "unknown field", body: `{"emial": "a@domain.zone"}`, code: CodeUnknownField, message: `unknown field "emial"`},
+		}
+
+		for i, tt := range tests {
+			t.Logf("\ttest:%d\tshould describe %s.", i, tt.name)
+			{
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, jsonRequest("POST", "/register", tt.body))
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+
+				var e ErrorResponse
+				assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+				assert.Equal(t, tt.code, e.Error.Code)
+				assert.Equal(t, tt.message, e.Error.Message)
+			}
+		}
+	}
+}
+
+func TestRegistrationLocalization(t *testing.T) {
+	t.Log("with registration handler translating to german.")
+	{
+		tr := i18n.NewTranslator()
+		assert.Nil(t, tr.Register("de", i18n.Catalog{
+			constants.PasswordLength: "Passwort muss zwischen %v und %v Zeichen lang sein",
+		}))
+		h := RegistrationHandler{
+			Registrator: fakeRegistrator{err: ValidationErrors{"password": i18n.Message(constants.PasswordLength, 3, 16)}},
+			Translator:  tr,
+		}
+		body := `{"email": "a@domain.zone", "password": "qw", "password_confirmation": "qw"}`
+
+		tests := []struct {
+			language string
+			message  string
+		}{
+			{"en-US,en;q=0.9", "password must be between 3 and 16 characters"},
+			{"de-DE,de;q=0.9", "Passwort muss zwischen 3 und 16 Zeichen lang sein"},
+			{"", "password must be between 3 and 16 characters"},
+		}
+
+		for i, tt := range tests {
+			t.Logf("\ttest:%d\tshould translate validation message for %q.", i, tt.language)
+			{
+				r := jsonRequest("POST", "/register", body)
+				r.Header.Set("Accept-Language", tt.language)
+
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+				var e ErrorResponse
+				assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+				assert.Equal(t, tt.message, e.Error.Fields["password"])
+			}
+		}
+	}
+}
+
+// jsonRequest prepares request with JSON body for handler tests.
+func jsonRequest(method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
 func testStorage() *storage.MemStore {
 	repo := storage.MemStore{
 		Users: []entities.User{
 			entities.User{
-				ID:       1,
+				ID:       "1",
 				Email:    "exists@domain.zone",
 				Password: "qwerty",
 			},
 		},
+		Hasher: fakeHasher{},
+		Clock:  &fakeClock{now: testNow},
+		IDs:    &storage.SequentialGenerator{Last: 1},
 	}
 
 	return &repo
 }
+
+func testForm(email, password string) *entities.Form {
+	return &entities.Form{
+		Email:                email,
+		Password:             password,
+		PasswordConfirmation: password,
+	}
+}
+
+// fakeHasher is a cheap PasswordHasher for tests.
+type fakeHasher struct{}
+
+func (fakeHasher) Hash(password string) (string, error) {
+	return "hashed:" + password, nil
+}
+
+func (fakeHasher) Compare(hash, password string) error {
+	if hash != "hashed:"+password {
+		return errors.New("password mismatch")
+	}
+
+	return nil
+}
+
+func TestRegistrationContentType(t *testing.T) {
+	t.Log("with registration handler.")
+	{
+		h := RegistrationHandler{Registrator: fakeRegistrator{}}
+		body := `{"email": "a@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`
+
+		t.Log("\ttest:0\tshould accept json with and without charset.")
+		{
+			for _, ct := range []string{"application/json", "application/json; charset=UTF-8"} {
+				r := httptest.NewRequest("POST", "/register", strings.NewReader(body))
+				r.Header.Set("Content-Type", ct)
+
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				assert.Equal(t, http.StatusCreated, w.Code, ct)
+			}
+		}
+
+		t.Log("\ttest:1\tshould reject missing content type.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/register", strings.NewReader(body)))
+			assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, CodeUnsupportedMediaType, e.Error.Code)
+		}
+
+		t.Log("\ttest:2\tshould reject unsupported content type.")
+		{
+			for _, ct := range []string{"application/x-www-form-urlencoded", "text/plain", "application/json; charset=latin1"} {
+				r := httptest.NewRequest("POST", "/register", strings.NewReader(body))
+				r.Header.Set("Content-Type", ct)
+
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, ct)
+			}
+		}
+	}
+}
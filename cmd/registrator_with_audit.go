@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/audit"
+	"github.com/newtondev/service_object/pkg/entities"
+)
+
+// AuditLogger records registration attempts to an append-only trail.
+type AuditLogger interface {
+	Record(ctx context.Context, e audit.Entry) error
+}
+
+// RegistratorWithAudit implements Registrator that records every attempt with AuditLogger
+type RegistratorWithAudit struct {
+	base   Registrator
+	logger AuditLogger
+}
+
+// NewRegistratorWithAudit records attempts of the base Registrator with logger
+func NewRegistratorWithAudit(base Registrator, logger AuditLogger) RegistratorWithAudit {
+	return RegistratorWithAudit{
+		base:   base,
+		logger: logger,
+	}
+}
+
+// Register implements Registrator
+func (ra RegistratorWithAudit) Register(ctx context.Context, f *entities.Form) (u *entities.User, err error) {
+	defer func() {
+		e := audit.Entry{
+			Time:     time.Now().UTC(),
+			Email:    f.Email,
+			Outcome:  audit.Success,
+			SourceIP: SourceIP(ctx),
+		}
+		if err != nil {
+			e.Outcome = audit.Failure
+			e.Error = err.Error()
+		}
+
+		// the attempt already happened, a failing trail must not change its result.
+		ra.logger.Record(ctx, e)
+	}()
+	return ra.base.Register(ctx, f)
+}
+
+type sourceIPKey struct{}
+
+// WithSourceIP returns ctx carrying IP address of the client.
+func WithSourceIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, sourceIPKey{}, ip)
+}
+
+// SourceIP returns IP address of the client carried by ctx.
+func SourceIP(ctx context.Context) string {
+	ip, _ := ctx.Value(sourceIPKey{}).(string)
+	return ip
+}
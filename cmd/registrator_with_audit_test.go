@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistratorWithAudit(t *testing.T) {
+	t.Log("with audited registrator.")
+	{
+		trail := audit.NewMemory()
+		base := &fakeRegistrator{}
+		ra := NewRegistratorWithAudit(base, trail)
+		ctx := WithSourceIP(context.Background(), "10.0.0.1")
+
+		t.Log("\ttest:0\tshould record successful attempt.")
+		{
+			_, err := ra.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+
+			entries := trail.Entries()
+			assert.Len(t, entries, 1)
+			assert.Equal(t, "new@domain.zone", entries[0].Email)
+			assert.Equal(t, audit.Success, entries[0].Outcome)
+			assert.Equal(t, "10.0.0.1", entries[0].SourceIP)
+			assert.Empty(t, entries[0].Error)
+			assert.False(t, entries[0].Time.IsZero())
+		}
+
+		t.Log("\ttest:1\tshould record failed attempt with the error.")
+		{
+			base.err = errors.New("boom")
+
+			_, err := ra.Register(ctx, testForm("other@domain.zone", "qwerty"))
+			assert.NotNil(t, err)
+
+			entries := trail.Entries()
+			assert.Len(t, entries, 2)
+			assert.Equal(t, "other@domain.zone", entries[1].Email)
+			assert.Equal(t, audit.Failure, entries[1].Outcome)
+			assert.Equal(t, "boom", entries[1].Error)
+			assert.Equal(t, "10.0.0.1", entries[1].SourceIP)
+		}
+	}
+
+	t.Log("with audited registration handler.")
+	{
+		trail := audit.NewMemory()
+		h := RegistrationHandler{Registrator: NewRegistratorWithAudit(fakeRegistrator{}, trail)}
+
+		t.Log("\ttest:0\tshould record source IP of the request.")
+		{
+			req := jsonRequest("POST", "/register", `{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`)
+			req.RemoteAddr = "192.0.2.7:4321"
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusCreated, w.Code)
+
+			entries := trail.Entries()
+			assert.Len(t, entries, 1)
+			assert.Equal(t, "192.0.2.7", entries[0].SourceIP)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/events"
+)
+
+// EventPublisher publishes domain events to a message bus.
+type EventPublisher interface {
+	Publish(ctx context.Context, e events.Event) error
+}
+
+// RegistratorWithEvents implements Registrator that publishes UserRegistered after successful registrations
+type RegistratorWithEvents struct {
+	base      Registrator
+	publisher EventPublisher
+	logger    Logger
+}
+
+// NewRegistratorWithEvents publishes registrations of the base Registrator with publisher, failures are logged with logger
+func NewRegistratorWithEvents(base Registrator, publisher EventPublisher, logger Logger) RegistratorWithEvents {
+	return RegistratorWithEvents{
+		base:      base,
+		publisher: publisher,
+		logger:    logger,
+	}
+}
+
+// Register implements Registrator
+func (re RegistratorWithEvents) Register(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	u, err := re.base.Register(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	e := events.UserRegistered{UserID: u.ID, Email: u.Email, Time: time.Now().UTC()}
+	// the user is already registered, a failing bus must not change the result.
+	if err := re.publisher.Publish(ctx, e); err != nil {
+		re.logger.Error("RegistratorWithEvents: Publish failed", append([]interface{}{"event", e.Type(), "user_id", u.ID, "error", err.Error()}, LogContext.Fields(ctx)...)...)
+	}
+
+	return u, nil
+}
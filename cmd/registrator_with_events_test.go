@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistratorWithEvents(t *testing.T) {
+	t.Log("with registrator publishing events.")
+	{
+		f := testForm("new@domain.zone", "s3cr3t-value")
+
+		t.Log("\ttest:0\tshould publish registered user without the password.")
+		{
+			bus := events.NewMemory()
+			u, err := NewRegistratorWithEvents(fakeRegistrator{}, bus, &fakeLogger{}).Register(context.Background(), f)
+			assert.Nil(t, err)
+
+			published := bus.Events()
+			assert.Len(t, published, 1)
+			e, ok := published[0].(events.UserRegistered)
+			assert.True(t, ok)
+			assert.Equal(t, u.ID, e.UserID)
+			assert.Equal(t, "new@domain.zone", e.Email)
+			assert.False(t, e.Time.IsZero())
+		}
+
+		t.Log("\ttest:1\tshould not publish failed registrations.")
+		{
+			bus := events.NewMemory()
+			_, err := NewRegistratorWithEvents(fakeRegistrator{err: errors.New("boom")}, bus, &fakeLogger{}).Register(context.Background(), f)
+			assert.NotNil(t, err)
+			assert.Empty(t, bus.Events())
+		}
+
+		t.Log("\ttest:2\tshould log publishing failures keeping the registration.")
+		{
+			l := &fakeLogger{}
+			u, err := NewRegistratorWithEvents(fakeRegistrator{}, failingPublisher{}, l).Register(context.Background(), f)
+			assert.Nil(t, err)
+			assert.NotNil(t, u)
+
+			assert.Len(t, l.errors, 1)
+			assert.Equal(t, events.TypeUserRegistered, l.errors[0]["event"])
+			assert.Equal(t, "bus unavailable", l.errors[0]["error"])
+		}
+	}
+}
+
+// failingPublisher fails every publish.
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(ctx context.Context, e events.Event) error {
+	return errors.New("bus unavailable")
+}
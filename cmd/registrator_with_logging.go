@@ -2,38 +2,110 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"time"
 
 	"github.com/newtondev/service_object/pkg/entities"
 )
 
+// Logger writes log lines of a message with name and value pairs,
+// *slog.Logger implements it.
+type Logger interface {
+	Info(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// StdLogger implements Logger on top of the standard logger writing
+// name and value pairs as "name:" value.
+type StdLogger struct {
+	Out, Err *log.Logger
+}
+
+// NewStdLogger returns Logger writing info lines to stdout and errors to stderr.
+func NewStdLogger(stdout, stderr io.Writer) StdLogger {
+	return StdLogger{
+		Out: log.New(stdout, "", log.LstdFlags),
+		Err: log.New(stderr, "", log.LstdFlags),
+	}
+}
+
+// Info implements Logger
+func (l StdLogger) Info(msg string, keyvals ...interface{}) {
+	l.Out.Println(append([]interface{}{msg}, plainFields(keyvals)...)...)
+}
+
+// Error implements Logger
+func (l StdLogger) Error(msg string, keyvals ...interface{}) {
+	l.Err.Println(append([]interface{}{msg}, plainFields(keyvals)...)...)
+}
+
+// jsonLogger implements Logger emitting JSON lines, errors go to a separate output.
+type jsonLogger struct {
+	out, err *slog.Logger
+}
+
+func (l jsonLogger) Info(msg string, keyvals ...interface{}) {
+	l.out.Info(msg, keyvals...)
+}
+
+func (l jsonLogger) Error(msg string, keyvals ...interface{}) {
+	l.err.Error(msg, keyvals...)
+}
+
 // RegistratorWithLog implements Registrator that is instrumented with logging
 type RegistratorWithLog struct {
-	stdlog, errlog *log.Logger
-	base           Registrator
+	logger Logger
+	base   Registrator
+}
+
+// NewRegistratorWithLog instruments an implementation of the Registrator with simple logging.
+// When structured is set log lines are emitted as JSON objects instead of plain text.
+func NewRegistratorWithLog(base Registrator, stdout, stderr io.Writer, structured bool) RegistratorWithLog {
+	if structured {
+		return NewRegistratorWithLogger(base, jsonLogger{
+			out: slog.New(slog.NewJSONHandler(stdout, nil)),
+			err: slog.New(slog.NewJSONHandler(stderr, nil)),
+		})
+	}
+
+	return NewRegistratorWithLogger(base, NewStdLogger(stdout, stderr))
 }
 
-// NewRegistratorWithLog instruments an implementation of the Registrator with simple logging
-func NewRegistratorWithLog(base Registrator, stdout, stderr io.Writer) RegistratorWithLog {
+// NewRegistratorWithLogger instruments an implementation of the Registrator with logging through logger.
+func NewRegistratorWithLogger(base Registrator, logger Logger) RegistratorWithLog {
 	return RegistratorWithLog{
 		base:   base,
-		stdlog: log.New(stdout, "", log.LstdFlags),
-		errlog: log.New(stderr, "", log.LstdFlags),
+		logger: logger,
 	}
 }
 
-// Register implements Registrator
+// Register implements Registrator, the form is logged by its email only so
+// passwords never reach the logs.
 func (rl RegistratorWithLog) Register(ctx context.Context, f *entities.Form) (u *entities.User, err error) {
-	params := []interface{}{"RegistratorWithLog: calling Register with params:", ctx, f}
-	rl.stdlog.Println(params...)
+	fields := append([]interface{}{"email", f.Email}, LogContext.Fields(ctx)...)
+	rl.logger.Info("RegistratorWithLog: calling Register", append([]interface{}{"event", "register_called"}, fields...)...)
+	start := time.Now()
 	defer func() {
-		results := []interface{}{"RegistratorWithLog: Register return results:", u, err}
+		fields := append([]interface{}{"event", "register_returned"}, fields...)
 		if err != nil {
-			rl.errlog.Println(results...)
-		} else {
-			rl.stdlog.Println(results...)
+			rl.logger.Error("RegistratorWithLog: Register failed", append(fields, "error", err.Error(), "duration", time.Since(start))...)
+		} else if u != nil {
+			// u is missing only when the base panics, the panic is logged by recovery.
+			rl.logger.Info("RegistratorWithLog: Register succeeded", append(fields, "user_id", u.ID, "duration", time.Since(start))...)
 		}
 	}()
 	return rl.base.Register(ctx, f)
 }
+
+// plainFields formats name and value pairs as "name:" value for plain logs.
+func plainFields(fields []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(fields))
+	for i := 0; i+1 < len(fields); i += 2 {
+		out = append(out, fmt.Sprintf("%s:", fields[i]), fields[i+1])
+	}
+
+	return out
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistratorWithLog(t *testing.T) {
+	t.Log("with instrumented registrator.")
+	{
+		f := testForm("new@domain.zone", "s3cr3t-value")
+
+		t.Log("\ttest:0\tshould never log the password in plain mode.")
+		{
+			var stdout, stderr bytes.Buffer
+			rl := NewRegistratorWithLog(fakeRegistrator{}, &stdout, &stderr, false)
+
+			_, err := rl.Register(context.Background(), f)
+			assert.Nil(t, err)
+			assert.Contains(t, stdout.String(), "new@domain.zone")
+			assert.NotContains(t, stdout.String()+stderr.String(), "s3cr3t-value")
+		}
+
+		t.Log("\ttest:1\tshould emit JSON lines without the password in structured mode.")
+		{
+			var stdout, stderr bytes.Buffer
+			rl := NewRegistratorWithLog(fakeRegistrator{}, &stdout, &stderr, true)
+
+			_, err := rl.Register(context.Background(), f)
+			assert.Nil(t, err)
+			assert.NotContains(t, stdout.String()+stderr.String(), "s3cr3t-value")
+
+			lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			assert.Len(t, lines, 2)
+
+			var entry map[string]interface{}
+			assert.Nil(t, json.Unmarshal([]byte(lines[1]), &entry))
+			assert.Equal(t, "register_returned", entry["event"])
+			assert.Equal(t, "new@domain.zone", entry["email"])
+			assert.Contains(t, entry, "duration")
+		}
+
+		t.Log("\ttest:2\tshould log failures to the error output in structured mode.")
+		{
+			var stdout, stderr bytes.Buffer
+			rl := NewRegistratorWithLog(fakeRegistrator{err: errors.New("boom")}, &stdout, &stderr, true)
+
+			_, err := rl.Register(context.Background(), f)
+			assert.NotNil(t, err)
+			assert.Contains(t, stderr.String(), `"error":"boom"`)
+			assert.NotContains(t, stdout.String()+stderr.String(), "s3cr3t-value")
+		}
+
+		t.Log("\ttest:3\tshould record duration of the call.")
+		{
+			var stdout, stderr bytes.Buffer
+			rl := NewRegistratorWithLog(fakeRegistrator{delay: 10 * time.Millisecond}, &stdout, &stderr, true)
+
+			_, err := rl.Register(context.Background(), f)
+			assert.Nil(t, err)
+
+			lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			var entry map[string]interface{}
+			assert.Nil(t, json.Unmarshal([]byte(lines[len(lines)-1]), &entry))
+			assert.True(t, time.Duration(entry["duration"].(float64)) >= 10*time.Millisecond)
+		}
+
+		t.Log("\ttest:4\tshould record duration of the failed call in plain mode.")
+		{
+			var stdout, stderr bytes.Buffer
+			rl := NewRegistratorWithLog(fakeRegistrator{err: errors.New("boom"), delay: 10 * time.Millisecond}, &stdout, &stderr, false)
+
+			_, err := rl.Register(context.Background(), f)
+			assert.NotNil(t, err)
+
+			i := strings.Index(stderr.String(), "duration: ")
+			assert.True(t, i > 0)
+			d, err := time.ParseDuration(strings.TrimSpace(stderr.String()[i+len("duration: "):]))
+			assert.Nil(t, err)
+			assert.True(t, d >= 10*time.Millisecond)
+		}
+	}
+}
+
+// fakeRegistrator returns the configured error or a user for the form
+// after the configured delay.
+type fakeRegistrator struct {
+	err   error
+	delay time.Duration
+}
+
+func (r fakeRegistrator) Register(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	time.Sleep(r.delay)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return &entities.User{ID: "1", Email: f.Email, Password: f.Password}, nil
+}
+
+func TestRegistratorWithLogger(t *testing.T) {
+	t.Log("with registrator logging through a custom logger.")
+	{
+		f := testForm("new@domain.zone", "s3cr3t-value")
+
+		t.Log("\ttest:0\tshould log calls with name and value pairs.")
+		{
+			l := &fakeLogger{}
+			_, err := NewRegistratorWithLogger(fakeRegistrator{}, l).Register(context.Background(), f)
+			assert.Nil(t, err)
+
+			assert.Len(t, l.infos, 2)
+			assert.Empty(t, l.errors)
+			assert.Equal(t, "register_called", l.infos[0]["event"])
+			assert.Equal(t, "new@domain.zone", l.infos[0]["email"])
+			assert.Equal(t, "register_returned", l.infos[1]["event"])
+			assert.Contains(t, l.infos[1], "user_id")
+			assert.Contains(t, l.infos[1], "duration")
+		}
+
+		t.Log("\ttest:1\tshould log failures as errors.")
+		{
+			l := &fakeLogger{}
+			_, err := NewRegistratorWithLogger(fakeRegistrator{err: errors.New("boom")}, l).Register(context.Background(), f)
+			assert.NotNil(t, err)
+
+			assert.Len(t, l.infos, 1)
+			assert.Len(t, l.errors, 1)
+			assert.Equal(t, "boom", l.errors[0]["error"])
+		}
+
+		t.Log("\ttest:2\tshould never pass the password to the logger.")
+		{
+			l := &fakeLogger{}
+			NewRegistratorWithLogger(fakeRegistrator{}, l).Register(context.Background(), f)
+
+			for _, fields := range append(l.infos, l.errors...) {
+				for _, v := range fields {
+					assert.NotEqual(t, "s3cr3t-value", v)
+				}
+			}
+		}
+
+		t.Log("\ttest:3\tshould accept slog loggers.")
+		{
+			var out bytes.Buffer
+			_, err := NewRegistratorWithLogger(fakeRegistrator{}, slog.New(slog.NewJSONHandler(&out, nil))).Register(context.Background(), f)
+			assert.Nil(t, err)
+			assert.Contains(t, out.String(), `"event":"register_returned"`)
+		}
+	}
+}
+
+// fakeLogger captures fields of logged lines.
+type fakeLogger struct {
+	infos, errors []map[string]interface{}
+}
+
+func (l *fakeLogger) Info(msg string, keyvals ...interface{}) {
+	l.infos = append(l.infos, fields(keyvals))
+}
+
+func (l *fakeLogger) Error(msg string, keyvals ...interface{}) {
+	l.errors = append(l.errors, fields(keyvals))
+}
+
+// fields maps name and value pairs.
+func fields(keyvals []interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		m[keyvals[i].(string)] = keyvals[i+1]
+	}
+
+	return m
+}
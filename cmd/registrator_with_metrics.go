@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegistratorWithMetrics implements Registrator that is instrumented with prometheus metrics
+type RegistratorWithMetrics struct {
+	attempts *prometheus.CounterVec
+	results  *prometheus.CounterVec
+	duration prometheus.Histogram
+	base     Registrator
+}
+
+// NewRegistratorWithMetrics instruments an implementation of the Registrator with metrics
+// registered in reg.
+func NewRegistratorWithMetrics(base Registrator, reg prometheus.Registerer) (RegistratorWithMetrics, error) {
+	rm := RegistratorWithMetrics{
+		base: base,
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "registration_attempts_total",
+			Help: "Total number of registration attempts.",
+		}, nil),
+		results: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "registration_results_total",
+			Help: "Total number of registrations by result.",
+		}, []string{"result"}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "registration_duration_seconds",
+			Help:    "Registration latency in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+
+	for _, c := range []prometheus.Collector{rm.attempts, rm.results, rm.duration} {
+		if err := reg.Register(c); err != nil {
+			return RegistratorWithMetrics{}, err
+		}
+	}
+
+	return rm, nil
+}
+
+// Register implements Registrator
+func (rm RegistratorWithMetrics) Register(ctx context.Context, f *entities.Form) (u *entities.User, err error) {
+	rm.attempts.WithLabelValues().Inc()
+	start := time.Now()
+	defer func() {
+		rm.duration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			rm.results.WithLabelValues("failure").Inc()
+		} else {
+			rm.results.WithLabelValues("success").Inc()
+		}
+	}()
+	return rm.base.Register(ctx, f)
+}
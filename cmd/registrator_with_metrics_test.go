@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistratorWithMetrics(t *testing.T) {
+	t.Log("with instrumented registrator.")
+	{
+		reg := prometheus.NewRegistry()
+		base := &fakeRegistrator{}
+		rm, err := NewRegistratorWithMetrics(base, reg)
+		assert.Nil(t, err)
+
+		t.Log("\ttest:0\tshould count successful and failed attempts.")
+		{
+			_, err := rm.Register(context.Background(), testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+
+			base.err = errors.New("boom")
+			_, err = rm.Register(context.Background(), testForm("new@domain.zone", "qwerty"))
+			assert.NotNil(t, err)
+
+			assert.Equal(t, float64(2), testutil.ToFloat64(rm.attempts))
+			assert.Equal(t, float64(1), testutil.ToFloat64(rm.results.WithLabelValues("success")))
+			assert.Equal(t, float64(1), testutil.ToFloat64(rm.results.WithLabelValues("failure")))
+			assert.Equal(t, 1, testutil.CollectAndCount(rm.duration))
+		}
+
+		t.Log("\ttest:1\tshould fail to register collectors twice.")
+		{
+			_, err := NewRegistratorWithMetrics(base, reg)
+			assert.NotNil(t, err)
+		}
+	}
+}
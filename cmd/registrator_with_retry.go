@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/hasher"
+	"github.com/pkg/errors"
+)
+
+// EmailFinder finds users by email.
+type EmailFinder interface {
+	FindByEmail(ctx context.Context, email string) (*entities.User, error)
+}
+
+// RegistratorWithRetry implements Registrator that retries transient errors with exponential backoff
+type RegistratorWithRetry struct {
+	base      Registrator
+	attempts  int
+	backoff   time.Duration
+	retryable []error
+
+	// Finder and Hasher are optional, when both are set a retry failing on
+	// the taken email looks the user up, as the failed attempt may have been
+	// stored with its acknowledgement lost. The user is returned when the
+	// password of the form matches its hash.
+	Finder EmailFinder
+	Hasher hasher.PasswordHasher
+}
+
+// NewRegistratorWithRetry retries Register of the base Registrator up to attempts times while it fails
+// with one of retryable errors, waiting backoff before the first retry and doubling it afterwards
+func NewRegistratorWithRetry(base Registrator, attempts int, backoff time.Duration, retryable ...error) RegistratorWithRetry {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	return RegistratorWithRetry{
+		base:      base,
+		attempts:  attempts,
+		backoff:   backoff,
+		retryable: retryable,
+	}
+}
+
+// Register implements Registrator
+func (rr RegistratorWithRetry) Register(ctx context.Context, f *entities.Form) (u *entities.User, err error) {
+	wait := rr.backoff
+	for attempt := 1; ; attempt++ {
+		u, err = rr.base.Register(ctx, f)
+		if attempt > 1 && onlyTaken(err) {
+			return rr.stored(ctx, f, err)
+		}
+		if err == nil || attempt >= rr.attempts || !rr.retry(err) {
+			return u, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// stored returns the user an earlier attempt stored for f, otherwise err.
+func (rr RegistratorWithRetry) stored(ctx context.Context, f *entities.Form, err error) (*entities.User, error) {
+	if rr.Finder == nil || rr.Hasher == nil {
+		return nil, err
+	}
+
+	g := *f
+	g.Sanitize()
+	u, ferr := rr.Finder.FindByEmail(ctx, g.Email)
+	if ferr != nil || rr.Hasher.Compare(u.Password, f.Password) != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// retry reports whether err is one of the retryable errors. Validation
+// failures and existing emails are never retried.
+func (rr RegistratorWithRetry) retry(err error) bool {
+	var v ValidationErrors
+	if errors.As(err, &v) || errors.Is(err, svcerrors.ErrEmailExists) {
+		return false
+	}
+
+	for _, r := range rr.retryable {
+		if errors.Is(err, r) {
+			return true
+		}
+	}
+
+	return false
+}
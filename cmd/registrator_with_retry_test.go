@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTransient = errors.New("connection reset")
+
+func TestRegistratorWithRetry(t *testing.T) {
+	t.Log("with registrator retrying transient errors.")
+	{
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould succeed after two transient failures.")
+		{
+			base := &flakyRegistrator{errs: []error{errTransient, errors.Wrap(errTransient, "repository create")}}
+			rr := NewRegistratorWithRetry(base, 3, time.Millisecond, errTransient)
+
+			u, err := rr.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+			assert.Equal(t, "new@domain.zone", u.Email)
+			assert.Equal(t, 3, base.calls)
+		}
+
+		t.Log("\ttest:1\tshould give up after max attempts.")
+		{
+			base := &flakyRegistrator{errs: []error{errTransient, errTransient, errTransient}}
+			rr := NewRegistratorWithRetry(base, 2, time.Millisecond, errTransient)
+
+			_, err := rr.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Equal(t, errTransient, err)
+			assert.Equal(t, 2, base.calls)
+		}
+
+		t.Log("\ttest:2\tshould not retry validation errors and existing email.")
+		{
+			for _, e := range []error{ValidationErrors{"email": "email is invalid"}, errors.Wrap(svcerrors.ErrEmailExists, "repository create")} {
+				base := &flakyRegistrator{errs: []error{e}}
+				rr := NewRegistratorWithRetry(base, 3, time.Millisecond, errTransient, svcerrors.ErrEmailExists)
+
+				_, err := rr.Register(ctx, testForm("new@domain.zone", "qwerty"))
+				assert.NotNil(t, err)
+				assert.Equal(t, 1, base.calls)
+			}
+		}
+
+		t.Log("\ttest:3\tshould stop waiting when context is cancelled.")
+		{
+			base := &flakyRegistrator{errs: []error{errTransient, errTransient}}
+			rr := NewRegistratorWithRetry(base, 3, time.Hour, errTransient)
+
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+
+			_, err := rr.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Equal(t, context.DeadlineExceeded, err)
+			assert.Equal(t, 1, base.calls)
+		}
+	}
+
+	t.Log("with registrator losing the acknowledgement of a stored user.")
+	{
+		ctx := context.Background()
+		retry := func(repo Repository) RegistratorWithRetry {
+			rr := NewRegistratorWithRetry(&lostAckRegistrator{repo: repo}, 3, time.Millisecond, errTransient)
+			rr.Finder, rr.Hasher = repo, fakeHasher{}
+			return rr
+		}
+
+		t.Log("\ttest:0\tshould return the stored user instead of the taken email.")
+		{
+			repo := testStorage()
+
+			u, err := retry(repo).Register(ctx, testForm(" new@domain.zone ", "qwerty"))
+			assert.Nil(t, err)
+			assert.Equal(t, "new@domain.zone", u.Email)
+
+			n, err := repo.Count(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, n)
+		}
+
+		t.Log("\ttest:1\tshould report the taken email of another registration.")
+		{
+			repo := testStorage()
+			rr := retry(repo)
+			rr.base = &lostAckRegistrator{repo: repo, stored: testForm("new@domain.zone", "other")}
+
+			_, err := rr.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.True(t, errors.Is(err, svcerrors.ErrEmailExists))
+		}
+
+		t.Log("\ttest:2\tshould report the taken email without a finder.")
+		{
+			rr := NewRegistratorWithRetry(&lostAckRegistrator{repo: testStorage()}, 3, time.Millisecond, errTransient)
+
+			_, err := rr.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.True(t, errors.Is(err, svcerrors.ErrEmailExists))
+		}
+	}
+}
+
+// lostAckRegistrator stores the user of the first call, or stored when set,
+// and fails with errTransient as if the acknowledgement was lost. Later
+// calls find the email taken.
+type lostAckRegistrator struct {
+	repo   Repository
+	stored *entities.Form
+	calls  int
+}
+
+func (r *lostAckRegistrator) Register(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	r.calls++
+	if r.calls > 1 {
+		return nil, errors.Wrap(svcerrors.ErrEmailExists, "repository create")
+	}
+
+	g := *f
+	if r.stored != nil {
+		g = *r.stored
+	}
+	g.Sanitize()
+	if _, err := r.repo.Create(ctx, &g); err != nil {
+		return nil, err
+	}
+
+	return nil, errTransient
+}
+
+// flakyRegistrator fails with errs in order and succeeds afterwards.
+type flakyRegistrator struct {
+	errs  []error
+	calls int
+}
+
+func (r *flakyRegistrator) Register(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	r.calls++
+	if r.calls <= len(r.errs) {
+		return nil, r.errs[r.calls-1]
+	}
+
+	return &entities.User{ID: "1", Email: f.Email}, nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"github.com/newtondev/service_object/pkg/entities"
+)
+
+// RegistratorWithSanitizing implements Registrator that sanitizes forms before
+// the base Registrator validates them
+type RegistratorWithSanitizing struct {
+	base Registrator
+}
+
+// NewRegistratorWithSanitizing sanitizes forms registered by the base Registrator
+func NewRegistratorWithSanitizing(base Registrator) RegistratorWithSanitizing {
+	return RegistratorWithSanitizing{base: base}
+}
+
+// Register implements Registrator, see entities.Form.Sanitize for the fields
+// it changes. A copy of f is sanitized, so decorators around it see the form
+// as the caller sent it
+func (rs RegistratorWithSanitizing) Register(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	g := *f
+	g.Sanitize()
+	return rs.base.Register(ctx, &g)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+func TestRegistratorWithSanitizing(t *testing.T) {
+	t.Log("with service registering sanitized forms.")
+	{
+		ctx := context.Background()
+		repo := testStorage()
+		s := &Service{Validator: &PlayValidator{Validator: validator.New(), Repository: repo}, Repository: repo, Hasher: fakeHasher{}}
+		rs := NewRegistratorWithSanitizing(s)
+
+		t.Log("\ttest:0\tshould store the email composed to NFC without surrounding whitespace.")
+		{
+			f := testForm(" jose\u0301@domain.zone\t", " pass word ")
+			f.FirstName = " Jose\u0301 "
+
+			u, err := rs.Register(ctx, f)
+			assert.Nil(t, err)
+			assert.Equal(t, "jos\u00e9@domain.zone", u.Email)
+			assert.Equal(t, "Jos\u00e9", u.FirstName)
+		}
+
+		t.Log("\ttest:1\tshould keep spaces of the password.")
+		{
+			_, err := s.Authenticate(ctx, "jos\u00e9@domain.zone", " pass word ")
+			assert.Nil(t, err)
+		}
+
+		t.Log("\ttest:2\tshould treat the composed and decomposed email as the same.")
+		{
+			for _, email := range []string{"jos\u00e9@domain.zone", " jose\u0301@domain.zone"} {
+				_, err := rs.Register(ctx, testForm(email, "qwerty"))
+				assert.Equal(t, ValidationErrors{"email": constants.EmailExists}, validationMap(err))
+			}
+		}
+
+		t.Log("\ttest:3\tshould keep the form of the caller unchanged.")
+		{
+			f := testForm(" other@domain.zone ", "qwerty")
+			f.FirstName = " Jose\u0301 "
+
+			_, err := rs.Register(ctx, f)
+			assert.Nil(t, err)
+			assert.Equal(t, " other@domain.zone ", f.Email)
+			assert.Equal(t, " Jose\u0301 ", f.FirstName)
+		}
+	}
+}
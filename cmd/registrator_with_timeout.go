@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+)
+
+// RegistratorWithTimeout implements Registrator that gives up on calls exceeding a deadline
+type RegistratorWithTimeout struct {
+	base    Registrator
+	timeout time.Duration
+}
+
+// NewRegistratorWithTimeout limits Register of the base Registrator to timeout
+func NewRegistratorWithTimeout(base Registrator, timeout time.Duration) RegistratorWithTimeout {
+	return RegistratorWithTimeout{
+		base:    base,
+		timeout: timeout,
+	}
+}
+
+// Register implements Registrator, returning ErrTimeout when the deadline
+// passes even if the base ignores the cancelled context. The base gets the
+// cancelled context and a copy of f, so a call left running never changes
+// the form of the caller. Stores honouring the context abort the call, but
+// a base ignoring it may still create the user after ErrTimeout is returned.
+// Panics of the base are raised again in the calling goroutine, so they can
+// be recovered there
+func (rt RegistratorWithTimeout) Register(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	g := *f
+
+	type result struct {
+		u     *entities.User
+		err   error
+		panic *goroutinePanic
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{panic: &goroutinePanic{value: p, stack: debug.Stack()}}
+			}
+		}()
+
+		u, err := rt.base.Register(ctx, &g)
+		done <- result{u: u, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.panic != nil {
+			panic(r.panic)
+		}
+		if r.err != nil && ctx.Err() == context.DeadlineExceeded {
+			return nil, svcerrors.ErrTimeout
+		}
+		return r.u, r.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, svcerrors.ErrTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// goroutinePanic carries a panic recovered in another goroutine with the
+// stack it was raised at
+type goroutinePanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *goroutinePanic) String() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
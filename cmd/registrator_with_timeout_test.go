@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistratorWithTimeout(t *testing.T) {
+	t.Log("with registrator limited to 50ms.")
+	{
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould return result of a fast call.")
+		{
+			rt := NewRegistratorWithTimeout(fakeRegistrator{}, 50*time.Millisecond)
+
+			u, err := rt.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+			assert.Equal(t, "new@domain.zone", u.Email)
+		}
+
+		t.Log("\ttest:1\tshould return timeout error for a slow call.")
+		{
+			rt := NewRegistratorWithTimeout(fakeRegistrator{delay: time.Second}, 50*time.Millisecond)
+
+			start := time.Now()
+			_, err := rt.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Equal(t, svcerrors.ErrTimeout, err)
+			assert.True(t, time.Since(start) < 500*time.Millisecond)
+		}
+
+		t.Log("\ttest:2\tshould return cancellation of the caller.")
+		{
+			rt := NewRegistratorWithTimeout(fakeRegistrator{delay: time.Second}, time.Minute)
+
+			ctx, cancel := context.WithCancel(ctx)
+			cancel()
+
+			_, err := rt.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Equal(t, context.Canceled, err)
+		}
+
+		t.Log("\ttest:3\tshould raise panics of the base in the calling goroutine.")
+		{
+			rt := NewRegistratorWithTimeout(panickingRegistrator{}, time.Minute)
+
+			assert.PanicsWithValue(t, "register", func() {
+				defer func() {
+					p := recover()
+					gp, ok := p.(*goroutinePanic)
+					assert.True(t, ok)
+					assert.Contains(t, string(gp.stack), "panickingRegistrator")
+					panic(gp.value)
+				}()
+
+				rt.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			})
+		}
+
+		t.Log("\ttest:4\tshould keep the form of the caller when the abandoned call changes it.")
+		{
+			done := make(chan struct{})
+			rt := NewRegistratorWithTimeout(mutatingRegistrator{delay: 100 * time.Millisecond, done: done}, 50*time.Millisecond)
+
+			f := testForm("new@domain.zone", "qwerty")
+			_, err := rt.Register(ctx, f)
+			assert.Equal(t, svcerrors.ErrTimeout, err)
+
+			<-done
+			assert.Equal(t, "new@domain.zone", f.Email)
+		}
+	}
+}
+
+// mutatingRegistrator changes the email of the form after delay, closing
+// done once it did.
+type mutatingRegistrator struct {
+	delay time.Duration
+	done  chan struct{}
+}
+
+func (r mutatingRegistrator) Register(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	defer close(r.done)
+
+	time.Sleep(r.delay)
+	f.Email = "changed@domain.zone"
+	return nil, ctx.Err()
+}
+
+// panickingRegistrator panics on every call.
+type panickingRegistrator struct{}
+
+func (panickingRegistrator) Register(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	panic("register")
+}
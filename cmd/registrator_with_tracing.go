@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RegistratorWithTracing implements Registrator that is instrumented with opentelemetry spans
+type RegistratorWithTracing struct {
+	tracer trace.Tracer
+	base   Registrator
+}
+
+// NewRegistratorWithTracing instruments an implementation of the Registrator with spans started by tracer
+func NewRegistratorWithTracing(base Registrator, tracer trace.Tracer) RegistratorWithTracing {
+	return RegistratorWithTracing{
+		base:   base,
+		tracer: tracer,
+	}
+}
+
+// Register implements Registrator
+func (rt RegistratorWithTracing) Register(ctx context.Context, f *entities.Form) (u *entities.User, err error) {
+	ctx, span := rt.tracer.Start(ctx, "Registrator.Register", trace.WithAttributes(attribute.String("user.email", f.Email)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(attribute.String("user.id", u.ID))
+			span.SetStatus(codes.Ok, "")
+		}
+		span.End()
+	}()
+	return rt.base.Register(ctx, f)
+}
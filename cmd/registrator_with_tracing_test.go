@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRegistratorWithTracing(t *testing.T) {
+	t.Log("with instrumented registrator.")
+	{
+		exporter := tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		base := &fakeRegistrator{}
+		rt := NewRegistratorWithTracing(base, tp.Tracer("test"))
+
+		t.Log("\ttest:0\tshould record successful span.")
+		{
+			_, err := rt.Register(context.Background(), testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+
+			spans := exporter.GetSpans()
+			assert.Len(t, spans, 1)
+			assert.Equal(t, "Registrator.Register", spans[0].Name)
+			assert.Equal(t, codes.Ok, spans[0].Status.Code)
+			assert.Contains(t, spans[0].Attributes, attribute.String("user.email", "new@domain.zone"))
+		}
+
+		t.Log("\ttest:1\tshould mark span of failed call as errored.")
+		{
+			exporter.Reset()
+			base.err = errors.New("boom")
+
+			_, err := rt.Register(context.Background(), testForm("new@domain.zone", "qwerty"))
+			assert.NotNil(t, err)
+
+			spans := exporter.GetSpans()
+			assert.Len(t, spans, 1)
+			assert.Equal(t, codes.Error, spans[0].Status.Code)
+			assert.Equal(t, "boom", spans[0].Status.Description)
+		}
+
+		t.Log("\ttest:2\tshould nest span under the incoming one.")
+		{
+			exporter.Reset()
+			base.err = nil
+
+			ctx, parent := tp.Tracer("test").Start(context.Background(), "POST /register")
+			_, err := rt.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+			parent.End()
+
+			spans := exporter.GetSpans()
+			assert.Len(t, spans, 2)
+			assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"sync"
+	"text/template"
+
+	"github.com/newtondev/service_object/pkg/entities"
+)
+
+// Default limits of welcome email sending.
+const (
+	DefaultWelcomeWorkers = 2
+	DefaultWelcomeQueue   = 100
+)
+
+// DefaultVerifyURL is a relative address of the verification endpoint.
+const DefaultVerifyURL = "/verify"
+
+// WelcomeSubject is a subject of welcome emails.
+const WelcomeSubject = "Welcome aboard"
+
+// WelcomeTemplate renders body of welcome emails for a user.
+var WelcomeTemplate = template.Must(template.New("welcome").Parse(`Hi {{if .FirstName}}{{.FirstName}}{{else}}there{{end}},
+
+thanks for signing up with {{.Email}}. Please verify your email with the link we sent you to finish the registration.
+`))
+
+// VerifySubject is a subject of emails carrying verification links.
+const VerifySubject = "Verify your email"
+
+// VerifyTemplate renders body of verification emails for a user and the
+// link verifying them.
+var VerifyTemplate = template.Must(template.New("verify").Parse(`Hi {{if .FirstName}}{{.FirstName}}{{else}}there{{end}},
+
+please verify {{.Email}} by opening the link below.
+
+{{.Link}}
+`))
+
+// ResetSubject is a subject of emails carrying password reset tokens.
+const ResetSubject = "Reset your password"
+
+// ResetTemplate renders body of password reset emails for a user and the
+// token resetting their password.
+var ResetTemplate = template.Must(template.New("reset").Parse(`Hi {{if .FirstName}}{{.FirstName}}{{else}}there{{end}},
+
+someone asked to reset the password of {{.Email}}. Use the token below to choose a new one.
+
+{{.Token}}
+
+If it was not you, you can ignore this email.
+`))
+
+// tokenData is rendered by templates of emails carrying a token, either as
+// it is or within a link.
+type tokenData struct {
+	*entities.User
+	Token string
+	Link  string
+}
+
+// ExistingSubject is a subject of emails telling an account already exists.
+const ExistingSubject = "You already have an account"
+
+// ExistingTemplate renders body of emails sent to registrations of a taken
+// email, for the form registered.
+var ExistingTemplate = template.Must(template.New("existing").Parse(`Hi,
+
+someone tried to sign up with {{.Email}}, but you already have an account. You can sign in or reset your password instead.
+If it was not you, you can ignore this email.
+`))
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// welcomeJob is an email waiting to be sent, its body is data rendered by
+// tmpl and fields identify it in logs.
+type welcomeJob struct {
+	ctx     context.Context
+	to      string
+	subject string
+	tmpl    *template.Template
+	data    interface{}
+	fields  []interface{}
+}
+
+// RegistratorWithWelcome implements Registrator that sends welcome emails after successful registrations
+// in the background, so slow mail servers do not delay responses
+type RegistratorWithWelcome struct {
+	// NotifyExisting sends registrations failing only on a taken email a
+	// notice that the account exists instead
+	NotifyExisting bool
+	// VerifyURL is the address of the verification endpoint links point
+	// to, the token is added as its query. DefaultVerifyURL is used when empty
+	VerifyURL string
+
+	base   Registrator
+	mailer Mailer
+	logger Logger
+
+	jobs   chan welcomeJob
+	mu     *sync.RWMutex
+	closed *bool
+	wg     *sync.WaitGroup
+}
+
+// NewRegistratorWithWelcome sends welcome emails to users registered by the base Registrator with mailer
+// using workers goroutines, at most queue emails wait to be sent and the rest are dropped and logged with logger
+func NewRegistratorWithWelcome(base Registrator, mailer Mailer, logger Logger, workers, queue int) RegistratorWithWelcome {
+	if workers <= 0 {
+		workers = DefaultWelcomeWorkers
+	}
+	if queue <= 0 {
+		queue = DefaultWelcomeQueue
+	}
+
+	rw := RegistratorWithWelcome{
+		base:   base,
+		mailer: mailer,
+		logger: logger,
+		jobs:   make(chan welcomeJob, queue),
+		mu:     &sync.RWMutex{},
+		closed: new(bool),
+		wg:     &sync.WaitGroup{},
+	}
+
+	rw.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go rw.work()
+	}
+
+	return rw
+}
+
+// Register implements Registrator
+func (rw RegistratorWithWelcome) Register(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	u, err := rw.base.Register(ctx, f)
+	if err != nil {
+		if rw.NotifyExisting && onlyTaken(err) {
+			rw.enqueue(ctx, welcomeJob{to: f.Email, subject: ExistingSubject, tmpl: ExistingTemplate, data: f}, "existing_email", f.Email)
+		}
+		return nil, err
+	}
+
+	rw.enqueue(ctx, welcomeJob{to: u.Email, subject: WelcomeSubject, tmpl: WelcomeTemplate, data: u}, "user_id", u.ID)
+	return u, nil
+}
+
+// NotifyVerification implements VerificationNotifier, queueing an email with
+// the link verifying u
+func (rw RegistratorWithWelcome) NotifyVerification(ctx context.Context, u *entities.User, token string) {
+	link := rw.VerifyURL
+	if link == "" {
+		link = DefaultVerifyURL
+	}
+	link += "?" + url.Values{"token": {token}}.Encode()
+
+	rw.enqueue(ctx, welcomeJob{to: u.Email, subject: VerifySubject, tmpl: VerifyTemplate, data: tokenData{User: u, Link: link}}, "user_id", u.ID)
+}
+
+// NotifyReset implements ResetNotifier, queueing an email with the token
+// resetting the password of u
+func (rw RegistratorWithWelcome) NotifyReset(ctx context.Context, u *entities.User, token string) {
+	rw.enqueue(ctx, welcomeJob{to: u.Email, subject: ResetSubject, tmpl: ResetTemplate, data: tokenData{User: u, Token: token}}, "user_id", u.ID)
+}
+
+// enqueue queues j to be sent, dropped emails are logged with the key and
+// value identifying them.
+func (rw RegistratorWithWelcome) enqueue(ctx context.Context, j welcomeJob, key, value string) {
+	rw.mu.RLock()
+	defer rw.mu.RUnlock()
+
+	if *rw.closed {
+		rw.logger.Error("RegistratorWithWelcome: welcome email dropped, mailer is closed", append([]interface{}{key, value}, LogContext.Fields(ctx)...)...)
+		return
+	}
+
+	// the response must not wait for the email, so it is sent after the request is done.
+	j.ctx, j.fields = context.WithoutCancel(ctx), []interface{}{key, value}
+	select {
+	case rw.jobs <- j:
+	default:
+		rw.logger.Error("RegistratorWithWelcome: welcome email dropped, queue is full", append([]interface{}{key, value}, LogContext.Fields(ctx)...)...)
+	}
+}
+
+// Close stops accepting emails and waits until queued ones are sent.
+func (rw RegistratorWithWelcome) Close() {
+	rw.mu.Lock()
+	if !*rw.closed {
+		*rw.closed = true
+		close(rw.jobs)
+	}
+	rw.mu.Unlock()
+
+	rw.wg.Wait()
+}
+
+// work sends queued emails until the queue is closed.
+func (rw RegistratorWithWelcome) work() {
+	defer rw.wg.Done()
+
+	for j := range rw.jobs {
+		var body bytes.Buffer
+		if err := j.tmpl.Execute(&body, j.data); err != nil {
+			rw.logger.Error("RegistratorWithWelcome: render failed", append(append(j.fields, "error", err.Error()), LogContext.Fields(j.ctx)...)...)
+			continue
+		}
+
+		if err := rw.mailer.Send(j.ctx, j.to, j.subject, body.String()); err != nil {
+			rw.logger.Error("RegistratorWithWelcome: Send failed", append(append(j.fields, "error", err.Error()), LogContext.Fields(j.ctx)...)...)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistratorWithWelcome(t *testing.T) {
+	t.Log("with registrator sending welcome emails.")
+	{
+		t.Log("\ttest:0\tshould send the welcome email to the registered email.")
+		{
+			m := &fakeMailer{}
+			rw := NewRegistratorWithWelcome(fakeRegistrator{}, m, &fakeLogger{}, 1, 1)
+
+			_, err := rw.Register(context.Background(), testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+			rw.Close()
+
+			assert.Len(t, m.sent, 1)
+			assert.Equal(t, "new@domain.zone", m.sent[0].to)
+			assert.Equal(t, WelcomeSubject, m.sent[0].subject)
+			assert.Contains(t, m.sent[0].body, "thanks for signing up with new@domain.zone")
+		}
+
+		t.Log("\ttest:1\tshould not send emails for failed registrations.")
+		{
+			m := &fakeMailer{}
+			rw := NewRegistratorWithWelcome(fakeRegistrator{err: errors.New("boom")}, m, &fakeLogger{}, 1, 1)
+
+			_, err := rw.Register(context.Background(), testForm("new@domain.zone", "qwerty"))
+			assert.NotNil(t, err)
+			rw.Close()
+
+			assert.Empty(t, m.sent)
+		}
+
+		t.Log("\ttest:2\tshould tell owners of taken emails when notifying existing accounts.")
+		{
+			m := &fakeMailer{}
+			rw := NewRegistratorWithWelcome(fakeRegistrator{err: ValidationErrors{"email": constants.EmailExists}}, m, &fakeLogger{}, 1, 1)
+			rw.NotifyExisting = true
+
+			_, err := rw.Register(context.Background(), testForm("exists@domain.zone", "qwerty"))
+			assert.NotNil(t, err)
+			rw.Close()
+
+			assert.Len(t, m.sent, 1)
+			assert.Equal(t, "exists@domain.zone", m.sent[0].to)
+			assert.Equal(t, ExistingSubject, m.sent[0].subject)
+			assert.Contains(t, m.sent[0].body, "you already have an account")
+		}
+
+		t.Log("\ttest:3\tshould not notify registrations failing on other fields too.")
+		{
+			m := &fakeMailer{}
+			rw := NewRegistratorWithWelcome(fakeRegistrator{err: ValidationErrors{"email": constants.EmailExists, "password": constants.PasswordMismatch}}, m, &fakeLogger{}, 1, 1)
+			rw.NotifyExisting = true
+
+			_, err := rw.Register(context.Background(), testForm("exists@domain.zone", "qwerty"))
+			assert.NotNil(t, err)
+			rw.Close()
+
+			assert.Empty(t, m.sent)
+		}
+
+		t.Log("\ttest:4\tshould log send failures keeping the registration.")
+		{
+			l := &fakeLogger{}
+			rw := NewRegistratorWithWelcome(fakeRegistrator{}, &fakeMailer{err: errors.New("connection refused")}, l, 1, 1)
+
+			u, err := rw.Register(context.Background(), testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+			assert.NotNil(t, u)
+			rw.Close()
+
+			assert.Len(t, l.errors, 1)
+			assert.Equal(t, "connection refused", l.errors[0]["error"])
+		}
+
+		t.Log("\ttest:5\tshould drop emails without blocking when the queue is full.")
+		{
+			m := &fakeMailer{started: make(chan struct{}, 1), release: make(chan struct{})}
+			l := &fakeLogger{}
+			rw := NewRegistratorWithWelcome(fakeRegistrator{}, m, l, 1, 1)
+
+			rw.Register(context.Background(), testForm("a@domain.zone", "qwerty"))
+			<-m.started
+			rw.Register(context.Background(), testForm("b@domain.zone", "qwerty"))
+			rw.Register(context.Background(), testForm("c@domain.zone", "qwerty"))
+
+			assert.Len(t, l.errors, 1)
+			close(m.release)
+			rw.Close()
+
+			assert.Len(t, m.sent, 2)
+			assert.Equal(t, "a@domain.zone", m.sent[0].to)
+			assert.Equal(t, "b@domain.zone", m.sent[1].to)
+		}
+	}
+}
+
+// sentEmail is an email captured by fakeMailer.
+type sentEmail struct {
+	to, subject, body string
+}
+
+// fakeMailer captures sent emails failing with err. When release is set
+// sends signal started and block until it is closed.
+type fakeMailer struct {
+	err     error
+	started chan struct{}
+	release chan struct{}
+
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+func (m *fakeMailer) Send(ctx context.Context, to, subject, body string) error {
+	if m.release != nil {
+		select {
+		case m.started <- struct{}{}:
+		default:
+		}
+		<-m.release
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentEmail{to: to, subject: subject, body: body})
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/pkg/errors"
+)
+
+// EmailCache remembers emails known to be taken.
+type EmailCache interface {
+	Has(ctx context.Context, email string) (bool, error)
+	Add(ctx context.Context, email string, ttl time.Duration) error
+	Remove(ctx context.Context, email string) error
+}
+
+// RepositoryWithCache implements Repository that answers Unique from EmailCache
+// when it knows the email and falls back to the base Repository otherwise
+type RepositoryWithCache struct {
+	Repository
+	cache EmailCache
+	ttl   time.Duration
+}
+
+// NewRepositoryWithCache caches emails of the base Repository in cache for ttl,
+// the returned Repository implements PasswordHistory only when the base does
+func NewRepositoryWithCache(base Repository, cache EmailCache, ttl time.Duration) Repository {
+	rc := RepositoryWithCache{
+		Repository: base,
+		cache:      cache,
+		ttl:        ttl,
+	}
+	if h, ok := base.(PasswordHistory); ok {
+		return repositoryWithCacheAndHistory{RepositoryWithCache: rc, history: h}
+	}
+
+	return rc
+}
+
+// repositoryWithCacheAndHistory is RepositoryWithCache of a base keeping
+// password history
+type repositoryWithCacheAndHistory struct {
+	RepositoryWithCache
+	history PasswordHistory
+}
+
+// PasswordHistory implements PasswordHistory
+func (rh repositoryWithCacheAndHistory) PasswordHistory(ctx context.Context, id string, limit int) ([]string, error) {
+	return rh.history.PasswordHistory(ctx, id, limit)
+}
+
+// AddPasswordHistory implements PasswordHistory
+func (rh repositoryWithCacheAndHistory) AddPasswordHistory(ctx context.Context, id string, hash string) error {
+	return rh.history.AddPasswordHistory(ctx, id, hash)
+}
+
+// Unique implements Repository, cache misses and failures are checked by
+// the base Repository
+func (rc RepositoryWithCache) Unique(ctx context.Context, email string) error {
+	if ok, err := rc.cache.Has(ctx, email); err == nil && ok {
+		return svcerrors.ErrEmailExists
+	}
+
+	err := rc.Repository.Unique(ctx, email)
+	if errors.Is(err, svcerrors.ErrEmailExists) {
+		rc.cache.Add(ctx, email, rc.ttl)
+	}
+
+	return err
+}
+
+// Create implements Repository
+func (rc RepositoryWithCache) Create(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	u, err := rc.Repository.Create(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// the user is stored, a failing cache only costs a base lookup later.
+	rc.cache.Add(ctx, u.Email, rc.ttl)
+	return u, nil
+}
+
+// Update implements Repository, the previous email is forgotten when it changes
+func (rc RepositoryWithCache) Update(ctx context.Context, id string, f *entities.Form) (*entities.User, error) {
+	old, err := rc.Repository.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := rc.Repository.Update(ctx, id, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if old.Email != u.Email {
+		rc.cache.Remove(ctx, old.Email)
+		rc.cache.Add(ctx, u.Email, rc.ttl)
+	}
+
+	return u, nil
+}
+
+// Delete implements Repository, the email of deleted user becomes free
+func (rc RepositoryWithCache) Delete(ctx context.Context, id string) error {
+	u, err := rc.Repository.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := rc.Repository.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	rc.cache.Remove(ctx, u.Email)
+	return nil
+}
+
+// Ping implements Pinger when the base Repository does
+func (rc RepositoryWithCache) Ping(ctx context.Context) error {
+	if p, ok := rc.Repository.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+// CheckWrite implements WriteChecker when the base Repository does
+func (rc RepositoryWithCache) CheckWrite(ctx context.Context) error {
+	if c, ok := rc.Repository.(WriteChecker); ok {
+		return c.CheckWrite(ctx)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryWithCache(t *testing.T) {
+	t.Log("with cached repository.")
+	{
+		ctx := context.Background()
+		base := &countingStorage{MemStore: testStorage()}
+		cache := &fakeCache{emails: map[string]time.Duration{}}
+		r := NewRepositoryWithCache(base, cache, time.Hour)
+
+		t.Log("\ttest:0\tshould check the base repository on a miss.")
+		{
+			assert.Nil(t, r.Unique(ctx, "new@domain.zone"))
+			assert.Equal(t, 1, base.unique)
+		}
+
+		t.Log("\ttest:1\tshould cache emails found taken by the base repository.")
+		{
+			assert.Equal(t, svcerrors.ErrEmailExists, r.Unique(ctx, "exists@domain.zone"))
+			assert.Equal(t, 2, base.unique)
+			assert.Equal(t, time.Hour, cache.emails["exists@domain.zone"])
+		}
+
+		t.Log("\ttest:2\tshould answer a hit without the base repository.")
+		{
+			assert.Equal(t, svcerrors.ErrEmailExists, r.Unique(ctx, "exists@domain.zone"))
+			assert.Equal(t, 2, base.unique)
+		}
+
+		t.Log("\ttest:3\tshould write created users through to the cache.")
+		{
+			_, err := r.Create(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+			assert.Contains(t, cache.emails, "new@domain.zone")
+
+			assert.Equal(t, svcerrors.ErrEmailExists, r.Unique(ctx, "new@domain.zone"))
+			assert.Equal(t, 2, base.unique)
+		}
+
+		t.Log("\ttest:4\tshould forget emails of deleted users.")
+		{
+			assert.Nil(t, r.Delete(ctx, "2"))
+			assert.NotContains(t, cache.emails, "new@domain.zone")
+			assert.Nil(t, r.Unique(ctx, "new@domain.zone"))
+		}
+
+		t.Log("\ttest:5\tshould fall back to the base repository when the cache fails.")
+		{
+			cache.err = errors.New("connection refused")
+
+			assert.Equal(t, svcerrors.ErrEmailExists, r.Unique(ctx, "exists@domain.zone"))
+			assert.Equal(t, 4, base.unique)
+
+			_, err := r.Create(ctx, testForm("other@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestRepositoryWithCacheHistory(t *testing.T) {
+	t.Log("with cached repositories.")
+	{
+		ctx := context.Background()
+		cache := &fakeCache{emails: map[string]time.Duration{}}
+
+		t.Log("\ttest:0\tshould keep password history of a base keeping it.")
+		{
+			r := NewRepositoryWithCache(testStorage(), cache, time.Hour)
+
+			h, ok := r.(PasswordHistory)
+			assert.True(t, ok)
+			assert.Nil(t, h.AddPasswordHistory(ctx, "1", "hashed:old"))
+
+			hashes, err := h.PasswordHistory(ctx, "1", 5)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"hashed:old"}, hashes)
+		}
+
+		t.Log("\ttest:1\tshould not claim password history of a base without it.")
+		{
+			r := NewRepositoryWithCache(struct{ Repository }{testStorage()}, cache, time.Hour)
+
+			_, ok := r.(PasswordHistory)
+			assert.False(t, ok)
+		}
+	}
+}
+
+// countingStorage counts uniqueness checks reaching the storage.
+type countingStorage struct {
+	*storage.MemStore
+	unique int
+}
+
+func (s *countingStorage) Unique(ctx context.Context, email string) error {
+	s.unique++
+	return s.MemStore.Unique(ctx, email)
+}
+
+// fakeCache is an EmailCache keeping emails with their ttl, every call fails
+// with err when it is set.
+type fakeCache struct {
+	emails map[string]time.Duration
+	err    error
+}
+
+func (c *fakeCache) Has(ctx context.Context, email string) (bool, error) {
+	if c.err != nil {
+		return false, c.err
+	}
+
+	_, ok := c.emails[email]
+	return ok, nil
+}
+
+func (c *fakeCache) Add(ctx context.Context, email string, ttl time.Duration) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	c.emails[email] = ttl
+	return nil
+}
+
+func (c *fakeCache) Remove(ctx context.Context, email string) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	delete(c.emails, email)
+	return nil
+}
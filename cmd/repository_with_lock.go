@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/pkg/errors"
+)
+
+// RepositoryWithLock implements Repository that serializes creation of users
+// with the same email, so concurrent registrations passing the uniqueness check
+// together cannot both be stored by the base Repository
+type RepositoryWithLock struct {
+	Repository
+	locks keyedMutex
+}
+
+// NewRepositoryWithLock locks emails around creations in the base Repository
+func NewRepositoryWithLock(base Repository) *RepositoryWithLock {
+	return &RepositoryWithLock{Repository: base}
+}
+
+// Create implements Repository, the email is checked again under its lock
+// as it may have been taken since the form was validated. Emails are locked
+// lowercased, as stores look them up case insensitively
+func (rl *RepositoryWithLock) Create(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	unlock := rl.locks.Lock(entities.NormalizeEmail(f.Email, true))
+	defer unlock()
+
+	if err := rl.Repository.Unique(ctx, f.Email); err != nil {
+		return nil, errors.Wrap(err, "repository unique")
+	}
+
+	return rl.Repository.Create(ctx, f)
+}
+
+// Ping implements Pinger when the base Repository does
+func (rl *RepositoryWithLock) Ping(ctx context.Context) error {
+	if p, ok := rl.Repository.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+// CheckWrite implements WriteChecker when the base Repository does
+func (rl *RepositoryWithLock) CheckWrite(ctx context.Context) error {
+	if c, ok := rl.Repository.(WriteChecker); ok {
+		return c.CheckWrite(ctx)
+	}
+
+	return nil
+}
+
+// keyedMutex is a set of mutexes by key, a mutex lives while it is held or
+// waited for.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock locks key and returns the func unlocking it.
+func (m *keyedMutex) Lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyedLock)
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		m.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
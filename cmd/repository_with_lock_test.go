@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryWithLock(t *testing.T) {
+	for _, emails := range [][2]string{
+		{"new@domain.zone", "new@domain.zone"},
+		{"Bob@domain.zone", "bob@domain.zone"},
+	} {
+		t.Logf("with concurrent registrations of %s and %s.", emails[0], emails[1])
+		{
+			repo := testStorage()
+			// both registrations pass validation before either is created.
+			barrier := &barrierValidator{}
+			barrier.Add(2)
+			srv := &Service{Validator: barrier, Repository: NewRepositoryWithLock(&stallingStorage{MemStore: repo, delay: 50 * time.Millisecond})}
+
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					_, errs[i] = srv.Register(context.Background(), testForm(emails[i], "qwerty"))
+				}(i)
+			}
+			wg.Wait()
+
+			t.Log("\ttest:0\tshould create exactly one user.")
+			{
+				var created, taken int
+				for _, err := range errs {
+					switch {
+					case err == nil:
+						created++
+					case errors.Is(err, svcerrors.ErrEmailExists):
+						taken++
+					}
+				}
+				assert.Equal(t, 1, created)
+				assert.Equal(t, 1, taken)
+				assert.Len(t, repo.Users, 2)
+			}
+
+			t.Log("\ttest:1\tshould release the lock of the email.")
+			{
+				assert.Empty(t, srv.Repository.(*RepositoryWithLock).locks.locks)
+			}
+		}
+	}
+}
+
+// stallingStorage waits after uniqueness checks, so concurrent creations
+// not serialized by the lock both pass the check.
+type stallingStorage struct {
+	*storage.MemStore
+	delay time.Duration
+}
+
+func (s *stallingStorage) Unique(ctx context.Context, email string) error {
+	err := s.MemStore.Unique(ctx, email)
+	time.Sleep(s.delay)
+	return err
+}
+
+// barrierValidator accepts every form once all the expected validations
+// are waiting.
+type barrierValidator struct {
+	sync.WaitGroup
+}
+
+func (v *barrierValidator) Validate(context.Context, *entities.Form) error {
+	v.Done()
+	v.Wait()
+	return nil
+}
+
+func (v *barrierValidator) ValidateUpdate(context.Context, *entities.User, *entities.Form) error {
+	return nil
+}
+
+func (v *barrierValidator) ValidatePassword(context.Context, string) error {
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries id correlating logs of a request.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen limits ids accepted from clients.
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// WithRequestID stores id of the X-Request-ID header in the request context
+// and echoes it in the response, an UUID is generated when the header is
+// missing or too long.
+func WithRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLen {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFromContext returns id of the request carried by ctx.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
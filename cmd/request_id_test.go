@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Log("with request id middleware.")
+	{
+		var got string
+		h := WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = RequestIDFromContext(r.Context())
+		}))
+
+		t.Log("\ttest:0\tshould generate id when header is absent.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			_, err := uuid.Parse(got)
+			assert.Nil(t, err)
+			assert.Equal(t, got, w.Header().Get(RequestIDHeader))
+		}
+
+		t.Log("\ttest:1\tshould preserve provided id.")
+		{
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set(RequestIDHeader, "req-42")
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, "req-42", got)
+			assert.Equal(t, "req-42", w.Header().Get(RequestIDHeader))
+		}
+
+		t.Log("\ttest:2\tshould replace too long id.")
+		{
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set(RequestIDHeader, strings.Repeat("a", maxRequestIDLen+1))
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			_, err := uuid.Parse(got)
+			assert.Nil(t, err)
+		}
+	}
+
+	t.Log("with logged registrator.")
+	{
+		ctx := context.WithValue(context.Background(), requestIDKey{}, "req-42")
+
+		t.Log("\ttest:0\tshould include request id in log lines.")
+		{
+			var stdout, stderr bytes.Buffer
+			rl := NewRegistratorWithLog(fakeRegistrator{}, &stdout, &stderr, true)
+
+			_, err := rl.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+
+			for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+				var entry map[string]interface{}
+				assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+				assert.Equal(t, "req-42", entry["request_id"])
+			}
+		}
+	}
+}
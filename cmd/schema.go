@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// RegistrationSchema is a JSON Schema of registration request bodies.
+//
+//go:embed registration.schema.json
+var RegistrationSchema []byte
+
+// SchemaValidator validates raw JSON documents against a compiled JSON Schema.
+type SchemaValidator struct {
+	schema *jsonschema.Schema
+}
+
+// NewSchemaValidator compiles the schema document once.
+func NewSchemaValidator(doc []byte) (*SchemaValidator, error) {
+	v, err := jsonschema.UnmarshalJSON(bytes.NewReader(doc))
+	if err != nil {
+		return nil, errors.Wrap(err, "schema unmarshal")
+	}
+
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource("schema.json", v); err != nil {
+		return nil, errors.Wrap(err, "schema add resource")
+	}
+
+	schema, err := c.Compile("schema.json")
+	if err != nil {
+		return nil, errors.Wrap(err, "schema compile")
+	}
+
+	return &SchemaValidator{schema: schema}, nil
+}
+
+// Validate checks the JSON body against the schema. Violations are returned
+// as ValidationErrors keyed by the dotted path of the offending value, other
+// errors mean the body is not valid JSON.
+func (s *SchemaValidator) Validate(body []byte) error {
+	v, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	err = s.schema.Validate(v)
+	if err == nil {
+		return nil
+	}
+
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+
+	validations := make(ValidationErrors)
+	for _, u := range ve.BasicOutput().Errors {
+		if u.Error == nil {
+			continue
+		}
+
+		field := strings.ReplaceAll(strings.TrimPrefix(u.InstanceLocation, "/"), "/", ".")
+		if field == "" {
+			field = "body"
+		}
+
+		if _, ok := validations[field]; !ok {
+			validations[field] = u.Error.String()
+		}
+	}
+
+	return validations
+}
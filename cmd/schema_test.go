@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaValidation(t *testing.T) {
+	t.Log("with registration handler validating the schema.")
+	{
+		schema, err := NewSchemaValidator(RegistrationSchema)
+		assert.Nil(t, err)
+		h := RegistrationHandler{Registrator: fakeRegistrator{}, Schema: schema}
+
+		t.Log("\ttest:0\tshould report type mismatch by field.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register", `{"email": "a@domain.zone", "password": 123456, "password_confirmation": "123456"}`))
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, CodeValidationFailed, e.Error.Code)
+			assert.Equal(t, map[string]string{"password": "got number, want string"}, e.Error.Fields)
+		}
+
+		t.Log("\ttest:1\tshould report body that is not an object.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register", `["a@domain.zone"]`))
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Contains(t, e.Error.Fields, "body")
+		}
+
+		t.Log("\ttest:2\tshould accept valid payload.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register", `{"email": "a@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`))
+			assert.Equal(t, http.StatusCreated, w.Code)
+		}
+
+		t.Log("\ttest:3\tshould keep invalid json a bad request.")
+		{
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register", `{"email": `))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		}
+	}
+}
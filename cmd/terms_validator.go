@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/newtondev/service_object/pkg/entities"
+)
+
+// TermsValidator requires registrations to accept the terms of service, it
+// is combined with other validators in a CompositeValidator where terms are
+// required.
+type TermsValidator struct{}
+
+// Validate implements Validator.
+func (TermsValidator) Validate(ctx context.Context, f *entities.Form) error {
+	if !f.AcceptedTerms {
+		return FieldErrors{{Field: "terms", Rule: RuleAccepted, Message: constants.TermsRequired}}
+	}
+
+	return nil
+}
+
+// ValidateUpdate implements Validator, terms are accepted once on
+// registration.
+func (TermsValidator) ValidateUpdate(ctx context.Context, u *entities.User, f *entities.Form) error {
+	return nil
+}
+
+// ValidatePassword implements Validator.
+func (TermsValidator) ValidatePassword(ctx context.Context, password string) error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTermsValidator(t *testing.T) {
+	t.Log("with terms validator.")
+	{
+		ctx := context.Background()
+		f := testForm("new@domain.zone", "qwerty")
+
+		t.Log("\ttest:0\tshould reject form without accepted terms.")
+		{
+			assert.Equal(t, FieldErrors{{Field: "terms", Rule: RuleAccepted, Message: constants.TermsRequired}}, TermsValidator{}.Validate(ctx, f))
+		}
+
+		t.Log("\ttest:1\tshould accept form with accepted terms.")
+		{
+			f.AcceptedTerms = true
+			assert.Nil(t, TermsValidator{}.Validate(ctx, f))
+		}
+	}
+
+	t.Log("with servers requiring terms or not.")
+	{
+		validate := func(cfg Config, body string) *httptest.ResponseRecorder {
+			srv, err := NewServer(cfg, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+			assert.Nil(t, err)
+
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, jsonRequest("POST", "/register/validate", body))
+			return w
+		}
+		form := `{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"`
+
+		t.Log("\ttest:0\tshould accept form with accepted terms.")
+		{
+			w := validate(Config{RequireTerms: true}, form+`, "accepted_terms": true}`)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		t.Log("\ttest:1\tshould reject form without accepted terms.")
+		{
+			w := validate(Config{RequireTerms: true}, form+`, "accepted_terms": false}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&e))
+			assert.Equal(t, map[string]string{"terms": constants.TermsRequired}, e.Error.Fields)
+		}
+
+		t.Log("\ttest:2\tshould not require terms when disabled.")
+		{
+			w := validate(Config{}, form+`}`)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+	}
+}
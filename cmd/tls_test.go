@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/registrationpb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestTLS(t *testing.T) {
+	t.Log("with server configured with a self-signed certificate.")
+	{
+		cert, key, pool := writeTestCert(t, t.TempDir())
+		cfg := Config{TLSCert: cert, TLSKey: key, TLSMinVersion: tls.VersionTLS13}
+
+		s, err := NewServer(cfg, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		go serve(s, l, cfg)
+		defer s.Close()
+
+		url := fmt.Sprintf("https://%s/healthz", l.Addr())
+
+		t.Log("\ttest:0\tshould serve requests over https.")
+		{
+			c := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+			resp, err := c.Get(url)
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+		}
+
+		t.Log("\ttest:1\tshould reject clients below the minimum version.")
+		{
+			c := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MaxVersion: tls.VersionTLS12}}}
+
+			_, err := c.Get(url)
+			assert.NotNil(t, err)
+		}
+	}
+}
+
+func TestGRPCTLS(t *testing.T) {
+	t.Log("with grpc server configured with a self-signed certificate.")
+	{
+		cert, key, pool := writeTestCert(t, t.TempDir())
+		cfg := Config{TLSCert: cert, TLSKey: key, TLSMinVersion: tls.VersionTLS12}
+
+		_, g, err := NewServers(cfg, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+		l := bufconn.Listen(1 << 20)
+		go g.Serve(l)
+		defer g.Stop()
+
+		dial := func(creds credentials.TransportCredentials) registrationpb.RegistrationClient {
+			conn, err := grpc.NewClient("passthrough:///bufnet",
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+				grpc.WithTransportCredentials(creds))
+			assert.Nil(t, err)
+			t.Cleanup(func() { conn.Close() })
+
+			return registrationpb.NewRegistrationClient(conn)
+		}
+		form := &registrationpb.Form{Email: "new@domain.zone", Password: "qwerty", PasswordConfirmation: "qwerty"}
+
+		t.Log("\ttest:0\tshould register users over tls.")
+		{
+			c := dial(credentials.NewTLS(&tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}))
+
+			u, err := c.Register(context.Background(), form)
+			assert.Nil(t, err)
+			assert.Equal(t, "new@domain.zone", u.GetEmail())
+		}
+
+		t.Log("\ttest:1\tshould reject plaintext clients.")
+		{
+			c := dial(insecure.NewCredentials())
+
+			_, err := c.Register(context.Background(), form)
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+		}
+	}
+
+	t.Log("with missing certificate.")
+	{
+		cfg := Config{TLSCert: "missing.pem", TLSKey: "missing.pem"}
+
+		t.Log("\ttest:0\tshould fail to prepare servers.")
+		{
+			_, _, err := NewServers(cfg, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+			assert.NotNil(t, err)
+		}
+	}
+}
+
+// writeTestCert writes self-signed certificate for 127.0.0.1 and its key to
+// dir and returns their paths with a pool trusting the certificate.
+func writeTestCert(t *testing.T, dir string) (string, string, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	c, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(c)
+
+	return certFile, keyFile, pool
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Transaction is a Repository whose changes are applied on Commit.
+type Transaction interface {
+	Repository
+	Commit() error
+	Rollback() error
+}
+
+// UnitOfWork begins transactions spanning several repository calls.
+type UnitOfWork interface {
+	Begin(ctx context.Context) (Transaction, error)
+}
+
+// UnitOfWorkFunc is an adapter to use a function as UnitOfWork.
+type UnitOfWorkFunc func(ctx context.Context) (Transaction, error)
+
+// Begin implements UnitOfWork.
+func (f UnitOfWorkFunc) Begin(ctx context.Context) (Transaction, error) {
+	return f(ctx)
+}
+
+// InTransaction runs fn with a transaction begun by uow, committing it when
+// fn returns nil and rolling it back when fn fails. A panic of fn rolls the
+// transaction back and is raised again. Memory stores apply changes right
+// away, so for them it only runs fn.
+func InTransaction(ctx context.Context, uow UnitOfWork, fn func(Repository) error) error {
+	tx, err := uow.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unit of work begin")
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	committed = true
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "unit of work commit")
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/audit"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	t.Log("with service registering users in sqlite transactions.")
+	{
+		store, err := storage.NewSQLiteStore(":memory:")
+		assert.Nil(t, err)
+		defer store.Close()
+		store.Hasher = fakeHasher{}
+
+		logger := &failingAudit{}
+		s := Service{
+			Validator:  noopValidator{},
+			Repository: store,
+			Audit:      logger,
+			UnitOfWork: UnitOfWorkFunc(func(ctx context.Context) (Transaction, error) {
+				tx, err := store.Begin(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return tx, nil
+			}),
+		}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould commit user when audit succeeds.")
+		{
+			u, err := s.Register(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.Nil(t, err)
+
+			found, err := store.FindByID(ctx, u.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, "new@domain.zone", found.Email)
+			assert.Len(t, logger.Entries(), 1)
+		}
+
+		t.Log("\ttest:1\tshould roll user back when audit fails.")
+		{
+			logger.err = errors.New("audit unavailable")
+
+			_, err := s.Register(ctx, testForm("other@domain.zone", "qwerty"))
+			assert.NotNil(t, err)
+
+			_, err = store.FindByEmail(ctx, "other@domain.zone")
+			assert.Equal(t, svcerrors.ErrUserNotFound, err)
+
+			n, err := store.Count(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, n)
+		}
+	}
+}
+
+func TestInTransaction(t *testing.T) {
+	t.Log("with sqlite store.")
+	{
+		store, err := storage.NewSQLiteStore(":memory:")
+		assert.Nil(t, err)
+		defer store.Close()
+		store.Hasher = fakeHasher{}
+
+		uow := UnitOfWorkFunc(func(ctx context.Context) (Transaction, error) {
+			tx, err := store.Begin(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return tx, nil
+		})
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould commit when the closure succeeds.")
+		{
+			err := InTransaction(ctx, uow, func(repo Repository) error {
+				_, err := repo.Create(ctx, testForm("new@domain.zone", "qwerty"))
+				return err
+			})
+			assert.Nil(t, err)
+
+			_, err = store.FindByEmail(ctx, "new@domain.zone")
+			assert.Nil(t, err)
+		}
+
+		t.Log("\ttest:1\tshould roll back when the closure fails.")
+		{
+			boom := errors.New("boom")
+			err := InTransaction(ctx, uow, func(repo Repository) error {
+				if _, err := repo.Create(ctx, testForm("failed@domain.zone", "qwerty")); err != nil {
+					return err
+				}
+				return boom
+			})
+			assert.Equal(t, boom, err)
+
+			_, err = store.FindByEmail(ctx, "failed@domain.zone")
+			assert.Equal(t, svcerrors.ErrUserNotFound, err)
+		}
+
+		t.Log("\ttest:2\tshould roll back and panic again when the closure panics.")
+		{
+			assert.PanicsWithValue(t, "boom", func() {
+				InTransaction(ctx, uow, func(repo Repository) error {
+					repo.Create(ctx, testForm("panicked@domain.zone", "qwerty"))
+					panic("boom")
+				})
+			})
+
+			_, err := store.FindByEmail(ctx, "panicked@domain.zone")
+			assert.Equal(t, svcerrors.ErrUserNotFound, err)
+
+			n, err := store.Count(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, n)
+		}
+
+		t.Log("\ttest:3\tshould report failure to begin.")
+		{
+			failing := UnitOfWorkFunc(func(ctx context.Context) (Transaction, error) {
+				return nil, errors.New("connection refused")
+			})
+			called := false
+			err := InTransaction(ctx, failing, func(Repository) error {
+				called = true
+				return nil
+			})
+			assert.EqualError(t, err, "unit of work begin: connection refused")
+			assert.False(t, called)
+		}
+	}
+
+	t.Log("with memory store.")
+	{
+		store := testStorage()
+		uow := UnitOfWorkFunc(func(ctx context.Context) (Transaction, error) {
+			return store.Begin(ctx)
+		})
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould run the closure on the store.")
+		{
+			err := InTransaction(ctx, uow, func(repo Repository) error {
+				_, err := repo.Create(ctx, testForm("new@domain.zone", "qwerty"))
+				return err
+			})
+			assert.Nil(t, err)
+			assert.Len(t, store.Users, 2)
+		}
+	}
+}
+
+// failingAudit records entries in memory unless err is set.
+type failingAudit struct {
+	audit.Memory
+	err error
+}
+
+func (a *failingAudit) Record(ctx context.Context, e audit.Entry) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	return a.Memory.Record(ctx, e)
+}
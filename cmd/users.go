@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/transport"
+	"github.com/pkg/errors"
+)
+
+// UserFinder abstraction for loading users.
+type UserFinder interface {
+	FindByID(ctx context.Context, id string) (*entities.User, error)
+}
+
+// UserHandler for user requests.
+type UserHandler struct {
+	Finder UserFinder
+}
+
+// ServeHTTP implements http.Handler.
+func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := h.Finder.FindByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(transport.NewUserResponse(u.Safe()))
+}
+
+// MeHandler for requests of the authenticated user for their own profile.
+type MeHandler struct {
+	Finder UserFinder
+}
+
+// ServeHTTP implements http.Handler, the user id is set by WithAuthentication.
+func (h *MeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := UserIDFromContext(r.Context())
+	if id == "" {
+		writeError(w, http.StatusUnauthorized, ErrorBody{Code: CodeUnauthorized, Message: "missing access token"})
+		return
+	}
+
+	u, err := h.Finder.FindByID(r.Context(), id)
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(transport.NewUserResponse(u.Safe()))
+}
+
+// UserDeleter abstraction for removing users.
+type UserDeleter interface {
+	Delete(ctx context.Context, id string) error
+}
+
+// DeleteUserHandler for user removal requests.
+type DeleteUserHandler struct {
+	Deleter UserDeleter
+}
+
+// ServeHTTP implements http.Handler.
+func (h *DeleteUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.Deleter.Delete(r.Context(), r.PathValue("id")); err != nil {
+		encodeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UserUpdater abstraction for changing user details.
+type UserUpdater interface {
+	Update(ctx context.Context, id string, f *entities.Form) (*entities.User, error)
+}
+
+// Update holds user update domain logic.
+func (s *Service) Update(ctx context.Context, id string, f *entities.Form) (*entities.User, error) {
+	user, err := s.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "repository find by id")
+	}
+
+	f.Email = entities.NormalizeEmail(f.Email, s.LowercaseEmail)
+
+	if err := s.Validator.ValidateUpdate(ctx, user, f); err != nil {
+		return nil, errors.Wrap(err, "validator validate update")
+	}
+
+	// updates resubmit the current password unless they change it.
+	if s.keepsHistory() && s.Hasher.Compare(user.Password, f.Password) != nil {
+		if err := s.checkHistory(ctx, id, f.Password); err != nil {
+			return nil, err
+		}
+
+		if err := s.rememberPassword(ctx, id, user.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err = s.Repository.Update(ctx, id, f)
+	if err != nil {
+		return nil, errors.Wrap(err, "repository update")
+	}
+
+	return user, nil
+}
+
+// UpdateUserHandler for user update requests.
+type UpdateUserHandler struct {
+	Updater UserUpdater
+	// MaxBodyBytes limits request body size, DefaultMaxBodyBytes is used when zero.
+	MaxBodyBytes int64
+}
+
+// ServeHTTP implements http.Handler.
+func (h *UpdateUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, ok := decodeForm(w, r, h.MaxBodyBytes, nil)
+	if !ok {
+		return
+	}
+
+	u, err := h.Updater.Update(r.Context(), r.PathValue("id"), f)
+	if err != nil {
+		encodeErrorFor(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(transport.NewUserResponse(u.Safe()))
+}
+
+// Pagination limits of user listing.
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// UserLister abstraction for listing users.
+type UserLister interface {
+	List(ctx context.Context, offset, limit int) ([]entities.User, error)
+	Count(ctx context.Context) (int, error)
+}
+
+// ListUsersHandler for user listing requests.
+type ListUsersHandler struct {
+	Lister UserLister
+}
+
+// ServeHTTP implements http.Handler.
+func (h *ListUsersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		writeError(w, http.StatusBadRequest, ErrorBody{Code: CodeInvalidQuery, Message: "page must be a positive integer"})
+		return
+	}
+
+	limit, err := queryInt(r, "limit", DefaultListLimit)
+	if err != nil || limit < 1 {
+		writeError(w, http.StatusBadRequest, ErrorBody{Code: CodeInvalidQuery, Message: "limit must be a positive integer"})
+		return
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	users, err := h.Lister.List(r.Context(), (page-1)*limit, limit)
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+
+	total, err := h.Lister.Count(r.Context())
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(transport.NewUserListResponse(users, total, page, limit))
+}
+
+// queryInt parses integer query parameter or returns def when it is missing.
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(v)
+}
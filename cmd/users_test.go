@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/token"
+	"github.com/newtondev/service_object/pkg/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsers(t *testing.T) {
+	t.Log("with initialized server.")
+	{
+		srv, err := NewServer(Config{JWTSecret: testSecret}, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		do := func(method, path, auth string) *http.Response {
+			req, err := http.NewRequest(method, s.URL+path, nil)
+			assert.Nil(t, err)
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.Nil(t, err)
+			return resp
+		}
+
+		t.Log("\ttest:0\tshould return existing user without password.")
+		{
+			resp := do("GET", "/users/1", bearer(t, entities.RoleUser))
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.Nil(t, err)
+			assert.JSONEq(t, `{"id":"1","email":"exists@domain.zone","verified":false}`, string(body))
+		}
+
+		t.Log("\ttest:1\tshould return not found for missing user.")
+		{
+			resp := do("GET", "/users/42", bearer(t, entities.RoleAdmin))
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		}
+
+		t.Log("\ttest:2\tshould return not found for invalid id.")
+		{
+			resp := do("GET", "/users/invalid", bearer(t, entities.RoleAdmin))
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		}
+
+		t.Log("\ttest:3\tshould return profile names of registered user.")
+		{
+			resp, err := http.Post(fmt.Sprintf("%s/register", s.URL), "application/json", strings.NewReader(`{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty", "first_name": "Ada", "last_name": "Lovelace"}`))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+			resp = do("GET", resp.Header.Get("Location"), resp.Header.Get("Authorization"))
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.Nil(t, err)
+			assert.JSONEq(t, `{"id":"2","email":"new@domain.zone","verified":false,"role":"user","first_name":"Ada","last_name":"Lovelace","created_at":"2020-01-02T03:04:05Z","updated_at":"2020-01-02T03:04:05Z"}`, string(body))
+		}
+
+		t.Log("\ttest:4\tshould reject requests without token.")
+		{
+			for _, method := range []string{"GET", "PUT", "DELETE"} {
+				resp := do(method, "/users/1", "")
+				assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, method)
+			}
+		}
+
+		t.Log("\ttest:5\tshould forbid users reaching another account.")
+		{
+			for _, method := range []string{"GET", "PUT", "DELETE"} {
+				resp := do(method, "/users/2", bearer(t, entities.RoleUser))
+				assert.Equal(t, http.StatusForbidden, resp.StatusCode, method)
+
+				var e ErrorResponse
+				assert.Nil(t, json.NewDecoder(resp.Body).Decode(&e))
+				assert.Equal(t, CodeForbidden, e.Error.Code)
+			}
+		}
+
+		t.Log("\ttest:6\tshould let admins reach any account.")
+		{
+			resp := do("GET", "/users/2", bearer(t, entities.RoleAdmin))
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+
+		t.Log("\ttest:7\tshould delete existing user.")
+		{
+			resp := do("DELETE", "/users/1", bearer(t, entities.RoleUser))
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		}
+
+		t.Log("\ttest:8\tshould return not found deleting missing user.")
+		{
+			resp := do("DELETE", "/users/1", bearer(t, entities.RoleUser))
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		}
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	t.Log("with three registered users.")
+	{
+		repo := testStorage()
+		for _, email := range []string{"a@domain.zone", "b@domain.zone"} {
+			_, err := repo.Create(context.Background(), testForm(email, "qwerty"))
+			assert.Nil(t, err)
+		}
+
+		srv, err := NewServer(Config{JWTSecret: testSecret}, ioutil.Discard, repo, prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		admin := bearer(t, entities.RoleAdmin)
+		get := func(query, auth string) *http.Response {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/users%s", s.URL, query), nil)
+			assert.Nil(t, err)
+			req.Header.Set("Authorization", auth)
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.Nil(t, err)
+			return resp
+		}
+
+		list := func(query string) (int, transport.UserListResponse) {
+			resp := get(query, admin)
+
+			var l transport.UserListResponse
+			if resp.StatusCode == http.StatusOK {
+				assert.Nil(t, json.NewDecoder(resp.Body).Decode(&l))
+			}
+
+			return resp.StatusCode, l
+		}
+
+		t.Log("\ttest:0\tshould return first page without passwords.")
+		{
+			resp := get("?limit=2", admin)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.Nil(t, err)
+			assert.JSONEq(t, `{"users":[{"id":"1","email":"exists@domain.zone","verified":false},{"id":"2","email":"a@domain.zone","verified":false,"role":"user","created_at":"2020-01-02T03:04:05Z","updated_at":"2020-01-02T03:04:05Z"}],"total":3,"page":1,"limit":2}`, string(body))
+		}
+
+		t.Log("\ttest:1\tshould return the rest on the next page.")
+		{
+			code, l := list("?page=2&limit=2")
+			assert.Equal(t, http.StatusOK, code)
+			assert.Len(t, l.Users, 1)
+			assert.Equal(t, "b@domain.zone", l.Users[0].Email)
+		}
+
+		t.Log("\ttest:2\tshould return empty page for out of range offset.")
+		{
+			resp := get("?page=5", admin)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.Nil(t, err)
+			assert.JSONEq(t, `{"users":[],"total":3,"page":5,"limit":20}`, string(body))
+		}
+
+		t.Log("\ttest:3\tshould clamp limit to the maximum.")
+		{
+			code, l := list("?limit=1000")
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, MaxListLimit, l.Limit)
+			assert.Len(t, l.Users, 3)
+		}
+
+		t.Log("\ttest:4\tshould reject invalid page and limit.")
+		{
+			for _, q := range []string{"?page=0", "?page=x", "?limit=0", "?limit=-1"} {
+				code, _ := list(q)
+				assert.Equal(t, http.StatusBadRequest, code, q)
+			}
+		}
+
+		t.Log("\ttest:5\tshould forbid listing to regular users.")
+		{
+			resp := get("", bearer(t, entities.RoleUser))
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&e))
+			assert.Equal(t, CodeForbidden, e.Error.Code)
+		}
+
+		t.Log("\ttest:6\tshould require a valid access token.")
+		{
+			for _, auth := range []string{"", "Bearer invalid"} {
+				resp := get("", auth)
+				assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, auth)
+			}
+		}
+
+		t.Log("\ttest:7\tshould count only live users after a delete.")
+		{
+			req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/users/2", s.URL), nil)
+			assert.Nil(t, err)
+			req.Header.Set("Authorization", bearer(t, entities.RoleAdmin))
+			resp, err := http.DefaultClient.Do(req)
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+			code, l := list("")
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, 2, l.Total)
+			assert.Len(t, l.Users, 2)
+		}
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	t.Log("with two registered users.")
+	{
+		repo := testStorage()
+		_, err := repo.Create(context.Background(), testForm("other@domain.zone", "qwerty"))
+		assert.Nil(t, err)
+
+		srv, err := NewServer(Config{JWTSecret: testSecret}, ioutil.Discard, repo, prometheus.NewRegistry())
+		assert.Nil(t, err)
+		s := httptest.NewServer(srv.Handler)
+		defer s.Close()
+
+		put := func(path, body string) *http.Response {
+			req, err := http.NewRequest("PUT", s.URL+path, strings.NewReader(body))
+			assert.Nil(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", bearer(t, entities.RoleAdmin))
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.Nil(t, err)
+			return resp
+		}
+
+		t.Log("\ttest:0\tshould update email and password.")
+		{
+			resp := put("/users/1", `{"email": "changed@domain.zone", "password": "newpass", "password_confirmation": "newpass"}`)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.Nil(t, err)
+			assert.JSONEq(t, `{"id":"1","email":"changed@domain.zone","verified":false,"updated_at":"2020-01-02T03:04:05Z"}`, string(body))
+			assert.Equal(t, "hashed:newpass", repo.Users[0].Password)
+		}
+
+		t.Log("\ttest:1\tshould allow keeping the same email.")
+		{
+			resp := put("/users/1", `{"email": "changed@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+
+		t.Log("\ttest:2\tshould reject email of another user.")
+		{
+			resp := put("/users/1", `{"email": "other@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+			var e ErrorResponse
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&e))
+			assert.Equal(t, constants.EmailExists, e.Error.Fields["email"])
+		}
+
+		t.Log("\ttest:3\tshould return not found for missing user.")
+		{
+			resp := put("/users/42", `{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		}
+	}
+}
+
+// bearer returns Authorization header value with a token for role.
+func bearer(t *testing.T, role string) string {
+	tok, err := token.NewJWT(testSecret, 0).Generate(&entities.User{ID: "1", Role: role})
+	assert.Nil(t, err)
+
+	return "Bearer " + tok
+}
+
+func TestMe(t *testing.T) {
+	t.Log("with registered user.")
+	{
+		srv, err := NewServer(Config{JWTSecret: testSecret}, ioutil.Discard, testStorage(), prometheus.NewRegistry())
+		assert.Nil(t, err)
+
+		me := func(auth string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "/users/me", nil)
+			if auth != "" {
+				r.Header.Set("Authorization", auth)
+			}
+
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, r)
+			return w
+		}
+
+		t.Log("\ttest:0\tshould return the token user without password.")
+		{
+			w := me(bearer(t, entities.RoleUser))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"id":"1","email":"exists@domain.zone","verified":false}`, w.Body.String())
+		}
+
+		t.Log("\ttest:1\tshould reject expired token.")
+		{
+			jwt := token.NewJWT(testSecret, time.Hour)
+			jwt.Clock = &fakeClock{now: time.Now().Add(-2 * time.Hour)}
+			tok, err := jwt.Generate(&entities.User{ID: "1", Role: entities.RoleUser})
+			assert.Nil(t, err)
+
+			w := me("Bearer " + tok)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.JSONEq(t, `{"error":{"code":"unauthorized","message":"invalid access token"}}`, w.Body.String())
+		}
+
+		t.Log("\ttest:2\tshould reject missing token.")
+		{
+			w := me("")
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.JSONEq(t, `{"error":{"code":"unauthorized","message":"missing access token"}}`, w.Body.String())
+		}
+
+		t.Log("\ttest:3\tshould return not found for token of a deleted user.")
+		{
+			tok, err := token.NewJWT(testSecret, 0).Generate(&entities.User{ID: "42", Role: entities.RoleUser})
+			assert.Nil(t, err)
+
+			w := me("Bearer " + tok)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/newtondev/service_object/pkg/constants"
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/i18n"
+	"github.com/newtondev/service_object/pkg/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+func TestPlayValidatorBlockedDomains(t *testing.T) {
+	t.Log("with blocked disposable domains.")
+	{
+		domains, err := readDomains(strings.NewReader("# disposable\nMailinator.com\n\ntrashmail.zone\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"mailinator.com", "trashmail.zone"}, domains)
+
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage(), BlockedDomains: domains}
+
+		t.Log("\ttest:0\tshould reject email of a blocked domain.")
+		{
+			err := v.Validate(context.Background(), testForm("new@mailinator.com", "qwerty"))
+			assert.Equal(t, ValidationErrors{"email": constants.DisposableEmail}, validationMap(err))
+		}
+
+		t.Log("\ttest:1\tshould accept email of other domain.")
+		{
+			assert.Nil(t, v.Validate(context.Background(), testForm("new@domain.zone", "qwerty")))
+		}
+	}
+}
+
+func TestPlayValidatorAllowedDomains(t *testing.T) {
+	t.Log("with allowed corporate domains.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage(), AllowedDomains: []string{"corp.zone", "Subsidiary.zone"}}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould accept email of an allowed domain.")
+		{
+			assert.Nil(t, v.Validate(ctx, testForm("new@corp.zone", "qwerty")))
+			assert.Nil(t, v.Validate(ctx, testForm("new@subsidiary.ZONE", "qwerty")))
+		}
+
+		t.Log("\ttest:1\tshould reject email of other domain.")
+		{
+			assert.Equal(t, ValidationErrors{"email": constants.DomainNotAllowed}, validationMap(v.Validate(ctx, testForm("new@domain.zone", "qwerty"))))
+		}
+
+		t.Log("\ttest:2\tshould report malformed email as invalid.")
+		{
+			assert.Equal(t, ValidationErrors{"email": i18n.Message(constants.Invalid, "email")}, validationMap(v.Validate(ctx, testForm("invalid", "qwerty"))))
+		}
+	}
+
+	t.Log("with no allowed domains.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage()}
+
+		t.Log("\ttest:0\tshould accept every domain.")
+		{
+			assert.Nil(t, v.Validate(context.Background(), testForm("new@domain.zone", "qwerty")))
+		}
+	}
+}
+
+func TestPlayValidatorEntropy(t *testing.T) {
+	t.Log("with min entropy of 40 bits.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage(), MinEntropy: 40}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould reject a low-entropy password.")
+		{
+			assert.Equal(t, ValidationErrors{"password": constants.PasswordWeak}, validationMap(v.Validate(ctx, testForm("new@domain.zone", "password1"))))
+			assert.Equal(t, ValidationErrors{"password": constants.PasswordWeak}, validationMap(v.ValidatePassword(ctx, "password1")))
+		}
+
+		t.Log("\ttest:1\tshould accept a high-entropy password.")
+		{
+			assert.Nil(t, v.Validate(ctx, testForm("new@domain.zone", "kX9#vQ2$mL7!pR4@")))
+			assert.Nil(t, v.ValidatePassword(ctx, "kX9#vQ2$mL7!pR4@"))
+		}
+
+		t.Log("\ttest:2\tshould report length before strength.")
+		{
+			assert.Equal(t, ValidationErrors{"password": i18n.Message(constants.PasswordLength, DefaultMinPassword, DefaultMaxPassword)}, validationMap(v.Validate(ctx, testForm("new@domain.zone", "qw"))))
+		}
+
+		t.Log("\ttest:3\tshould use the estimator when set.")
+		{
+			v.Estimator = fixedEstimator(100)
+			assert.Nil(t, v.Validate(ctx, testForm("new@domain.zone", "password1")))
+		}
+	}
+
+	t.Log("with no min entropy.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage()}
+
+		t.Log("\ttest:0\tshould accept a low-entropy password.")
+		{
+			assert.Nil(t, v.Validate(context.Background(), testForm("new@domain.zone", "password1")))
+		}
+	}
+}
+
+// fixedEstimator estimates every password at its value.
+type fixedEstimator float64
+
+func (e fixedEstimator) Entropy(password string) float64 {
+	return float64(e)
+}
+
+func TestPlayValidatorPasswordConfirmation(t *testing.T) {
+	t.Log("with play validator.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage()}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould report missing confirmation once.")
+		{
+			f := testForm("new@domain.zone", "qwerty")
+			f.PasswordConfirmation = ""
+			assert.Equal(t, ValidationErrors{"password_confirmation": constants.Required}, validationMap(v.Validate(ctx, f)))
+		}
+
+		t.Log("\ttest:1\tshould treat whitespace-only confirmation as missing.")
+		{
+			f := testForm("new@domain.zone", "qwerty")
+			f.PasswordConfirmation = "   "
+			assert.Equal(t, ValidationErrors{"password_confirmation": constants.Required}, validationMap(v.Validate(ctx, f)))
+		}
+
+		t.Log("\ttest:2\tshould ignore trailing whitespace when comparing.")
+		{
+			f := testForm("new@domain.zone", "qwerty")
+			f.PasswordConfirmation = "qwerty \n"
+			assert.Nil(t, v.Validate(ctx, f))
+		}
+
+		t.Log("\ttest:3\tshould report genuine mismatch.")
+		{
+			f := testForm("new@domain.zone", "qwerty")
+			f.PasswordConfirmation = "qwertz"
+			assert.Equal(t, ValidationErrors{"password": constants.PasswordMismatch}, validationMap(v.Validate(ctx, f)))
+		}
+	}
+}
+
+func TestPlayValidatorPasswordLength(t *testing.T) {
+	t.Log("with 8 to 128 characters password policy.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage(), MinPassword: 8, MaxPassword: 128}
+		ctx := context.Background()
+		msg := i18n.Message(constants.PasswordLength, 8, 128)
+
+		t.Log("\ttest:0\tshould accept passwords on the bounds.")
+		{
+			assert.Nil(t, v.Validate(ctx, testForm("new@domain.zone", strings.Repeat("a", 8))))
+			assert.Nil(t, v.Validate(ctx, testForm("new@domain.zone", strings.Repeat("a", 128))))
+			assert.Nil(t, v.ValidatePassword(ctx, strings.Repeat("a", 8)))
+		}
+
+		t.Log("\ttest:1\tshould reject passwords just outside the bounds.")
+		{
+			assert.Equal(t, ValidationErrors{"password": msg}, validationMap(v.Validate(ctx, testForm("new@domain.zone", strings.Repeat("a", 7)))))
+			assert.Equal(t, ValidationErrors{"password": msg}, validationMap(v.Validate(ctx, testForm("new@domain.zone", strings.Repeat("a", 129)))))
+			assert.Equal(t, ValidationErrors{"password": msg}, validationMap(v.ValidatePassword(ctx, strings.Repeat("a", 7))))
+		}
+
+		t.Log("\ttest:2\tshould count characters rather than bytes.")
+		{
+			assert.Nil(t, v.Validate(ctx, testForm("new@domain.zone", strings.Repeat("ä", 8))))
+		}
+
+		t.Log("\ttest:3\tshould report mismatch of passwords within the bounds.")
+		{
+			f := testForm("new@domain.zone", "qwertyuiop")
+			f.PasswordConfirmation = "qwertyuiox"
+			assert.Equal(t, ValidationErrors{"password": constants.PasswordMismatch}, validationMap(v.Validate(ctx, f)))
+		}
+
+		t.Log("\ttest:4\tshould report length before mismatch.")
+		{
+			f := testForm("new@domain.zone", "qwerty")
+			f.PasswordConfirmation = "qwertyuiop"
+			assert.Equal(t, ValidationErrors{"password": msg}, validationMap(v.Validate(ctx, f)))
+		}
+	}
+
+	t.Log("with default bounds.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage()}
+
+		t.Log("\ttest:0\tshould keep 3 to 16 characters.")
+		{
+			assert.Nil(t, v.ValidatePassword(context.Background(), "abc"))
+			assert.NotNil(t, v.ValidatePassword(context.Background(), "ab"))
+			assert.NotNil(t, v.ValidatePassword(context.Background(), strings.Repeat("a", 17)))
+		}
+	}
+}
+
+func TestPlayValidatorNames(t *testing.T) {
+	t.Log("with names required.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage(), RequireNames: true, MaxName: 8}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould require first and last name.")
+		{
+			f := testForm("new@domain.zone", "qwerty")
+			f.LastName = " "
+			assert.Equal(t, ValidationErrors{"first_name": constants.Required, "last_name": constants.Required}, validationMap(v.Validate(ctx, f)))
+		}
+
+		t.Log("\ttest:1\tshould accept names within the max length.")
+		{
+			f := testForm("new@domain.zone", "qwerty")
+			f.FirstName, f.LastName, f.DisplayName = "Jürgen", "Schmidt", "jschmidt"
+			assert.Nil(t, v.Validate(ctx, f))
+		}
+
+		t.Log("\ttest:2\tshould reject names over the max length.")
+		{
+			f := testForm("new@domain.zone", "qwerty")
+			f.FirstName, f.LastName, f.DisplayName = "Jürgen", "Schmidtbauer", "j.schmidtbauer"
+			msg := i18n.Message(constants.NameLength, 8)
+			assert.Equal(t, ValidationErrors{"last_name": msg, "display_name": msg}, validationMap(v.Validate(ctx, f)))
+		}
+	}
+
+	t.Log("with names optional.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage()}
+
+		t.Log("\ttest:0\tshould accept form without names.")
+		{
+			assert.Nil(t, v.Validate(context.Background(), testForm("new@domain.zone", "qwerty")))
+		}
+	}
+}
+
+func TestPlayValidatorPasswordEmail(t *testing.T) {
+	t.Log("with default validator.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage()}
+		ctx := context.Background()
+		want := ValidationErrors{"password": constants.PasswordEmail}
+
+		t.Log("\ttest:0\tshould reject password equal to the email.")
+		{
+			assert.Equal(t, want, validationMap(v.Validate(ctx, testForm("new@domain.zone", "new@domain.zone"))))
+			assert.Equal(t, want, validationMap(v.Validate(ctx, testForm("new@domain.zone", "NEW@Domain.zone"))))
+		}
+
+		t.Log("\ttest:1\tshould reject password equal to the email local part.")
+		{
+			assert.Equal(t, want, validationMap(v.Validate(ctx, testForm("johnny@domain.zone", "Johnny"))))
+		}
+
+		t.Log("\ttest:2\tshould accept password only containing the local part.")
+		{
+			assert.Nil(t, v.Validate(ctx, testForm("johnny@domain.zone", "johnny1")))
+			assert.Nil(t, v.Validate(ctx, testForm("johnny@domain.zone", "domain.zone")))
+		}
+
+		t.Log("\ttest:3\tshould report mismatch before the email match.")
+		{
+			f := testForm("johnny@domain.zone", "johnny")
+			f.PasswordConfirmation = "johnnz"
+			assert.Equal(t, ValidationErrors{"password": constants.PasswordMismatch}, validationMap(v.Validate(ctx, f)))
+		}
+	}
+}
+
+func TestPlayValidatorUsername(t *testing.T) {
+	t.Log("with user registered with a username.")
+	{
+		repo := testStorage()
+		ctx := context.Background()
+		taken := testForm("taken@domain.zone", "qwerty")
+		taken.Username = "taken"
+		u, err := repo.Create(ctx, taken)
+		assert.Nil(t, err)
+
+		v := PlayValidator{Validator: validator.New(), Repository: repo}
+		form := func(email, username string) *entities.Form {
+			f := testForm(email, "qwerty")
+			f.Username = username
+			return f
+		}
+
+		t.Log("\ttest:0\tshould reject duplicate username with unique email.")
+		{
+			assert.Equal(t, ValidationErrors{"username": constants.UsernameExists}, validationMap(v.Validate(ctx, form("new@domain.zone", "taken"))))
+		}
+
+		t.Log("\ttest:1\tshould reject duplicate email with unique username.")
+		{
+			assert.Equal(t, ValidationErrors{"email": constants.EmailExists}, validationMap(v.Validate(ctx, form("taken@domain.zone", "fresh"))))
+		}
+
+		t.Log("\ttest:2\tshould report both collisions.")
+		{
+			assert.Equal(t, ValidationErrors{"email": constants.EmailExists, "username": constants.UsernameExists}, validationMap(v.Validate(ctx, form("taken@domain.zone", "taken"))))
+		}
+
+		t.Log("\ttest:3\tshould accept unique or missing username.")
+		{
+			assert.Nil(t, v.Validate(ctx, form("new@domain.zone", "fresh")))
+			assert.Nil(t, v.Validate(ctx, form("new@domain.zone", "")))
+		}
+
+		t.Log("\ttest:4\tshould not check username on update.")
+		{
+			assert.Nil(t, v.ValidateUpdate(ctx, u, form("taken@domain.zone", "taken")))
+		}
+	}
+}
+
+func TestPlayValidatorBreaches(t *testing.T) {
+	t.Log("with breach checker.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage(), Breaches: fakeBreaches{"qwerty"}}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould reject breached password.")
+		{
+			assert.Equal(t, ValidationErrors{"password": constants.BreachedPassword}, validationMap(v.Validate(ctx, testForm("new@domain.zone", "qwerty"))))
+			assert.Equal(t, ValidationErrors{"password": constants.BreachedPassword}, validationMap(v.ValidatePassword(ctx, "qwerty")))
+		}
+
+		t.Log("\ttest:1\tshould accept clean password.")
+		{
+			assert.Nil(t, v.Validate(ctx, testForm("new@domain.zone", "s3cure")))
+			assert.Nil(t, v.ValidatePassword(ctx, "s3cure"))
+		}
+
+		t.Log("\ttest:2\tshould report mismatch before the breach.")
+		{
+			f := testForm("new@domain.zone", "qwerty")
+			f.PasswordConfirmation = "qwertz"
+			assert.Equal(t, ValidationErrors{"password": constants.PasswordMismatch}, validationMap(v.Validate(ctx, f)))
+		}
+
+		t.Log("\ttest:3\tshould return checker failures.")
+		{
+			v.Breaches = fakeBreaches{}
+			err := v.Validate(ctx, testForm("new@domain.zone", "unavailable"))
+			assert.NotNil(t, err)
+			assert.Equal(t, "breach service unavailable", errors.Cause(err).Error())
+		}
+	}
+}
+
+// fakeBreaches is a BreachChecker knowing passwords listed in it, password
+// "unavailable" fails the check.
+type fakeBreaches []string
+
+func (b fakeBreaches) Breached(ctx context.Context, password string) (bool, error) {
+	if password == "unavailable" {
+		return false, errors.New("breach service unavailable")
+	}
+
+	for _, p := range b {
+		if p == password {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func TestPlayValidatorEmail(t *testing.T) {
+	t.Log("with play validator.")
+	{
+		v := PlayValidator{Validator: validator.New(), Repository: testStorage()}
+		ctx := context.Background()
+		local := strings.Repeat("a", MaxEmailLocalLength)
+		label := strings.Repeat("d", 60) + "."
+		// 254 characters, the longest accepted email.
+		longest := local + "@" + label + label + strings.Repeat("d", 62) + ".zone"
+
+		tests := []struct {
+			email string
+			msg   string
+		}{
+			{email: "new@domain.zone"},
+			{email: "first.last@sub.domain.zone"},
+			{email: local + "@domain.zone"},
+			{email: local + "a@domain.zone", msg: i18n.Message(constants.EmailLocalLength, MaxEmailLocalLength)},
+			{email: longest},
+			{email: "d" + longest, msg: i18n.Message(constants.EmailLength, MaxEmailLength)},
+			{email: `"first..last"@domain.zone`, msg: constants.EmailDots},
+			{email: `".first"@domain.zone`, msg: constants.EmailDots},
+			{email: `"first."@domain.zone`, msg: constants.EmailDots},
+			{email: "new@domain.z", msg: constants.EmailTLD},
+			{email: "first..last@domain.zone", msg: i18n.Message(constants.Invalid, "email")},
+			{email: ".first@domain.zone", msg: i18n.Message(constants.Invalid, "email")},
+			{email: "new@localhost", msg: i18n.Message(constants.Invalid, "email")},
+		}
+
+		for i, tt := range tests {
+			t.Logf("\ttest:%d\tshould validate %q.", i, tt.email)
+			{
+				err := v.Validate(ctx, testForm(tt.email, "qwerty"))
+				if tt.msg == "" {
+					assert.Nil(t, err)
+					continue
+				}
+
+				assert.Equal(t, ValidationErrors{"email": tt.msg}, validationMap(err))
+			}
+		}
+	}
+}
+
+func TestPlayValidatorUniqueFailure(t *testing.T) {
+	t.Log("with storage failing uniqueness checks.")
+	{
+		repo := &failingStorage{MemStore: testStorage(), err: errors.New("connection reset by peer")}
+		logger := &fakeLogger{}
+		v := &PlayValidator{Validator: validator.New(), Repository: repo, Logger: logger}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould report storage unavailable keeping the cause.")
+		{
+			err := v.Validate(ctx, testForm("new@domain.zone", "qwerty"))
+			assert.True(t, errors.Is(err, svcerrors.ErrStorageUnavailable))
+			assert.True(t, errors.Is(err, repo.err))
+
+			var e ValidationErrors
+			assert.False(t, errors.As(err, &e))
+		}
+
+		t.Log("\ttest:1\tshould log the failure.")
+		{
+			assert.Len(t, logger.errors, 1)
+			assert.Equal(t, "connection reset by peer", logger.errors[0]["error"])
+		}
+
+		t.Log("\ttest:2\tshould respond with service unavailable.")
+		{
+			h := RegistrationHandler{Registrator: &Service{Validator: v, Repository: repo}}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, jsonRequest("POST", "/register", `{"email": "new@domain.zone", "password": "qwerty", "password_confirmation": "qwerty"}`))
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.JSONEq(t, `{"error":{"code":"unavailable","message":"storage temporarily unavailable"}}`, w.Body.String())
+			assert.Len(t, repo.MemStore.Users, 1)
+		}
+
+		t.Log("\ttest:3\tshould report invalid forms as validation failures.")
+		{
+			assert.Equal(t, ValidationErrors{"email": i18n.Message(constants.Invalid, "email")}, validationMap(v.Validate(ctx, testForm("invalid", "qwerty"))))
+		}
+	}
+}
+
+// validationMap returns the map view of validation errors in err.
+func validationMap(err error) ValidationErrors {
+	var v ValidationErrors
+	errors.As(err, &v)
+	return v
+}
+
+// failingStorage fails uniqueness checks with err when it is set.
+type failingStorage struct {
+	*storage.MemStore
+	err error
+}
+
+func (s *failingStorage) Unique(ctx context.Context, email string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	return s.MemStore.Unique(ctx, email)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/pkg/errors"
+)
+
+// OneTimeTokens issues and consumes single-use user tokens.
+type OneTimeTokens interface {
+	Issue(userID string) (string, error)
+	Peek(token string) (string, error)
+	Consume(token string) (string, error)
+}
+
+// VerificationNotifier delivers verification tokens to users.
+type VerificationNotifier interface {
+	NotifyVerification(ctx context.Context, u *entities.User, token string)
+}
+
+// Verifier abstraction for email verification.
+type Verifier interface {
+	Verify(ctx context.Context, token string) error
+}
+
+// GenerateVerification issues a verification token for the user.
+func (s *Service) GenerateVerification(ctx context.Context, u *entities.User) (string, error) {
+	return s.Verifications.Issue(u.ID)
+}
+
+// Verify marks user of the token as verified.
+func (s *Service) Verify(ctx context.Context, token string) error {
+	if s.Verifications == nil {
+		return svcerrors.ErrInvalidToken
+	}
+
+	id, err := s.Verifications.Consume(token)
+	if err != nil {
+		return err
+	}
+
+	if err := s.SetVerified(ctx, id); err != nil {
+		return errors.Wrap(err, "repository set verified")
+	}
+
+	return nil
+}
+
+// VerifyHandler for email verification requests.
+type VerifyHandler struct {
+	Verifier
+}
+
+// ServeHTTP implements http.Handler.
+func (h *VerifyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.Verify(r.Context(), r.URL.Query().Get("token")); err != nil {
+		encodeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
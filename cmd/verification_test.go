@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerification(t *testing.T) {
+	t.Log("with service issuing verification tokens.")
+	{
+		repo := testStorage()
+		srv := &Service{
+			Validator:     noopValidator{},
+			Repository:    repo,
+			Verifications: token.NewOneTime(&sequenceGenerator{}, time.Hour),
+		}
+		s := httptest.NewServer(&VerifyHandler{Verifier: srv})
+		defer s.Close()
+
+		m := &fakeMailer{}
+		rw := NewRegistratorWithWelcome(srv, m, &fakeLogger{}, 1, 1)
+		rw.VerifyURL = s.URL + "/verify"
+		srv.VerificationNotifier = rw
+
+		u, err := srv.Register(context.Background(), testForm("new@domain.zone", "qwerty"))
+		assert.Nil(t, err)
+		assert.False(t, u.Verified)
+		rw.Close()
+
+		t.Log("\ttest:0\tshould email the verification link to the user.")
+		{
+			assert.Len(t, m.sent, 1)
+			assert.Equal(t, "new@domain.zone", m.sent[0].to)
+			assert.Equal(t, VerifySubject, m.sent[0].subject)
+			assert.Contains(t, m.sent[0].body, s.URL+"/verify?token=token-1")
+		}
+
+		t.Log("\ttest:1\tshould verify user with the emailed link.")
+		{
+			link := strings.TrimSpace(m.sent[0].body[strings.LastIndex(m.sent[0].body, "\n\n"):])
+			resp, err := http.Get(link)
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+			u, err := repo.FindByID(context.Background(), u.ID)
+			assert.Nil(t, err)
+			assert.True(t, u.Verified)
+		}
+
+		t.Log("\ttest:2\tshould reject reused token.")
+		{
+			resp, err := http.Get(fmt.Sprintf("%s/verify?token=token-1", s.URL))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+
+		t.Log("\ttest:3\tshould reject unknown token.")
+		{
+			resp, err := http.Get(fmt.Sprintf("%s/verify?token=unknown", s.URL))
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+// sequenceGenerator generates deterministic tokens.
+type sequenceGenerator struct {
+	n int
+}
+
+func (g *sequenceGenerator) Generate() (string, error) {
+	g.n++
+	return fmt.Sprintf("token-%d", g.n), nil
+}
+
+// noopValidator accepts every form.
+type noopValidator struct{}
+
+func (noopValidator) Validate(context.Context, *entities.Form) error {
+	return nil
+}
+
+func (noopValidator) ValidateUpdate(context.Context, *entities.User, *entities.Form) error {
+	return nil
+}
+
+func (noopValidator) ValidatePassword(context.Context, string) error {
+	return nil
+}
@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Registration outcomes.
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+// Entry is a recorded registration attempt.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Email    string    `json:"email"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+	SourceIP string    `json:"source_ip"`
+}
+
+// Memory is an append-only audit trail kept in memory.
+type Memory struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewMemory prepares empty in-memory audit trail.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Record appends the entry.
+func (m *Memory) Record(ctx context.Context, e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries = append(m.entries, e)
+
+	return nil
+}
+
+// Entries returns a copy of recorded entries in order.
+func (m *Memory) Entries() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Entry(nil), m.entries...)
+}
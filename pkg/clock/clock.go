@@ -0,0 +1,27 @@
+// Package clock tells current time, tests can replace it to get stable
+// timestamps and exercise expiry.
+package clock
+
+import "time"
+
+// Clock tells current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is a Clock backed by time.Now.
+type Real struct{}
+
+// Now implements Clock.
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// Now returns time of c falling back to the real clock when c is nil.
+func Now(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+
+	return c.Now()
+}
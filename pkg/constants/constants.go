@@ -3,5 +3,23 @@ package constants
 const (
 	PasswordMismatch = "password mismatch"
 	EmailExists      = "email exists"
+	UsernameExists   = "username exists"
 	ValidationMsg    = "you have validation errors"
-)
\ No newline at end of file
+	DisposableEmail  = "disposable email not allowed"
+	DomainNotAllowed = "email domain not allowed"
+	Required         = "required"
+	PasswordLength   = "password must be between %v and %v characters"
+	NameLength       = "must be at most %v characters"
+	Invalid          = "%v is invalid"
+	BreachedPassword = "this password has appeared in a data breach"
+	PasswordEmail    = "password must not match your email"
+	PasswordReused   = "password was used recently"
+	PasswordWeak     = "password is too weak"
+	TermsRequired    = "you must accept the terms"
+	EmailLength      = "email must be at most %v characters"
+	EmailLocalLength = "email local part must be at most %v characters"
+	EmailDots        = "email must not contain consecutive, leading or trailing dots"
+	EmailTLD         = "email domain must have a top-level domain"
+
+	RegistrationAccepted = "check your email to finish the registration"
+)
@@ -0,0 +1,45 @@
+// Package emailcache remembers emails known to be taken, so uniqueness
+// checks can skip the primary store.
+package emailcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultPrefix prefixes keys of cached emails.
+const DefaultPrefix = "emails:"
+
+// Redis keeps every known email as a key of its own, so each expires
+// separately after the TTL it was added with.
+type Redis struct {
+	Client redis.Cmdable
+	Prefix string
+}
+
+// NewRedis prepares redis cache using client.
+func NewRedis(client redis.Cmdable) *Redis {
+	return &Redis{Client: client, Prefix: DefaultPrefix}
+}
+
+// Has reports whether email is cached.
+func (r *Redis) Has(ctx context.Context, email string) (bool, error) {
+	n, err := r.Client.Exists(ctx, r.key(email)).Result()
+	return n > 0, err
+}
+
+// Add caches email for ttl, zero ttl keeps it until removed.
+func (r *Redis) Add(ctx context.Context, email string, ttl time.Duration) error {
+	return r.Client.Set(ctx, r.key(email), 1, ttl).Err()
+}
+
+// Remove forgets email.
+func (r *Redis) Remove(ctx context.Context, email string) error {
+	return r.Client.Del(ctx, r.key(email)).Err()
+}
+
+func (r *Redis) key(email string) string {
+	return r.Prefix + email
+}
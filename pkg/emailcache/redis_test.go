@@ -0,0 +1,64 @@
+package emailcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedis(t *testing.T) {
+	t.Log("with redis cache.")
+	{
+		mr := miniredis.RunT(t)
+		c := NewRedis(redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1}))
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould miss unknown email.")
+		{
+			ok, err := c.Has(ctx, "a@domain.zone")
+			assert.Nil(t, err)
+			assert.False(t, ok)
+		}
+
+		t.Log("\ttest:1\tshould hit added email.")
+		{
+			assert.Nil(t, c.Add(ctx, "a@domain.zone", time.Minute))
+			assert.True(t, mr.Exists(DefaultPrefix+"a@domain.zone"))
+
+			ok, err := c.Has(ctx, "a@domain.zone")
+			assert.Nil(t, err)
+			assert.True(t, ok)
+		}
+
+		t.Log("\ttest:2\tshould forget email after ttl.")
+		{
+			mr.FastForward(time.Minute)
+
+			ok, err := c.Has(ctx, "a@domain.zone")
+			assert.Nil(t, err)
+			assert.False(t, ok)
+		}
+
+		t.Log("\ttest:3\tshould forget removed email.")
+		{
+			assert.Nil(t, c.Add(ctx, "b@domain.zone", 0))
+			assert.Nil(t, c.Remove(ctx, "b@domain.zone"))
+
+			ok, err := c.Has(ctx, "b@domain.zone")
+			assert.Nil(t, err)
+			assert.False(t, ok)
+		}
+
+		t.Log("\ttest:4\tshould fail when redis is gone.")
+		{
+			mr.Close()
+
+			_, err := c.Has(ctx, "a@domain.zone")
+			assert.NotNil(t, err)
+		}
+	}
+}
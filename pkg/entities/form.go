@@ -1,8 +1,47 @@
 package entities
 
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
 // Form is a registration request.
 type Form struct {
-	Email                string `json:"email" validate:"required,email"`
-	Password             string `json:"password" validate:"gte=3,lte=16"`
-	PasswordConfirmation string `json:"password_confirmation" validate:"gte=3,lte=16"`
-}
\ No newline at end of file
+	Email                string `validate:"required,email"`
+	Username             string
+	Password             string
+	PasswordConfirmation string
+	FirstName            string
+	LastName             string
+	DisplayName          string
+	// AcceptedTerms tells the user accepted the terms of service.
+	AcceptedTerms bool
+}
+
+// NormalizeEmail trims surrounding whitespace, composes characters to NFC and
+// lowercases the domain of the email. The local part is lowercased as well
+// when lowerLocal is set.
+func NormalizeEmail(email string, lowerLocal bool) string {
+	email = norm.NFC.String(strings.TrimSpace(email))
+	if lowerLocal {
+		return strings.ToLower(email)
+	}
+
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return email
+	}
+
+	return email[:i+1] + strings.ToLower(email[i+1:])
+}
+
+// Sanitize trims surrounding whitespace of the email, username and names
+// and composes their characters to NFC, so visually equal values are equal.
+// Passwords are kept as they are, spaces included, as they must match
+// byte for byte on login.
+func (f *Form) Sanitize() {
+	for _, v := range []*string{&f.Email, &f.Username, &f.FirstName, &f.LastName, &f.DisplayName} {
+		*v = norm.NFC.String(strings.TrimSpace(*v))
+	}
+}
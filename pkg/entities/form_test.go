@@ -0,0 +1,64 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		lowerLocal bool
+		want       string
+	}{
+		{name: "mixed case domain", email: "Alice@Example.COM", want: "Alice@example.com"},
+		{name: "mixed case address", email: "Alice@Example.COM", lowerLocal: true, want: "alice@example.com"},
+		{name: "surrounding spaces", email: " \talice@example.com \n", want: "alice@example.com"},
+		{name: "unicode domain", email: "alice@ÜBER.Example", want: "alice@über.example"},
+		{name: "missing at sign", email: " Alice ", want: "Alice"},
+		{name: "decomposed characters", email: "jose\u0301@domain.zone", want: "jos\u00e9@domain.zone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NormalizeEmail(tt.email, tt.lowerLocal))
+		})
+	}
+}
+
+func TestFormSanitize(t *testing.T) {
+	t.Log("with form of decomposed and padded values.")
+	{
+		f := Form{
+			Email:                " Jose\u0301@domain.zone\n",
+			Username:             "\tjose\u0301 ",
+			Password:             " pa\u0301ss word ",
+			PasswordConfirmation: " pa\u0301ss word ",
+			FirstName:            " Jose\u0301 ",
+			LastName:             "de la Cruz ",
+			DisplayName:          "  ",
+		}
+		f.Sanitize()
+
+		t.Log("\ttest:0\tshould compose the email to NFC without surrounding whitespace.")
+		{
+			assert.Equal(t, "Jos\u00e9@domain.zone", f.Email)
+		}
+
+		t.Log("\ttest:1\tshould trim names keeping internal spaces.")
+		{
+			assert.Equal(t, "jos\u00e9", f.Username)
+			assert.Equal(t, "Jos\u00e9", f.FirstName)
+			assert.Equal(t, "de la Cruz", f.LastName)
+			assert.Equal(t, "", f.DisplayName)
+		}
+
+		t.Log("\ttest:2\tshould keep passwords as they are.")
+		{
+			assert.Equal(t, " pa\u0301ss word ", f.Password)
+			assert.Equal(t, " pa\u0301ss word ", f.PasswordConfirmation)
+		}
+	}
+}
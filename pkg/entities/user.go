@@ -1,8 +1,65 @@
 package entities
 
+import "time"
+
+// Roles of users.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User represents the database colum.
 type User struct {
-	ID       int    `json:"id"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
\ No newline at end of file
+	ID       string
+	Email    string
+	Username string
+	Password string
+	Verified bool
+	Role     string
+
+	FirstName   string
+	LastName    string
+	DisplayName string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+// SafeUser is a user without its secrets, it is what may leave the service.
+// Fields are copied one by one, so secret fields added to User stay out
+// until they are added here.
+type SafeUser struct {
+	ID       string
+	Email    string
+	Username string
+	Verified bool
+	Role     string
+
+	FirstName   string
+	LastName    string
+	DisplayName string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+// Safe returns the user without its password hash.
+func (u *User) Safe() SafeUser {
+	return SafeUser{
+		ID:       u.ID,
+		Email:    u.Email,
+		Username: u.Username,
+		Verified: u.Verified,
+		Role:     u.Role,
+
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		DisplayName: u.DisplayName,
+
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		DeletedAt: u.DeletedAt,
+	}
+}
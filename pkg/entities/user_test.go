@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserSafe(t *testing.T) {
+	t.Log("with safe user type.")
+	{
+		t.Log("\ttest:0\tshould have no secret fields.")
+		{
+			typ := reflect.TypeOf(SafeUser{})
+			for i := 0; i < typ.NumField(); i++ {
+				name := strings.ToLower(typ.Field(i).Name)
+				assert.NotContains(t, name, "password", typ.Field(i).Name)
+				assert.NotContains(t, name, "hash", typ.Field(i).Name)
+			}
+		}
+	}
+
+	t.Log("with user.")
+	{
+		created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		u := User{ID: "1", Email: "a@domain.zone", Password: "hash", Verified: true, Role: RoleAdmin, FirstName: "Ada", CreatedAt: created}
+
+		t.Log("\ttest:0\tshould copy everything but the password hash.")
+		{
+			assert.Equal(t, SafeUser{ID: "1", Email: "a@domain.zone", Verified: true, Role: RoleAdmin, FirstName: "Ada", CreatedAt: created}, u.Safe())
+		}
+	}
+}
@@ -5,4 +5,37 @@ import "github.com/pkg/errors"
 var (
 	// ErrEmailExists returns when given email is present in storage.
 	ErrEmailExists = errors.New("email already exists")
-)
\ No newline at end of file
+
+	// ErrUsernameExists returns when given username is present in storage.
+	ErrUsernameExists = errors.New("username already exists")
+
+	// ErrUserNotFound returns when requested user is missing in storage.
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrInvalidCredentials returns when email and password do not match a user.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrAccountLocked returns when an account is locked after too many
+	// failed logins.
+	ErrAccountLocked = errors.New("account temporarily locked")
+
+	// ErrInvalidToken returns when a token is unknown or was already used.
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrTokenExpired returns when a token is past its expiry.
+	ErrTokenExpired = errors.New("token expired")
+
+	// ErrTimeout returns when an operation exceeds its deadline.
+	ErrTimeout = errors.New("operation timed out")
+
+	// ErrStorageUnavailable returns when storage fails for a reason other
+	// than the request itself, the request may be retried later.
+	ErrStorageUnavailable = errors.New("storage temporarily unavailable")
+
+	// ErrRegistrationDisabled returns when registrations are paused.
+	ErrRegistrationDisabled = errors.New("registrations are disabled")
+
+	// ErrRequestInFlight returns when a request with the same idempotency
+	// key is still being processed.
+	ErrRequestInFlight = errors.New("request with the idempotency key is in progress")
+)
@@ -0,0 +1,60 @@
+// Package events holds domain events published to downstream services.
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	TypeUserRegistered = "user.registered"
+)
+
+// Event is a domain event.
+type Event interface {
+	Type() string
+}
+
+// UserRegistered is published after a user is registered, it never carries
+// the password.
+type UserRegistered struct {
+	UserID string    `json:"user_id"`
+	Email  string    `json:"email"`
+	Time   time.Time `json:"time"`
+}
+
+// Type implements Event.
+func (UserRegistered) Type() string {
+	return TypeUserRegistered
+}
+
+// Memory is a publisher keeping events in memory.
+type Memory struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewMemory prepares empty in-memory publisher.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Publish appends the event.
+func (m *Memory) Publish(ctx context.Context, e Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = append(m.events, e)
+
+	return nil
+}
+
+// Events returns a copy of published events in order.
+func (m *Memory) Events() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Event(nil), m.events...)
+}
@@ -0,0 +1,56 @@
+package hasher
+
+import "golang.org/x/crypto/bcrypt"
+
+// PasswordHasher turns a plaintext password into a storable hash and
+// verifies plaintext passwords against it.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Compare(hash, password string) error
+}
+
+// Rehasher is implemented by hashers able to tell a hash was produced with
+// weaker settings than they use now.
+type Rehasher interface {
+	NeedsRehash(hash string) bool
+}
+
+// Bcrypt hashes passwords with bcrypt using the configured cost.
+type Bcrypt struct {
+	Cost int
+}
+
+// NewBcrypt prepares bcrypt hasher, falling back to the default cost when
+// the given one is out of range.
+func NewBcrypt(cost int) *Bcrypt {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+
+	return &Bcrypt{Cost: cost}
+}
+
+// Hash implements PasswordHasher.
+func (b *Bcrypt) Hash(password string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(h), nil
+}
+
+// Compare implements PasswordHasher.
+func (b *Bcrypt) Compare(hash, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+// NeedsRehash implements Rehasher, hashes of a lower cost need a rehash.
+func (b *Bcrypt) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false
+	}
+
+	return cost < b.Cost
+}
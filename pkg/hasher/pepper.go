@@ -0,0 +1,110 @@
+package hasher
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownPepper returns when a hash was made with a pepper version the
+// hasher does not hold.
+var ErrUnknownPepper = errors.New("unknown pepper version")
+
+// Peppered hashes passwords keyed with an application-wide secret pepper
+// kept outside the database, so leaked hashes can not be cracked without it.
+//
+// Hashes are prefixed with the version of the pepper they were made with,
+// like "p2$" followed by the hash of Base. To rotate the pepper, put the new
+// one under a new Version and keep the previous ones in Peppers: old hashes
+// still verify and are reported by NeedsRehash, so they are upgraded on the
+// next login. Hashes without the prefix were made before peppering and are
+// compared without a pepper.
+type Peppered struct {
+	Base PasswordHasher
+	// Version is the version of the pepper new hashes are made with.
+	Version int
+	// Peppers holds peppers by version.
+	Peppers map[int][]byte
+}
+
+// NewPeppered prepares hasher peppering passwords hashed by base with the
+// pepper of the given version.
+func NewPeppered(base PasswordHasher, version int, pepper []byte) *Peppered {
+	return &Peppered{Base: base, Version: version, Peppers: map[int][]byte{version: pepper}}
+}
+
+// Hash implements PasswordHasher.
+func (p *Peppered) Hash(password string) (string, error) {
+	pepper, ok := p.Peppers[p.Version]
+	if !ok {
+		return "", ErrUnknownPepper
+	}
+
+	h, err := p.Base.Hash(pepperPassword(pepper, password))
+	if err != nil {
+		return "", err
+	}
+
+	return "p" + strconv.Itoa(p.Version) + "$" + h, nil
+}
+
+// Compare implements PasswordHasher.
+func (p *Peppered) Compare(hash, password string) error {
+	version, base, ok := splitPepper(hash)
+	if !ok {
+		return p.Base.Compare(hash, password)
+	}
+
+	pepper, ok := p.Peppers[version]
+	if !ok {
+		return ErrUnknownPepper
+	}
+
+	return p.Base.Compare(base, pepperPassword(pepper, password))
+}
+
+// NeedsRehash implements Rehasher, hashes made without the current pepper
+// need a rehash, as do the ones Base reports.
+func (p *Peppered) NeedsRehash(hash string) bool {
+	version, base, ok := splitPepper(hash)
+	if !ok || version != p.Version {
+		return true
+	}
+
+	if r, ok := p.Base.(Rehasher); ok {
+		return r.NeedsRehash(base)
+	}
+
+	return false
+}
+
+// pepperPassword keys password with the pepper. HMAC is used instead of
+// appending the pepper, so bcrypt cutting input at 72 bytes can not drop it.
+func pepperPassword(pepper []byte, password string) string {
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte(password))
+
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// splitPepper splits hash into pepper version and hash of the base hasher.
+func splitPepper(hash string) (int, string, bool) {
+	if !strings.HasPrefix(hash, "p") {
+		return 0, "", false
+	}
+
+	v, base, ok := strings.Cut(hash[1:], "$")
+	if !ok {
+		return 0, "", false
+	}
+
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, "", false
+	}
+
+	return version, base, true
+}
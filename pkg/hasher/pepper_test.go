@@ -0,0 +1,68 @@
+package hasher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPeppered(t *testing.T) {
+	t.Log("with bcrypt hasher peppered with version 1.")
+	{
+		base := NewBcrypt(bcrypt.MinCost)
+		p := NewPeppered(base, 1, []byte("pepper-one"))
+
+		hash, err := p.Hash("qwerty")
+		assert.Nil(t, err)
+
+		t.Log("\ttest:0\tshould verify password with the same pepper.")
+		{
+			assert.True(t, strings.HasPrefix(hash, "p1$"))
+			assert.Nil(t, p.Compare(hash, "qwerty"))
+			assert.NotNil(t, p.Compare(hash, "other"))
+			assert.False(t, p.NeedsRehash(hash))
+		}
+
+		t.Log("\ttest:1\tshould not verify password without the pepper.")
+		{
+			assert.NotNil(t, base.Compare(strings.TrimPrefix(hash, "p1$"), "qwerty"))
+		}
+
+		t.Log("\ttest:2\tshould not verify password with other pepper.")
+		{
+			other := NewPeppered(base, 1, []byte("pepper-two"))
+			assert.NotNil(t, other.Compare(hash, "qwerty"))
+		}
+
+		t.Log("\ttest:3\tshould verify hashes of kept peppers after rotation.")
+		{
+			rotated := NewPeppered(base, 2, []byte("pepper-two"))
+			rotated.Peppers[1] = []byte("pepper-one")
+
+			assert.Nil(t, rotated.Compare(hash, "qwerty"))
+			assert.True(t, rotated.NeedsRehash(hash))
+
+			upgraded, err := rotated.Hash("qwerty")
+			assert.Nil(t, err)
+			assert.True(t, strings.HasPrefix(upgraded, "p2$"))
+			assert.False(t, rotated.NeedsRehash(upgraded))
+		}
+
+		t.Log("\ttest:4\tshould reject hashes of dropped peppers.")
+		{
+			rotated := NewPeppered(base, 2, []byte("pepper-two"))
+			assert.Equal(t, ErrUnknownPepper, rotated.Compare(hash, "qwerty"))
+		}
+
+		t.Log("\ttest:5\tshould verify hashes made before peppering.")
+		{
+			legacy, err := base.Hash("qwerty")
+			assert.Nil(t, err)
+
+			assert.Nil(t, p.Compare(legacy, "qwerty"))
+			assert.True(t, p.NeedsRehash(legacy))
+		}
+	}
+}
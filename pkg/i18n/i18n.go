@@ -0,0 +1,107 @@
+// Package i18n translates messages to locales chosen from Accept-Language
+// headers. Message keys are the English messages themselves, so English
+// needs no catalog and unknown keys are returned as they are.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used when none of the requested locales is registered.
+const DefaultLocale = "en"
+
+// argSeparator separates the key of a message from its arguments.
+const argSeparator = "\x1f"
+
+// Message returns a translatable message of the format key and its
+// arguments, the translated format is applied to them with fmt.Sprintf.
+func Message(key string, args ...interface{}) string {
+	parts := []string{key}
+	for _, a := range args {
+		parts = append(parts, fmt.Sprint(a))
+	}
+
+	return strings.Join(parts, argSeparator)
+}
+
+// Catalog maps message keys to their translated formats.
+type Catalog map[string]string
+
+// Translator translates messages to registered locales.
+type Translator struct {
+	mu       sync.RWMutex
+	tags     []language.Tag
+	catalogs map[language.Tag]Catalog
+	matcher  language.Matcher
+}
+
+// NewTranslator prepares translator with the default English locale.
+func NewTranslator() *Translator {
+	t := &Translator{catalogs: map[language.Tag]Catalog{}}
+	t.Register(DefaultLocale, Catalog{})
+	return t
+}
+
+// Register adds or replaces the catalog of locale, a BCP 47 tag like "de"
+// or "pt-BR".
+func (t *Translator) Register(locale string, c Catalog) error {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.catalogs[tag]; !ok {
+		t.tags = append(t.tags, tag)
+		t.matcher = language.NewMatcher(t.tags)
+	}
+	t.catalogs[tag] = c
+	return nil
+}
+
+// Locale picks the registered locale best matching the Accept-Language
+// header, the default locale is the first registered one.
+func (t *Translator) Locale(acceptLanguage string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return t.tags[0].String()
+	}
+
+	_, i, _ := t.matcher.Match(tags...)
+	return t.tags[i].String()
+}
+
+// Translate formats message in locale, messages without translation are
+// formatted in English.
+func (t *Translator) Translate(locale, msg string) string {
+	parts := strings.Split(msg, argSeparator)
+	key, format := parts[0], parts[0]
+
+	if tag, err := language.Parse(locale); err == nil {
+		t.mu.RLock()
+		if f, ok := t.catalogs[tag][key]; ok {
+			format = f
+		}
+		t.mu.RUnlock()
+	}
+
+	if len(parts) == 1 {
+		return format
+	}
+
+	args := make([]interface{}, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		args = append(args, p)
+	}
+
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,39 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslator(t *testing.T) {
+	t.Log("with english and german locales.")
+	{
+		tr := NewTranslator()
+		assert.Nil(t, tr.Register("de", Catalog{
+			"required":                      "erforderlich",
+			"must be at most %v characters": "darf höchstens %v Zeichen lang sein",
+		}))
+
+		t.Log("\ttest:0\tshould pick the best matching locale.")
+		{
+			assert.Equal(t, "de", tr.Locale("de-AT,de;q=0.9,en;q=0.8"))
+			assert.Equal(t, "en", tr.Locale("en-US"))
+			assert.Equal(t, "en", tr.Locale("fr"))
+			assert.Equal(t, "en", tr.Locale(""))
+		}
+
+		t.Log("\ttest:1\tshould translate messages with arguments.")
+		{
+			assert.Equal(t, "erforderlich", tr.Translate("de", "required"))
+			assert.Equal(t, "darf höchstens 8 Zeichen lang sein", tr.Translate("de", Message("must be at most %v characters", 8)))
+			assert.Equal(t, "must be at most 8 characters", tr.Translate("en", Message("must be at most %v characters", 8)))
+		}
+
+		t.Log("\ttest:2\tshould keep messages without translation.")
+		{
+			assert.Equal(t, "got number, want string", tr.Translate("de", "got number, want string"))
+			assert.Equal(t, "required", tr.Translate("xx-invalid-", "required"))
+		}
+	}
+}
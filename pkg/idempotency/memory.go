@@ -0,0 +1,135 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/clock"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+)
+
+// DefaultTTL is a time responses are remembered for.
+const DefaultTTL = 24 * time.Hour
+
+// Response is a recorded http response replayed for repeated keys.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// Fingerprint identifies the request the response was recorded for, so
+	// keys reused for other requests are told apart.
+	Fingerprint string
+}
+
+// Memory keeps recorded responses per key in memory.
+type Memory struct {
+	TTL time.Duration
+	// Clock is used for expiry, real clock when nil.
+	Clock clock.Clock
+
+	mu        sync.Mutex
+	entries   map[string]entry
+	nextSweep time.Time
+}
+
+// entry holds a recorded response, nil while the request of the key is in
+// flight.
+type entry struct {
+	resp    *Response
+	expires time.Time
+}
+
+// NewMemory prepares in-memory store remembering responses for ttl.
+func NewMemory(ttl time.Duration) *Memory {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &Memory{
+		TTL:     ttl,
+		entries: make(map[string]entry),
+	}
+}
+
+// Reserve returns response recorded for key, otherwise marks key in flight
+// until Put or Release and returns nil. Keys already in flight get
+// ErrRequestInFlight.
+func (m *Memory) Reserve(ctx context.Context, key string) (*Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.lookup(key); ok {
+		if e.resp == nil {
+			return nil, svcerrors.ErrRequestInFlight
+		}
+
+		return e.resp, nil
+	}
+
+	m.sweep()
+	// abandoned reservations expire like responses.
+	m.entries[key] = entry{expires: m.now().Add(m.TTL)}
+
+	return nil, nil
+}
+
+// Put records response for key.
+func (m *Memory) Put(ctx context.Context, key string, resp *Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep()
+	m.entries[key] = entry{resp: resp, expires: m.now().Add(m.TTL)}
+
+	return nil
+}
+
+// Release forgets key reserved without recording a response, so it can be
+// retried.
+func (m *Memory) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok && e.resp == nil {
+		delete(m.entries, key)
+	}
+
+	return nil
+}
+
+// lookup returns live entry of key, forgetting it when expired.
+func (m *Memory) lookup(key string) (entry, bool) {
+	e, ok := m.entries[key]
+	if !ok {
+		return entry{}, false
+	}
+
+	if m.now().After(e.expires) {
+		delete(m.entries, key)
+		return entry{}, false
+	}
+
+	return e, true
+}
+
+// sweep forgets expired entries at most once per TTL, so keys never read
+// again do not pile up.
+func (m *Memory) sweep() {
+	now := m.now()
+	if now.Before(m.nextSweep) {
+		return
+	}
+
+	for k, e := range m.entries {
+		if now.After(e.expires) {
+			delete(m.entries, k)
+		}
+	}
+	m.nextSweep = now.Add(m.TTL)
+}
+
+func (m *Memory) now() time.Time {
+	return clock.Now(m.Clock)
+}
@@ -0,0 +1,106 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory(t *testing.T) {
+	t.Log("with short lived store.")
+	{
+		m := NewMemory(50 * time.Millisecond)
+		ctx := context.Background()
+		resp := &Response{Status: 201, Body: []byte("{}")}
+
+		t.Log("\ttest:0\tshould return nothing for unknown key.")
+		{
+			r, err := m.Reserve(ctx, "key")
+			assert.Nil(t, err)
+			assert.Nil(t, r)
+		}
+
+		t.Log("\ttest:1\tshould return recorded response.")
+		{
+			assert.Nil(t, m.Put(ctx, "key", resp))
+
+			r, err := m.Reserve(ctx, "key")
+			assert.Nil(t, err)
+			assert.Equal(t, resp, r)
+		}
+
+		t.Log("\ttest:2\tshould forget response after ttl.")
+		{
+			time.Sleep(60 * time.Millisecond)
+
+			r, err := m.Reserve(ctx, "key")
+			assert.Nil(t, err)
+			assert.Nil(t, r)
+		}
+	}
+}
+
+func TestMemoryReserve(t *testing.T) {
+	t.Log("with store remembering responses for a minute.")
+	{
+		clock := &fakeClock{now: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+		m := NewMemory(time.Minute)
+		m.Clock = clock
+		ctx := context.Background()
+		resp := &Response{Status: 201, Body: []byte("{}")}
+
+		t.Log("\ttest:0\tshould reserve an unknown key.")
+		{
+			r, err := m.Reserve(ctx, "key")
+			assert.Nil(t, err)
+			assert.Nil(t, r)
+		}
+
+		t.Log("\ttest:1\tshould reject a key in flight.")
+		{
+			_, err := m.Reserve(ctx, "key")
+			assert.Equal(t, svcerrors.ErrRequestInFlight, err)
+		}
+
+		t.Log("\ttest:2\tshould free a released key.")
+		{
+			assert.Nil(t, m.Release(ctx, "key"))
+
+			r, err := m.Reserve(ctx, "key")
+			assert.Nil(t, err)
+			assert.Nil(t, r)
+		}
+
+		t.Log("\ttest:3\tshould return the response put for a reserved key.")
+		{
+			assert.Nil(t, m.Put(ctx, "key", resp))
+			assert.Nil(t, m.Release(ctx, "key"))
+
+			r, err := m.Reserve(ctx, "key")
+			assert.Nil(t, err)
+			assert.Equal(t, resp, r)
+		}
+
+		t.Log("\ttest:4\tshould sweep expired keys never read again.")
+		{
+			_, err := m.Reserve(ctx, "abandoned")
+			assert.Nil(t, err)
+			assert.Len(t, m.entries, 2)
+
+			clock.now = clock.now.Add(2 * time.Minute)
+			assert.Nil(t, m.Put(ctx, "other", resp))
+			assert.Len(t, m.entries, 1)
+		}
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
@@ -0,0 +1,123 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/clock"
+)
+
+// Memory counts failed attempts per key in memory, keys failing Threshold
+// times in a row are locked for Cooldown. Failures are forgotten once a key
+// has not failed for Cooldown, so keys never locked do not pile up.
+type Memory struct {
+	Threshold int
+	Cooldown  time.Duration
+	// Clock is used for lock expiry, real clock when nil.
+	Clock clock.Clock
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	nextSweep time.Time
+}
+
+// entry holds consecutive failures of a key, the time of the last one and
+// the end of its lock.
+type entry struct {
+	failures int
+	last     time.Time
+	until    time.Time
+}
+
+// expired reports whether e is forgotten at now, either its lock ended or
+// it has not failed for cooldown.
+func (e *entry) expired(now time.Time, cooldown time.Duration) bool {
+	if !e.until.IsZero() {
+		return !now.Before(e.until)
+	}
+
+	return now.Sub(e.last) >= cooldown
+}
+
+// NewMemory prepares in-memory store locking keys for cooldown once they
+// fail threshold times.
+func NewMemory(threshold int, cooldown time.Duration) *Memory {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return &Memory{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		entries:   make(map[string]*entry),
+	}
+}
+
+// Locked reports whether key is locked, returning the time left until it
+// unlocks. Expired locks are forgotten along with the failures.
+func (m *Memory) Locked(ctx context.Context, key string) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok || e.until.IsZero() {
+		return false, 0, nil
+	}
+
+	if left := e.until.Sub(m.now()); left > 0 {
+		return true, left, nil
+	}
+
+	delete(m.entries, key)
+	return false, 0, nil
+}
+
+// Fail records a failed attempt of key, locking it on reaching the threshold.
+func (m *Memory) Fail(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	e, ok := m.entries[key]
+	if !ok || e.expired(now, m.Cooldown) {
+		e = &entry{}
+		m.entries[key] = e
+	}
+
+	e.last = now
+	if e.failures++; e.failures >= m.Threshold {
+		e.until = now.Add(m.Cooldown)
+	}
+
+	return nil
+}
+
+// Reset forgets failures of key.
+func (m *Memory) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+// sweep forgets expired entries at most once per Cooldown.
+func (m *Memory) sweep(now time.Time) {
+	if now.Before(m.nextSweep) {
+		return
+	}
+
+	for k, e := range m.entries {
+		if e.expired(now, m.Cooldown) {
+			delete(m.entries, k)
+		}
+	}
+	m.nextSweep = now.Add(m.Cooldown)
+}
+
+func (m *Memory) now() time.Time {
+	return clock.Now(m.Clock)
+}
@@ -0,0 +1,101 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory(t *testing.T) {
+	t.Log("with store locking after three failures for a minute.")
+	{
+		clock := &fakeClock{now: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+		m := NewMemory(3, time.Minute)
+		m.Clock = clock
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould not lock below the threshold.")
+		{
+			for i := 0; i < 2; i++ {
+				assert.Nil(t, m.Fail(ctx, "a@domain.zone"))
+			}
+
+			locked, _, err := m.Locked(ctx, "a@domain.zone")
+			assert.Nil(t, err)
+			assert.False(t, locked)
+		}
+
+		t.Log("\ttest:1\tshould lock on reaching the threshold.")
+		{
+			assert.Nil(t, m.Fail(ctx, "a@domain.zone"))
+
+			locked, left, err := m.Locked(ctx, "a@domain.zone")
+			assert.Nil(t, err)
+			assert.True(t, locked)
+			assert.Equal(t, time.Minute, left)
+		}
+
+		t.Log("\ttest:2\tshould lock keys independently.")
+		{
+			locked, _, err := m.Locked(ctx, "b@domain.zone")
+			assert.Nil(t, err)
+			assert.False(t, locked)
+		}
+
+		t.Log("\ttest:3\tshould unlock after the cooldown with failures forgotten.")
+		{
+			clock.now = clock.now.Add(time.Minute)
+
+			locked, _, err := m.Locked(ctx, "a@domain.zone")
+			assert.Nil(t, err)
+			assert.False(t, locked)
+
+			assert.Nil(t, m.Fail(ctx, "a@domain.zone"))
+			locked, _, _ = m.Locked(ctx, "a@domain.zone")
+			assert.False(t, locked)
+		}
+
+		t.Log("\ttest:4\tshould forget failures on reset.")
+		{
+			assert.Nil(t, m.Fail(ctx, "a@domain.zone"))
+			assert.Nil(t, m.Reset(ctx, "a@domain.zone"))
+			assert.Nil(t, m.Fail(ctx, "a@domain.zone"))
+
+			locked, _, _ := m.Locked(ctx, "a@domain.zone")
+			assert.False(t, locked)
+		}
+
+		t.Log("\ttest:5\tshould forget failures spaced over the cooldown.")
+		{
+			assert.Nil(t, m.Reset(ctx, "a@domain.zone"))
+			for i := 0; i < 3; i++ {
+				assert.Nil(t, m.Fail(ctx, "a@domain.zone"))
+				clock.now = clock.now.Add(time.Minute)
+			}
+
+			locked, _, _ := m.Locked(ctx, "a@domain.zone")
+			assert.False(t, locked)
+		}
+
+		t.Log("\ttest:6\tshould sweep keys that stopped failing.")
+		{
+			for _, key := range []string{"c@domain.zone", "d@domain.zone", "e@domain.zone"} {
+				assert.Nil(t, m.Fail(ctx, key))
+			}
+
+			clock.now = clock.now.Add(2 * time.Minute)
+			assert.Nil(t, m.Fail(ctx, "f@domain.zone"))
+			assert.Len(t, m.entries, 1)
+		}
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
@@ -0,0 +1,114 @@
+// Package mailer sends emails to users.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidHeader returns when a recipient or subject would inject headers.
+var ErrInvalidHeader = errors.New("invalid header value")
+
+// Noop drops every message, it is used when no mail server is configured.
+type Noop struct{}
+
+// Send implements mailer discarding the message.
+func (Noop) Send(ctx context.Context, to, subject, body string) error {
+	return nil
+}
+
+// Default delivery retries of SMTP.
+const (
+	DefaultAttempts = 3
+	DefaultBackoff  = time.Second
+)
+
+// SMTP sends plain text messages through an SMTP server. Failed deliveries
+// are retried unless the server rejects the message permanently.
+type SMTP struct {
+	Addr string
+	From string
+	// Auth is optional, messages are sent unauthenticated without it.
+	Auth smtp.Auth
+	// Attempts bounds deliveries of a message, it is sent once when below one.
+	Attempts int
+	// Backoff is an average wait before the first retry, it doubles with
+	// every retry and is jittered so senders do not retry in lockstep.
+	Backoff time.Duration
+
+	// send delivers the message, smtp.SendMail is used when nil.
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTP prepares SMTP mailer sending from the address from through the
+// server at addr, PLAIN authentication is used when username is set.
+func NewSMTP(addr, from, username, password string) *SMTP {
+	s := &SMTP{Addr: addr, From: from, Attempts: DefaultAttempts, Backoff: DefaultBackoff}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		s.Auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return s
+}
+
+// Send sends the message to the address to, retrying transient failures
+// until attempts run out or ctx is done.
+func (s *SMTP) Send(ctx context.Context, to, subject, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+
+	send := s.send
+	if send == nil {
+		send = smtp.SendMail
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s", s.From, to, subject, body)
+	wait := s.Backoff
+	for attempt := 1; ; attempt++ {
+		err := send(s.Addr, s.Auth, s.From, []string{to}, []byte(msg))
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= s.Attempts || permanent(err) {
+			return errors.Wrap(err, "smtp send")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jitter(wait)):
+		}
+		wait *= 2
+	}
+}
+
+// permanent reports whether err is a 5xx reply, the server would reject
+// the message again.
+func permanent(err error) bool {
+	var e *textproto.Error
+	return errors.As(err, &e) && e.Code >= 500 && e.Code < 600
+}
+
+// jitter returns a random duration between half of d and one and a half d.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + rand.N(d)
+}
@@ -0,0 +1,123 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMTP(t *testing.T) {
+	t.Log("with SMTP mailer.")
+	{
+		var (
+			addr, from string
+			to         []string
+			msg        []byte
+		)
+		s := NewSMTP("mail.domain.zone:587", "noreply@domain.zone", "user", "secret")
+		s.Backoff = 0
+		s.send = func(a string, auth smtp.Auth, f string, t []string, m []byte) error {
+			addr, from, to, msg = a, f, t, m
+			return nil
+		}
+
+		t.Log("\ttest:0\tshould send the message with headers.")
+		{
+			assert.Nil(t, s.Send(context.Background(), "new@domain.zone", "Welcome", "Hello"))
+			assert.Equal(t, "mail.domain.zone:587", addr)
+			assert.Equal(t, "noreply@domain.zone", from)
+			assert.Equal(t, []string{"new@domain.zone"}, to)
+			assert.Contains(t, string(msg), "To: new@domain.zone\r\n")
+			assert.Contains(t, string(msg), "Subject: Welcome\r\n")
+			assert.Contains(t, string(msg), "\r\n\r\nHello")
+			assert.NotNil(t, s.Auth)
+		}
+
+		t.Log("\ttest:1\tshould reject header injection.")
+		{
+			assert.Equal(t, ErrInvalidHeader, s.Send(context.Background(), "new@domain.zone\r\nBcc: all@domain.zone", "Welcome", "Hello"))
+		}
+
+		t.Log("\ttest:2\tshould wrap delivery failures.")
+		{
+			s.send = func(string, smtp.Auth, string, []string, []byte) error {
+				return errors.New("connection refused")
+			}
+			assert.EqualError(t, s.Send(context.Background(), "new@domain.zone", "Welcome", "Hello"), "smtp send: connection refused")
+		}
+	}
+}
+
+func TestSMTPRetry(t *testing.T) {
+	t.Log("with SMTP mailer retrying three times.")
+	{
+		s := NewSMTP("mail.domain.zone:587", "noreply@domain.zone", "", "")
+		s.Backoff = time.Millisecond
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould retry transient failures until delivered.")
+		{
+			f := &fakeServer{errs: []error{errors.New("connection reset"), &textproto.Error{Code: 421, Msg: "try again later"}}}
+			s.send = f.send
+
+			assert.Nil(t, s.Send(ctx, "new@domain.zone", "Welcome", "Hello"))
+			assert.Equal(t, 3, f.calls)
+		}
+
+		t.Log("\ttest:1\tshould give up after the last attempt.")
+		{
+			f := &fakeServer{errs: []error{errors.New("connection reset"), errors.New("connection reset"), errors.New("connection refused")}}
+			s.send = f.send
+
+			assert.EqualError(t, s.Send(ctx, "new@domain.zone", "Welcome", "Hello"), "smtp send: connection refused")
+			assert.Equal(t, 3, f.calls)
+		}
+
+		t.Log("\ttest:2\tshould not retry permanent failures.")
+		{
+			f := &fakeServer{errs: []error{&textproto.Error{Code: 550, Msg: "mailbox unavailable"}}}
+			s.send = f.send
+
+			var e *textproto.Error
+			assert.True(t, errors.As(s.Send(ctx, "new@domain.zone", "Welcome", "Hello"), &e))
+			assert.Equal(t, 550, e.Code)
+			assert.Equal(t, 1, f.calls)
+		}
+
+		t.Log("\ttest:3\tshould stop waiting when context is done.")
+		{
+			ctx, cancel := context.WithCancel(ctx)
+			f := &fakeServer{errs: []error{errors.New("connection reset")}, cancel: cancel}
+			s.send = f.send
+			s.Backoff = time.Hour
+
+			assert.Equal(t, context.Canceled, s.Send(ctx, "new@domain.zone", "Welcome", "Hello"))
+			assert.Equal(t, 1, f.calls)
+		}
+	}
+}
+
+// fakeServer fails deliveries with errs in turn and accepts them after.
+type fakeServer struct {
+	errs   []error
+	calls  int
+	cancel context.CancelFunc
+}
+
+func (f *fakeServer) send(string, smtp.Auth, string, []string, []byte) error {
+	f.calls++
+	if f.cancel != nil {
+		f.cancel()
+	}
+
+	if f.calls > len(f.errs) {
+		return nil
+	}
+
+	return f.errs[f.calls-1]
+}
@@ -0,0 +1,212 @@
+// Package openapi builds OpenAPI 3 documents with schemas reflected from Go
+// structs and their json and validate tags.
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the OpenAPI version of built documents.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps lowercase HTTP methods to operations of a path.
+type PathItem map[string]*Operation
+
+// Operation describes a single API operation.
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// RequestBody describes a request body.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds schema of a content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds schemas referenced by the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is a JSON schema object as defined by OpenAPI.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+}
+
+// Ref returns schema referencing the component schema name.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// JSON returns content of JSON media type with schema s.
+func JSON(s *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf reflects schema of the value v. Property names come from json
+// tags and constraints from validate tags of v, or of the same named fields
+// of rules when v has none, so a request can borrow rules of the domain
+// type it maps to.
+func SchemaOf(v interface{}, rules ...interface{}) *Schema {
+	var types []reflect.Type
+	for _, r := range rules {
+		types = append(types, indirect(reflect.TypeOf(r)))
+	}
+
+	return schemaOf(reflect.TypeOf(v), types)
+}
+
+func schemaOf(t reflect.Type, rules []reflect.Type) *Schema {
+	t = indirect(t)
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem(), nil)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), nil)}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+
+		return structSchema(t, rules)
+	}
+
+	return &Schema{}
+}
+
+func structSchema(t reflect.Type, rules []reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+
+		p := schemaOf(f.Type, nil)
+		if constrain(p, validateTag(f, rules)) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = p
+	}
+
+	return s
+}
+
+// validateTag returns validate tag of the field or of the same named field
+// of the first rules type having one.
+func validateTag(f reflect.StructField, rules []reflect.Type) string {
+	if tag, ok := f.Tag.Lookup("validate"); ok {
+		return tag
+	}
+
+	for _, r := range rules {
+		if rf, ok := r.FieldByName(f.Name); ok {
+			if tag, ok := rf.Tag.Lookup("validate"); ok {
+				return tag
+			}
+		}
+	}
+
+	return ""
+}
+
+// constrain applies validate tag rules to s and reports whether the value
+// is required.
+func constrain(s *Schema, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil || s.Type != "string" {
+				continue
+			}
+
+			if name == "min" {
+				s.MinLength = &n
+			} else {
+				s.MaxLength = &n
+			}
+		}
+	}
+
+	return required
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t
+}
@@ -0,0 +1,58 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testForm struct {
+	Email string   `validate:"required,email"`
+	Name  string   `validate:"min=2,max=10"`
+	Tags  []string `validate:"max=3"`
+}
+
+type testRequest struct {
+	Email   string            `json:"email"`
+	Name    string            `json:"name,omitempty"`
+	Tags    []string          `json:"tags"`
+	Age     *int              `json:"age"`
+	Labels  map[string]string `json:"labels"`
+	Created time.Time         `json:"created"`
+	Secret  string            `json:"-"`
+	hidden  string
+}
+
+func TestSchemaOf(t *testing.T) {
+	t.Log("with request mapped to a form.")
+	{
+		s := SchemaOf(testRequest{}, testForm{})
+
+		t.Log("\ttest:0\tshould name properties by json tags.")
+		{
+			assert.Equal(t, "object", s.Type)
+			assert.Len(t, s.Properties, 6)
+			assert.NotContains(t, s.Properties, "Secret")
+			assert.NotContains(t, s.Properties, "hidden")
+		}
+
+		t.Log("\ttest:1\tshould map types.")
+		{
+			assert.Equal(t, "integer", s.Properties["age"].Type)
+			assert.Equal(t, "array", s.Properties["tags"].Type)
+			assert.Equal(t, "string", s.Properties["tags"].Items.Type)
+			assert.Equal(t, "string", s.Properties["labels"].AdditionalProperties.Type)
+			assert.Equal(t, "date-time", s.Properties["created"].Format)
+		}
+
+		t.Log("\ttest:2\tshould borrow constraints of the form.")
+		{
+			assert.Equal(t, []string{"email"}, s.Required)
+			assert.Equal(t, "email", s.Properties["email"].Format)
+			assert.Equal(t, 2, *s.Properties["name"].MinLength)
+			assert.Equal(t, 10, *s.Properties["name"].MaxLength)
+			assert.Nil(t, s.Properties["tags"].MaxLength)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/clock"
+	"golang.org/x/time/rate"
+)
+
+// Memory keeps a token bucket limiter per key in memory.
+type Memory struct {
+	Rate  rate.Limit
+	Burst int
+	// Idle is a time after which unused limiters are forgotten. NewMemory
+	// sets it to the time a bucket takes to refill, so forgotten keys start
+	// over as they would have been.
+	Idle time.Duration
+	// Clock is used for refills and idleness, real clock when nil.
+	Clock clock.Clock
+
+	mu        sync.Mutex
+	limiters  map[string]*limiter
+	nextSweep time.Time
+}
+
+// limiter is a token bucket with the time it was last used.
+type limiter struct {
+	*rate.Limiter
+	lastUsed time.Time
+}
+
+// NewMemory prepares in-memory limiter store allowing perSecond events per
+// key with bursts of burst events.
+func NewMemory(perSecond float64, burst int) *Memory {
+	if burst < 1 {
+		burst = 1
+	}
+
+	idle := time.Minute
+	if perSecond > 0 {
+		idle = time.Duration(math.Ceil(float64(burst) / perSecond * float64(time.Second)))
+	}
+
+	return &Memory{
+		Rate:     rate.Limit(perSecond),
+		Burst:    burst,
+		Idle:     idle,
+		limiters: make(map[string]*limiter),
+	}
+}
+
+// Allow reports whether an event for key may happen now, otherwise returns
+// the time to wait before retrying.
+func (m *Memory) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	now := clock.Now(m.Clock)
+	r := m.limiter(key, now).ReserveN(now, 1)
+	if d := r.DelayFrom(now); d > 0 {
+		r.CancelAt(now)
+		return false, d, nil
+	}
+
+	return true, 0, nil
+}
+
+// limiter returns limiter for key creating it when missing, limiters idle
+// for longer than Idle are swept on the way.
+func (m *Memory) limiter(key string, now time.Time) *rate.Limiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	l, ok := m.limiters[key]
+	if !ok {
+		l = &limiter{Limiter: rate.NewLimiter(m.Rate, m.Burst)}
+		m.limiters[key] = l
+	}
+	l.lastUsed = now
+
+	return l.Limiter
+}
+
+// sweep forgets idle limiters at most once per Idle, so a spread of keys
+// does not grow memory without bound.
+func (m *Memory) sweep(now time.Time) {
+	if now.Before(m.nextSweep) {
+		return
+	}
+
+	for k, l := range m.limiters {
+		if now.Sub(l.lastUsed) >= m.Idle {
+			delete(m.limiters, k)
+		}
+	}
+	m.nextSweep = now.Add(m.Idle)
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory(t *testing.T) {
+	t.Log("with limiter allowing burst of two.")
+	{
+		m := NewMemory(1, 2)
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould allow events within the burst.")
+		{
+			for i := 0; i < 2; i++ {
+				ok, _, err := m.Allow(ctx, "1.1.1.1")
+				assert.Nil(t, err)
+				assert.True(t, ok)
+			}
+		}
+
+		t.Log("\ttest:1\tshould reject event over the threshold with retry delay.")
+		{
+			ok, retry, err := m.Allow(ctx, "1.1.1.1")
+			assert.Nil(t, err)
+			assert.False(t, ok)
+			assert.True(t, retry > 0)
+		}
+
+		t.Log("\ttest:2\tshould limit keys independently.")
+		{
+			ok, _, err := m.Allow(ctx, "2.2.2.2")
+			assert.Nil(t, err)
+			assert.True(t, ok)
+		}
+	}
+}
+
+func TestMemoryEviction(t *testing.T) {
+	t.Log("with limiter allowing one event a second with burst of two.")
+	{
+		clock := &fakeClock{now: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+		m := NewMemory(1, 2)
+		m.Clock = clock
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould forget limiters idle until refilled.")
+		{
+			for _, key := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+				_, _, err := m.Allow(ctx, key)
+				assert.Nil(t, err)
+			}
+			assert.Len(t, m.limiters, 3)
+
+			clock.now = clock.now.Add(2 * time.Second)
+			_, _, err := m.Allow(ctx, "4.4.4.4")
+			assert.Nil(t, err)
+			assert.Len(t, m.limiters, 1)
+		}
+
+		t.Log("\ttest:1\tshould keep limiting keys in use.")
+		{
+			ok, _, err := m.Allow(ctx, "4.4.4.4")
+			assert.Nil(t, err)
+			assert.True(t, ok)
+
+			ok, _, err = m.Allow(ctx, "4.4.4.4")
+			assert.Nil(t, err)
+			assert.False(t, ok)
+		}
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
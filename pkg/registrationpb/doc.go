@@ -0,0 +1,4 @@
+// Package registrationpb holds gRPC bindings of proto/registration.proto.
+package registrationpb
+
+//go:generate sh -c "cd ../.. && buf generate"
@@ -0,0 +1,12 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/newtondev/service_object/pkg/clock"
+)
+
+// now returns UTC time of c falling back to time.Now when c is nil.
+func now(c clock.Clock) time.Time {
+	return clock.Now(c).UTC()
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator generates ids of new users.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// UUIDGenerator generates random UUIDs, stores use it when they have no
+// generator set.
+type UUIDGenerator struct{}
+
+// NewID implements IDGenerator.
+func (UUIDGenerator) NewID() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	return id.String(), nil
+}
+
+// SequentialGenerator generates increasing decimal ids following Last, it
+// keeps ids predictable in tests.
+type SequentialGenerator struct {
+	Last int
+
+	mu sync.Mutex
+}
+
+// NewID implements IDGenerator.
+func (g *SequentialGenerator) NewID() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.Last++
+	return strconv.Itoa(g.Last), nil
+}
+
+// newID returns id of g falling back to UUIDGenerator when g is nil.
+func newID(g IDGenerator) (string, error) {
+	if g == nil {
+		g = UUIDGenerator{}
+	}
+
+	return g.NewID()
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDGenerators(t *testing.T) {
+	t.Log("with uuid generator.")
+	{
+		t.Log("\ttest:0\tshould generate distinct valid uuids.")
+		{
+			a, err := UUIDGenerator{}.NewID()
+			assert.Nil(t, err)
+			b, err := UUIDGenerator{}.NewID()
+			assert.Nil(t, err)
+
+			assert.NotEqual(t, a, b)
+			_, err = uuid.Parse(a)
+			assert.Nil(t, err)
+		}
+
+		t.Log("\ttest:1\tshould be used when store has no generator.")
+		{
+			id, err := newID(nil)
+			assert.Nil(t, err)
+			_, err = uuid.Parse(id)
+			assert.Nil(t, err)
+		}
+	}
+
+	t.Log("with sequential generator.")
+	{
+		g := &SequentialGenerator{Last: 9}
+
+		t.Log("\ttest:0\tshould continue after the last id.")
+		{
+			for _, want := range []string{"10", "11", "12"} {
+				id, err := g.NewID()
+				assert.Nil(t, err)
+				assert.Equal(t, want, id)
+			}
+		}
+	}
+}
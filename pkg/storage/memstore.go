@@ -2,36 +2,492 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
+	"github.com/google/uuid"
+	"github.com/newtondev/service_object/pkg/clock"
 	"github.com/newtondev/service_object/pkg/entities"
 	"github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/hasher"
 )
 
-// MemStore is a memory storage for users.
+// MemStore is a memory storage for users. Deleted users are kept with
+// DeletedAt set and are hidden from lookups unless IncludeDeleted is set.
+// Its methods are safe for concurrent use, Users must not be changed
+// directly while they run.
 type MemStore struct {
-	Users []entities.User
+	Users  []entities.User
+	Hasher hasher.PasswordHasher
+	Clock  clock.Clock
+	// IDs generates ids of created users, UUIDs are used when nil.
+	IDs            IDGenerator
+	IncludeDeleted bool
+
+	mu sync.Mutex
+	// history holds previous password hashes by user id, oldest first.
+	history map[string][]string
+
+	// index maps lowercased emails of not deleted users to positions in
+	// Users, indexed is the length of Users it was built for.
+	index   map[string]int
+	indexed int
+}
+
+// NewMemStore prepares memory storage seeded with users, users without id
+// are given a random one and users without role get the user role as on
+// create. It panics when not deleted users share an email, create would
+// never store them.
+func NewMemStore(users ...entities.User) *MemStore {
+	s := &MemStore{Users: make([]entities.User, 0, len(users))}
+	for _, u := range users {
+		if u.ID == "" {
+			u.ID = uuid.NewString()
+		}
+		if u.Role == "" {
+			u.Role = entities.RoleUser
+		}
+		s.Users = append(s.Users, u)
+	}
+
+	s.reindex()
+	for i, u := range s.Users {
+		if j := s.index[indexKey(u.Email)]; u.DeletedAt == nil && j != i {
+			panic(fmt.Sprintf("storage: seeded users %q and %q share email %q", u.ID, s.Users[j].ID, u.Email))
+		}
+	}
+
+	return s
+}
+
+// Ping implements readiness check, memory storage is always reachable.
+func (s *MemStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// CheckWrite implements write check, memory storage always accepts writes.
+func (s *MemStore) CheckWrite(ctx context.Context) error {
+	return nil
+}
+
+// Begin implements no-op transaction, changes are applied right away.
+func (s *MemStore) Begin(ctx context.Context) (*MemStore, error) {
+	return s, nil
+}
+
+// Commit implements no-op transaction.
+func (s *MemStore) Commit() error {
+	return nil
+}
+
+// Rollback implements no-op transaction, changes are not discarded.
+func (s *MemStore) Rollback() error {
+	return nil
 }
 
 // Unique checks if a email exists in the database.
 func (s *MemStore) Unique(ctx context.Context, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findByEmail(email); err == nil {
+		return errors.ErrEmailExists
+	}
+
+	return nil
+}
+
+// UniqueUsername checks if a username exists in the database.
+func (s *MemStore) UniqueUsername(ctx context.Context, username string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	for _, u := range s.Users {
-		if u.Email == email {
-			return errors.ErrEmailExists
+		if u.Username == username && s.visible(u) {
+			return errors.ErrUsernameExists
 		}
 	}
 
 	return nil
 }
 
+// FindByEmail finds user by email normalized the same way registration does.
+func (s *MemStore) FindByEmail(ctx context.Context, email string) (*entities.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.findByEmail(email)
+}
+
+// findByEmail finds user by email, s.mu must be held.
+func (s *MemStore) findByEmail(email string) (*entities.User, error) {
+	if s.IncludeDeleted {
+		key := indexKey(email)
+		for _, u := range s.Users {
+			if indexKey(u.Email) == key {
+				return &u, nil
+			}
+		}
+
+		return nil, errors.ErrUserNotFound
+	}
+
+	i, ok := s.lookup(email)
+	if !ok {
+		return nil, errors.ErrUserNotFound
+	}
+
+	u := s.Users[i]
+	return &u, nil
+}
+
+// FindByID finds user by id.
+func (s *MemStore) FindByID(ctx context.Context, id string) (*entities.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.Users {
+		if u.ID == id && s.visible(u) {
+			return &u, nil
+		}
+	}
+
+	return nil, errors.ErrUserNotFound
+}
+
+// List returns at most limit users starting at offset ordered by id.
+func (s *MemStore) List(ctx context.Context, offset, limit int) ([]entities.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := []entities.User{}
+	for _, u := range s.Users {
+		if !s.visible(u) {
+			continue
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		if len(users) == limit {
+			break
+		}
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// Count returns number of users.
+func (s *MemStore) Count(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, u := range s.Users {
+		if s.visible(u) {
+			n++
+		}
+	}
+
+	return n, ctx.Err()
+}
+
 // Create creates user in the database for a form.
 func (s *MemStore) Create(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	hash, err := hashPassword(s.Hasher, f.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newID(s.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// hashing is slow, so the request may be gone by now.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	u := entities.User{
-		ID:       len(s.Users) + 1,
-		Password: f.Password,
+		ID:       id,
+		Password: hash,
 		Email:    f.Email,
+		Username: f.Username,
+		Role:     entities.RoleUser,
+
+		FirstName:   f.FirstName,
+		LastName:    f.LastName,
+		DisplayName: f.DisplayName,
 	}
+	u.CreatedAt = now(s.Clock)
+	u.UpdatedAt = u.CreatedAt
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ensureIndex()
 	s.Users = append(s.Users, u)
+	s.index[indexKey(u.Email)] = len(s.Users) - 1
+	s.indexed = len(s.Users)
 
 	return &u, nil
 }
+
+// Update replaces email, password and names of the user.
+func (s *MemStore) Update(ctx context.Context, id string, f *entities.Form) (*entities.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// hashing is slow, so it is done before taking the lock.
+	hash, err := hashPassword(s.Hasher, f.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ensureIndex()
+	for i := range s.Users {
+		if s.Users[i].ID != id || s.Users[i].DeletedAt != nil {
+			continue
+		}
+
+		delete(s.index, indexKey(s.Users[i].Email))
+		s.Users[i].Email = f.Email
+		s.Users[i].Password = hash
+		s.Users[i].FirstName = f.FirstName
+		s.Users[i].LastName = f.LastName
+		s.Users[i].DisplayName = f.DisplayName
+		s.Users[i].UpdatedAt = now(s.Clock)
+		s.index[indexKey(f.Email)] = i
+		u := s.Users[i]
+
+		return &u, nil
+	}
+
+	return nil, errors.ErrUserNotFound
+}
+
+// Delete marks user as deleted keeping its record.
+func (s *MemStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ensureIndex()
+	for i := range s.Users {
+		if s.Users[i].ID == id && s.Users[i].DeletedAt == nil {
+			t := now(s.Clock)
+			s.Users[i].DeletedAt = &t
+			delete(s.index, indexKey(s.Users[i].Email))
+			return nil
+		}
+	}
+
+	return errors.ErrUserNotFound
+}
+
+// Restore clears deletion mark of the user. It fails with ErrEmailExists
+// when the email was taken since the user was deleted.
+func (s *MemStore) Restore(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ensureIndex()
+	for i := range s.Users {
+		if s.Users[i].ID != id || s.Users[i].DeletedAt == nil {
+			continue
+		}
+
+		key := indexKey(s.Users[i].Email)
+		if _, ok := s.index[key]; ok {
+			return errors.ErrEmailExists
+		}
+
+		s.Users[i].DeletedAt = nil
+		s.Users[i].UpdatedAt = now(s.Clock)
+		s.index[key] = i
+		return nil
+	}
+
+	return errors.ErrUserNotFound
+}
+
+// SetVerified marks user as verified.
+func (s *MemStore) SetVerified(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.Users {
+		if s.Users[i].ID == id {
+			s.Users[i].Verified = true
+			s.Users[i].UpdatedAt = now(s.Clock)
+			return nil
+		}
+	}
+
+	return errors.ErrUserNotFound
+}
+
+// UpdatePassword replaces password hash of the user.
+func (s *MemStore) UpdatePassword(ctx context.Context, id string, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.Users {
+		if s.Users[i].ID == id {
+			s.Users[i].Password = hash
+			s.Users[i].UpdatedAt = now(s.Clock)
+			return nil
+		}
+	}
+
+	return errors.ErrUserNotFound
+}
+
+// PasswordHistory returns at most limit previous password hashes of the
+// user, latest first.
+func (s *MemStore) PasswordHistory(ctx context.Context, id string, limit int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var hashes []string
+	previous := s.history[id]
+	for i := len(previous) - 1; i >= 0 && len(hashes) < limit; i-- {
+		hashes = append(hashes, previous[i])
+	}
+
+	return hashes, nil
+}
+
+// AddPasswordHistory records a previous password hash of the user.
+func (s *MemStore) AddPasswordHistory(ctx context.Context, id string, hash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.history == nil {
+		s.history = make(map[string][]string)
+	}
+	s.history[id] = append(s.history[id], hash)
+
+	return nil
+}
+
+// MemSnapshot is a copy of users and password histories of a MemStore.
+type MemSnapshot struct {
+	users   []entities.User
+	history map[string][]string
+}
+
+// Snapshot returns a copy of the store state, later changes of the store
+// do not affect it.
+func (s *MemStore) Snapshot() *MemSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &MemSnapshot{users: copyUsers(s.Users), history: copyHistory(s.history)}
+}
+
+// RestoreSnapshot resets the store to the state of snap, snap stays usable
+// for further restores.
+func (s *MemStore) RestoreSnapshot(snap *MemSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Users = copyUsers(snap.users)
+	s.history = copyHistory(snap.history)
+	s.reindex()
+}
+
+// copyUsers returns a deep copy of users.
+func copyUsers(users []entities.User) []entities.User {
+	copied := make([]entities.User, len(users))
+	for i, u := range users {
+		if u.DeletedAt != nil {
+			t := *u.DeletedAt
+			u.DeletedAt = &t
+		}
+		copied[i] = u
+	}
+
+	return copied
+}
+
+// copyHistory returns a deep copy of password histories.
+func copyHistory(history map[string][]string) map[string][]string {
+	if history == nil {
+		return nil
+	}
+
+	copied := make(map[string][]string, len(history))
+	for id, hashes := range history {
+		copied[id] = append([]string(nil), hashes...)
+	}
+
+	return copied
+}
+
+// lookup returns position of the user with email in Users.
+func (s *MemStore) lookup(email string) (int, bool) {
+	s.ensureIndex()
+
+	i, ok := s.index[indexKey(email)]
+	return i, ok
+}
+
+// ensureIndex rebuilds the email index when Users were changed directly.
+func (s *MemStore) ensureIndex() {
+	if s.index == nil || s.indexed != len(s.Users) {
+		s.reindex()
+	}
+}
+
+// reindex rebuilds the email index from not deleted Users.
+func (s *MemStore) reindex() {
+	s.index = make(map[string]int, len(s.Users))
+	s.indexed = len(s.Users)
+	for i, u := range s.Users {
+		if u.DeletedAt == nil {
+			s.index[indexKey(u.Email)] = i
+		}
+	}
+}
+
+// visible reports whether u is returned by lookups of the store.
+func (s *MemStore) visible(u entities.User) bool {
+	return u.DeletedAt == nil || s.IncludeDeleted
+}
+
+// indexKey normalizes email for case-insensitive lookups.
+func indexKey(email string) string {
+	return entities.NormalizeEmail(email, true)
+}
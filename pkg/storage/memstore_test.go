@@ -0,0 +1,523 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/hasher"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMemStoreCreate(t *testing.T) {
+	t.Log("with empty memory store.")
+	{
+		s := MemStore{Hasher: hasher.NewBcrypt(bcrypt.MinCost)}
+
+		t.Log("\ttest:0\tshould store only the password hash.")
+		{
+			u, err := s.Create(context.Background(), &entities.Form{Email: "new@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+			assert.NotEqual(t, "qwerty", u.Password)
+			assert.NotEqual(t, "qwerty", s.Users[0].Password)
+			assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(u.Password), []byte("qwerty")))
+		}
+	}
+}
+
+func TestNewMemStore(t *testing.T) {
+	t.Log("with memory store seeded with users.")
+	{
+		ctx := context.Background()
+		deleted := testNow
+		s := NewMemStore(
+			entities.User{ID: "admin", Email: "admin@domain.zone", Role: entities.RoleAdmin},
+			entities.User{Email: "Demo@Domain.zone"},
+			entities.User{ID: "gone", Email: "gone@domain.zone", DeletedAt: &deleted},
+		)
+
+		t.Log("\ttest:0\tshould keep given ids and assign missing ones.")
+		{
+			assert.Equal(t, "admin", s.Users[0].ID)
+			assert.NotEmpty(t, s.Users[1].ID)
+		}
+
+		t.Log("\ttest:1\tshould default the role like create does.")
+		{
+			assert.Equal(t, entities.RoleAdmin, s.Users[0].Role)
+			assert.Equal(t, entities.RoleUser, s.Users[1].Role)
+		}
+
+		t.Log("\ttest:2\tshould find seeded users by email.")
+		{
+			u, err := s.FindByEmail(ctx, "demo@domain.zone")
+			assert.Nil(t, err)
+			assert.Equal(t, s.Users[1].ID, u.ID)
+		}
+
+		t.Log("\ttest:3\tshould report seeded emails as taken.")
+		{
+			assert.Equal(t, errors.ErrEmailExists, s.Unique(ctx, "admin@domain.zone"))
+			assert.Equal(t, errors.ErrEmailExists, s.Unique(ctx, "DEMO@domain.zone"))
+			assert.Nil(t, s.Unique(ctx, "new@domain.zone"))
+		}
+
+		t.Log("\ttest:4\tshould not index deleted users.")
+		{
+			assert.Nil(t, s.Unique(ctx, "gone@domain.zone"))
+		}
+	}
+
+	t.Log("with seeded users sharing an email.")
+	{
+		t.Log("\ttest:0\tshould panic.")
+		{
+			assert.Panics(t, func() {
+				NewMemStore(entities.User{Email: "a@domain.zone"}, entities.User{Email: "A@domain.zone"})
+			})
+		}
+	}
+}
+
+func TestMemStoreTimestamps(t *testing.T) {
+	t.Log("with memory store on a fake clock.")
+	{
+		ctx := context.Background()
+		clock := &fakeClock{testNow}
+		s := MemStore{Hasher: fakeHasher{}, Clock: clock, IDs: &SequentialGenerator{}}
+
+		t.Log("\ttest:0\tshould set both timestamps on create.")
+		{
+			u, err := s.Create(ctx, &entities.Form{Email: "new@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+			assert.Equal(t, testNow, u.CreatedAt)
+			assert.Equal(t, testNow, u.UpdatedAt)
+		}
+
+		t.Log("\ttest:1\tshould move only updated_at forward on update.")
+		{
+			clock.Advance(time.Minute)
+
+			u, err := s.Update(ctx, "1", &entities.Form{Email: "new@domain.zone", Password: "other"})
+			assert.Nil(t, err)
+			assert.Equal(t, testNow, u.CreatedAt)
+			assert.True(t, u.UpdatedAt.After(u.CreatedAt))
+
+			found, err := s.FindByID(ctx, "1")
+			assert.Nil(t, err)
+			assert.Equal(t, u.UpdatedAt, found.UpdatedAt)
+		}
+	}
+}
+
+func TestMemStoreDelete(t *testing.T) {
+	t.Log("with populated memory store.")
+	{
+		ctx := context.Background()
+		s := MemStore{Hasher: fakeHasher{}, IDs: &SequentialGenerator{}}
+		for _, email := range []string{"a@domain.zone", "b@domain.zone", "c@domain.zone"} {
+			_, err := s.Create(ctx, &entities.Form{Email: email, Password: "qwerty"})
+			assert.Nil(t, err)
+		}
+
+		t.Log("\ttest:0\tshould delete existing user keeping other ids.")
+		{
+			assert.Nil(t, s.Delete(ctx, "2"))
+
+			_, err := s.FindByID(ctx, "2")
+			assert.Equal(t, errors.ErrUserNotFound, err)
+
+			u, err := s.FindByID(ctx, "3")
+			assert.Nil(t, err)
+			assert.Equal(t, "c@domain.zone", u.Email)
+		}
+
+		t.Log("\ttest:1\tshould return not found for missing user.")
+		{
+			assert.Equal(t, errors.ErrUserNotFound, s.Delete(ctx, "42"))
+		}
+
+		t.Log("\ttest:2\tshould return not found on double delete.")
+		{
+			assert.Nil(t, s.Delete(ctx, "1"))
+			assert.Equal(t, errors.ErrUserNotFound, s.Delete(ctx, "1"))
+		}
+
+		t.Log("\ttest:3\tshould not reuse ids of deleted users.")
+		{
+			assert.Nil(t, s.Delete(ctx, "3"))
+
+			u, err := s.Create(ctx, &entities.Form{Email: "d@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+			assert.Equal(t, "4", u.ID)
+		}
+	}
+}
+
+func TestMemStoreSoftDelete(t *testing.T) {
+	t.Log("with deleted user.")
+	{
+		ctx := context.Background()
+		s := MemStore{Hasher: fakeHasher{}, Clock: &fakeClock{testNow}, IDs: &SequentialGenerator{}}
+		for _, email := range []string{"a@domain.zone", "b@domain.zone"} {
+			_, err := s.Create(ctx, &entities.Form{Email: email, Password: "qwerty"})
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, s.Delete(ctx, "1"))
+
+		t.Log("\ttest:0\tshould keep the record marked as deleted.")
+		{
+			assert.Len(t, s.Users, 2)
+			assert.Equal(t, testNow, *s.Users[0].DeletedAt)
+		}
+
+		t.Log("\ttest:1\tshould hide user from lookups and uniqueness.")
+		{
+			assert.Nil(t, s.Unique(ctx, "a@domain.zone"))
+
+			_, err := s.FindByEmail(ctx, "a@domain.zone")
+			assert.Equal(t, errors.ErrUserNotFound, err)
+
+			users, err := s.List(ctx, 0, 10)
+			assert.Nil(t, err)
+			assert.Len(t, users, 1)
+			assert.Equal(t, "2", users[0].ID)
+		}
+
+		t.Log("\ttest:2\tshould return user when deleted are included.")
+		{
+			s.IncludeDeleted = true
+			assert.Equal(t, errors.ErrEmailExists, s.Unique(ctx, "a@domain.zone"))
+
+			u, err := s.FindByEmail(ctx, "a@domain.zone")
+			assert.Nil(t, err)
+			assert.Equal(t, "1", u.ID)
+
+			users, err := s.List(ctx, 0, 10)
+			assert.Nil(t, err)
+			assert.Len(t, users, 2)
+			s.IncludeDeleted = false
+		}
+
+		t.Log("\ttest:3\tshould restore deleted user.")
+		{
+			assert.Nil(t, s.Restore(ctx, "1"))
+			assert.Equal(t, errors.ErrUserNotFound, s.Restore(ctx, "1"))
+			assert.Equal(t, errors.ErrEmailExists, s.Unique(ctx, "a@domain.zone"))
+
+			u, err := s.FindByID(ctx, "1")
+			assert.Nil(t, err)
+			assert.Nil(t, u.DeletedAt)
+		}
+
+		t.Log("\ttest:4\tshould not restore user whose email was taken.")
+		{
+			assert.Nil(t, s.Delete(ctx, "1"))
+			_, err := s.Create(ctx, &entities.Form{Email: "a@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+
+			assert.Equal(t, errors.ErrEmailExists, s.Restore(ctx, "1"))
+		}
+	}
+}
+
+func TestMemStoreList(t *testing.T) {
+	t.Log("with three stored users.")
+	{
+		s := MemStore{Users: []entities.User{{ID: "1"}, {ID: "2"}, {ID: "3"}}}
+
+		t.Log("\ttest:0\tshould return users within the window.")
+		{
+			users, err := s.List(context.Background(), 1, 5)
+			assert.Nil(t, err)
+			assert.Equal(t, []entities.User{{ID: "2"}, {ID: "3"}}, users)
+
+			n, err := s.Count(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, 3, n)
+		}
+
+		t.Log("\ttest:1\tshould return empty list past the end.")
+		{
+			users, err := s.List(context.Background(), 3, 5)
+			assert.Nil(t, err)
+			assert.NotNil(t, users)
+			assert.Empty(t, users)
+		}
+	}
+}
+
+func TestMemStoreCount(t *testing.T) {
+	t.Log("with users created and deleted.")
+	{
+		s := MemStore{Hasher: fakeHasher{}, IDs: &SequentialGenerator{}}
+		ctx := context.Background()
+		for _, email := range []string{"a@domain.zone", "b@domain.zone", "c@domain.zone"} {
+			_, err := s.Create(ctx, &entities.Form{Email: email, Password: "qwerty"})
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, s.Delete(ctx, "2"))
+
+		t.Log("\ttest:0\tshould count only live users.")
+		{
+			n, err := s.Count(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, n)
+		}
+
+		t.Log("\ttest:1\tshould count deleted users when they are included.")
+		{
+			s.IncludeDeleted = true
+			n, err := s.Count(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 3, n)
+		}
+	}
+}
+
+func TestMemStoreContext(t *testing.T) {
+	t.Log("with cancelled context.")
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s := MemStore{Users: []entities.User{{ID: "1", Email: "exists@domain.zone"}}, Hasher: fakeHasher{}}
+
+		t.Log("\ttest:0\tshould not check uniqueness.")
+		{
+			assert.Equal(t, context.Canceled, s.Unique(ctx, "new@domain.zone"))
+		}
+
+		t.Log("\ttest:1\tshould not create user.")
+		{
+			u, err := s.Create(ctx, &entities.Form{Email: "new@domain.zone", Password: "qwerty"})
+			assert.Nil(t, u)
+			assert.Equal(t, context.Canceled, err)
+			assert.Len(t, s.Users, 1)
+		}
+	}
+}
+
+func TestMemStoreFindByEmail(t *testing.T) {
+	t.Log("with populated memory store.")
+	{
+		ctx := context.Background()
+		s := MemStore{Users: []entities.User{{ID: "1", Email: "exists@domain.zone"}}}
+
+		t.Log("\ttest:0\tshould find existing user.")
+		{
+			u, err := s.FindByEmail(ctx, "exists@domain.zone")
+			assert.Nil(t, err)
+			assert.Equal(t, "1", u.ID)
+		}
+
+		t.Log("\ttest:1\tshould find user by not normalized email.")
+		{
+			u, err := s.FindByEmail(ctx, " exists@DOMAIN.zone ")
+			assert.Nil(t, err)
+			assert.Equal(t, "1", u.ID)
+		}
+
+		t.Log("\ttest:2\tshould return not found for missing user.")
+		{
+			u, err := s.FindByEmail(ctx, "missing@domain.zone")
+			assert.Nil(t, u)
+			assert.Equal(t, errors.ErrUserNotFound, err)
+		}
+
+		t.Log("\ttest:3\tshould match email case-insensitively.")
+		{
+			u, err := s.FindByEmail(ctx, "Exists@Domain.Zone")
+			assert.Nil(t, err)
+			assert.Equal(t, "1", u.ID)
+			assert.Equal(t, errors.ErrEmailExists, s.Unique(ctx, "EXISTS@domain.zone"))
+		}
+	}
+}
+
+func TestMemStoreIndex(t *testing.T) {
+	t.Log("with populated memory store.")
+	{
+		ctx := context.Background()
+		s := MemStore{Hasher: fakeHasher{}, IDs: &SequentialGenerator{}}
+		for _, email := range []string{"a@domain.zone", "b@domain.zone", "c@domain.zone"} {
+			_, err := s.Create(ctx, &entities.Form{Email: email, Password: "qwerty"})
+			assert.Nil(t, err)
+		}
+
+		t.Log("\ttest:0\tshould find users shifted by a delete.")
+		{
+			assert.Nil(t, s.Delete(ctx, "1"))
+
+			_, err := s.FindByEmail(ctx, "a@domain.zone")
+			assert.Equal(t, errors.ErrUserNotFound, err)
+			assert.Nil(t, s.Unique(ctx, "a@domain.zone"))
+
+			u, err := s.FindByEmail(ctx, "c@domain.zone")
+			assert.Nil(t, err)
+			assert.Equal(t, "3", u.ID)
+		}
+
+		t.Log("\ttest:1\tshould follow email changes.")
+		{
+			_, err := s.Update(ctx, "2", &entities.Form{Email: "new@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+
+			assert.Nil(t, s.Unique(ctx, "b@domain.zone"))
+			u, err := s.FindByEmail(ctx, "new@domain.zone")
+			assert.Nil(t, err)
+			assert.Equal(t, "2", u.ID)
+		}
+
+		t.Log("\ttest:2\tshould index users created after a delete.")
+		{
+			u, err := s.Create(ctx, &entities.Form{Email: "a@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+
+			found, err := s.FindByEmail(ctx, "a@domain.zone")
+			assert.Nil(t, err)
+			assert.Equal(t, u.ID, found.ID)
+		}
+	}
+}
+
+func BenchmarkMemStoreFindByEmail(b *testing.B) {
+	s := MemStore{}
+	for i := 0; i < 10000; i++ {
+		s.Users = append(s.Users, entities.User{ID: strconv.Itoa(i + 1), Email: fmt.Sprintf("user%d@domain.zone", i)})
+	}
+	email := "user9999@domain.zone"
+	ctx := context.Background()
+
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s.FindByEmail(ctx, email)
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scanEmail(s.Users, email)
+		}
+	})
+}
+
+// scanEmail is the linear lookup MemStore used before the index.
+func scanEmail(users []entities.User, email string) *entities.User {
+	email = entities.NormalizeEmail(email, false)
+	for _, u := range users {
+		if u.Email == email {
+			return &u
+		}
+	}
+
+	return nil
+}
+
+func TestMemStorePasswordHistory(t *testing.T) {
+	t.Log("with three previous passwords of a user.")
+	{
+		s := MemStore{}
+		ctx := context.Background()
+		for _, hash := range []string{"hash:1", "hash:2", "hash:3"} {
+			assert.Nil(t, s.AddPasswordHistory(ctx, "1", hash))
+		}
+
+		t.Log("\ttest:0\tshould return the latest hashes first up to the limit.")
+		{
+			hashes, err := s.PasswordHistory(ctx, "1", 2)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"hash:3", "hash:2"}, hashes)
+		}
+
+		t.Log("\ttest:1\tshould keep histories of users apart.")
+		{
+			hashes, err := s.PasswordHistory(ctx, "2", 2)
+			assert.Nil(t, err)
+			assert.Empty(t, hashes)
+		}
+	}
+}
+
+func TestMemStoreSnapshot(t *testing.T) {
+	t.Log("with snapshot of a memory store with two users.")
+	{
+		ctx := context.Background()
+		s := MemStore{Hasher: fakeHasher{}, IDs: &SequentialGenerator{}}
+		for _, email := range []string{"a@domain.zone", "b@domain.zone"} {
+			_, err := s.Create(ctx, &entities.Form{Email: email, Password: "qwerty"})
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, s.AddPasswordHistory(ctx, "1", "hash:1"))
+		baseline := append([]entities.User(nil), s.Users...)
+		snap := s.Snapshot()
+
+		_, err := s.Create(ctx, &entities.Form{Email: "c@domain.zone", Password: "qwerty"})
+		assert.Nil(t, err)
+		assert.Nil(t, s.Delete(ctx, "1"))
+		assert.Nil(t, s.AddPasswordHistory(ctx, "1", "hash:2"))
+
+		t.Log("\ttest:0\tshould not change with the store.")
+		{
+			assert.Len(t, snap.users, 2)
+			assert.Nil(t, snap.users[0].DeletedAt)
+		}
+
+		t.Log("\ttest:1\tshould reset the store to the snapshot.")
+		{
+			s.RestoreSnapshot(snap)
+			assert.Equal(t, baseline, s.Users)
+
+			hashes, err := s.PasswordHistory(ctx, "1", 5)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"hash:1"}, hashes)
+		}
+
+		t.Log("\ttest:2\tshould rebuild the email index.")
+		{
+			assert.Nil(t, s.Unique(ctx, "c@domain.zone"))
+			assert.Equal(t, errors.ErrEmailExists, s.Unique(ctx, "a@domain.zone"))
+		}
+
+		t.Log("\ttest:3\tshould stay usable for further restores.")
+		{
+			assert.Nil(t, s.Delete(ctx, "2"))
+			s.RestoreSnapshot(snap)
+			assert.Equal(t, baseline, s.Users)
+		}
+	}
+
+	t.Log("with users created concurrently.")
+	{
+		ctx := context.Background()
+		s := MemStore{Hasher: fakeHasher{}, IDs: &SequentialGenerator{}}
+		snap := s.Snapshot()
+
+		t.Log("\ttest:0\tshould snapshot and restore safely.")
+		{
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(2)
+				go func(i int) {
+					defer wg.Done()
+					s.Create(ctx, &entities.Form{Email: fmt.Sprintf("%d@domain.zone", i), Password: "qwerty"})
+				}(i)
+				go func() {
+					defer wg.Done()
+					s.Snapshot()
+				}()
+			}
+			wg.Wait()
+
+			s.RestoreSnapshot(snap)
+			n, err := s.Count(ctx)
+			assert.Nil(t, err)
+			assert.Zero(t, n)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/newtondev/service_object/pkg/clock"
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/hasher"
+)
+
+// mysqlDuplicateEntry is the mysql error number for unique key violations.
+const mysqlDuplicateEntry = 1062
+
+// MySQLSchema creates users table. Emails and usernames of deleted users
+// stay reserved as mysql has no partial unique indexes.
+const MySQLSchema = `CREATE TABLE IF NOT EXISTS users (
+	id           VARCHAR(36) PRIMARY KEY,
+	email        VARCHAR(254) NOT NULL UNIQUE,
+	username     VARCHAR(64) UNIQUE,
+	password     VARCHAR(255) NOT NULL,
+	verified     BOOLEAN NOT NULL DEFAULT FALSE,
+	role         VARCHAR(32) NOT NULL DEFAULT 'user',
+	first_name   VARCHAR(255) NOT NULL DEFAULT '',
+	last_name    VARCHAR(255) NOT NULL DEFAULT '',
+	display_name VARCHAR(255) NOT NULL DEFAULT '',
+	created_at   DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	updated_at   DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	deleted_at   DATETIME(6)
+)`
+
+// MySQLCanarySchema creates canary table written by write checks, it is a
+// separate statement as mysql executes one statement at a time by default.
+const MySQLCanarySchema = `CREATE TABLE IF NOT EXISTS canary (
+	id         INT PRIMARY KEY,
+	checked_at DATETIME(6) NOT NULL
+)`
+
+// MySQLStore is a mysql storage for users.
+type MySQLStore struct {
+	DB     *sql.DB
+	Hasher hasher.PasswordHasher
+	Clock  clock.Clock
+	// IDs generates ids of created users, UUIDs are used when nil.
+	IDs IDGenerator
+	// IncludeDeleted makes lookups return deleted users too.
+	IncludeDeleted bool
+
+	tx *sql.Tx
+}
+
+// NewMySQLStore prepares mysql storage. The DSN of db must set parseTime=true
+// for timestamps to be scanned.
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{DB: db}
+}
+
+// Migrate creates users and canary tables if they are missing.
+func (s *MySQLStore) Migrate(ctx context.Context) error {
+	if _, err := s.db().ExecContext(ctx, MySQLSchema); err != nil {
+		return err
+	}
+
+	_, err := s.db().ExecContext(ctx, MySQLCanarySchema)
+	return err
+}
+
+// Ping checks the database is reachable.
+func (s *MySQLStore) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+// CheckWrite checks the database accepts writes by updating the canary row.
+func (s *MySQLStore) CheckWrite(ctx context.Context) error {
+	_, err := s.db().ExecContext(ctx, `INSERT INTO canary (id, checked_at) VALUES (1, ?) ON DUPLICATE KEY UPDATE checked_at = VALUES(checked_at)`, now(s.Clock))
+	return err
+}
+
+// Unique checks if a email exists in the database.
+func (s *MySQLStore) Unique(ctx context.Context, email string) error {
+	var exists bool
+	err := s.db().QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND `+notDeleted(s.IncludeDeleted)+`)`, email).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.ErrEmailExists
+	}
+
+	return nil
+}
+
+// UniqueUsername checks if a username exists in the database.
+func (s *MySQLStore) UniqueUsername(ctx context.Context, username string) error {
+	var exists bool
+	err := s.db().QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE username = ? AND `+notDeleted(s.IncludeDeleted)+`)`, username).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.ErrUsernameExists
+	}
+
+	return nil
+}
+
+// FindByEmail finds user by email normalized the same way registration does.
+func (s *MySQLStore) FindByEmail(ctx context.Context, email string) (*entities.User, error) {
+	return findUser(ctx, s.db(), `SELECT id, email, COALESCE(username, ''), password, verified, role, first_name, last_name, display_name, created_at, updated_at, deleted_at FROM users WHERE email = ? AND `+notDeleted(s.IncludeDeleted), entities.NormalizeEmail(email, false))
+}
+
+// FindByID finds user by id.
+func (s *MySQLStore) FindByID(ctx context.Context, id string) (*entities.User, error) {
+	return findUser(ctx, s.db(), `SELECT id, email, COALESCE(username, ''), password, verified, role, first_name, last_name, display_name, created_at, updated_at, deleted_at FROM users WHERE id = ? AND `+notDeleted(s.IncludeDeleted), id)
+}
+
+// List returns at most limit users starting at offset ordered by id.
+func (s *MySQLStore) List(ctx context.Context, offset, limit int) ([]entities.User, error) {
+	return listUsers(ctx, s.db(), `SELECT id, email, COALESCE(username, ''), password, verified, role, first_name, last_name, display_name, created_at, updated_at, deleted_at FROM users WHERE `+notDeleted(s.IncludeDeleted)+` ORDER BY created_at, id LIMIT ? OFFSET ?`, limit, offset)
+}
+
+// Count returns number of users.
+func (s *MySQLStore) Count(ctx context.Context) (int, error) {
+	var n int
+	err := s.db().QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE `+notDeleted(s.IncludeDeleted)).Scan(&n)
+	return n, err
+}
+
+// Create creates user in the database for a form.
+func (s *MySQLStore) Create(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	hash, err := hashPassword(s.Hasher, f.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newID(s.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	t := now(s.Clock)
+	u := entities.User{
+		ID:        id,
+		Password:  hash,
+		Email:     f.Email,
+		Username:  f.Username,
+		Role:      entities.RoleUser,
+		CreatedAt: t,
+		UpdatedAt: t,
+
+		FirstName:   f.FirstName,
+		LastName:    f.LastName,
+		DisplayName: f.DisplayName,
+	}
+
+	_, err = s.db().ExecContext(ctx, `INSERT INTO users (id, email, username, password, role, first_name, last_name, display_name, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, u.ID, u.Email, nullString(u.Username), u.Password, u.Role, u.FirstName, u.LastName, u.DisplayName, u.CreatedAt, u.UpdatedAt)
+	if err != nil {
+		if isMySQLDuplicate(err) {
+			return nil, mysqlExists(err)
+		}
+
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// Update replaces email, password and names of the user.
+func (s *MySQLStore) Update(ctx context.Context, id string, f *entities.Form) (*entities.User, error) {
+	hash, err := hashPassword(s.Hasher, f.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	// mysql reports zero affected rows for unchanged values, so the user is
+	// loaded afterwards to detect missing ones.
+	_, err = s.db().ExecContext(ctx, `UPDATE users SET email = ?, password = ?, first_name = ?, last_name = ?, display_name = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`, f.Email, hash, f.FirstName, f.LastName, f.DisplayName, now(s.Clock), id)
+	if err != nil {
+		if isMySQLDuplicate(err) {
+			return nil, mysqlExists(err)
+		}
+
+		return nil, err
+	}
+
+	return s.FindByID(ctx, id)
+}
+
+// Delete marks user as deleted keeping its row.
+func (s *MySQLStore) Delete(ctx context.Context, id string) error {
+	return execUser(ctx, s.db(), `UPDATE users SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, now(s.Clock), id)
+}
+
+// Restore clears deletion mark of the user. It fails with ErrEmailExists or
+// ErrUsernameExists when they were taken since the user was deleted.
+func (s *MySQLStore) Restore(ctx context.Context, id string) error {
+	err := execUser(ctx, s.db(), `UPDATE users SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL`, now(s.Clock), id)
+	if isMySQLDuplicate(err) {
+		return mysqlExists(err)
+	}
+
+	return err
+}
+
+// SetVerified marks user as verified.
+func (s *MySQLStore) SetVerified(ctx context.Context, id string) error {
+	if _, err := s.db().ExecContext(ctx, `UPDATE users SET verified = TRUE, updated_at = ? WHERE id = ?`, now(s.Clock), id); err != nil {
+		return err
+	}
+
+	_, err := s.FindByID(ctx, id)
+	return err
+}
+
+// UpdatePassword replaces password hash of the user.
+func (s *MySQLStore) UpdatePassword(ctx context.Context, id string, hash string) error {
+	if _, err := s.db().ExecContext(ctx, `UPDATE users SET password = ?, updated_at = ? WHERE id = ?`, hash, now(s.Clock), id); err != nil {
+		return err
+	}
+
+	_, err := s.FindByID(ctx, id)
+	return err
+}
+
+// isMySQLDuplicate reports whether err is a unique key violation.
+func isMySQLDuplicate(err error) bool {
+	myErr, ok := err.(*mysql.MySQLError)
+	return ok && myErr.Number == mysqlDuplicateEntry
+}
+
+// mysqlExists returns the exists error of the unique key a duplicate entry
+// err violates, mysql names the key in the message only.
+func mysqlExists(err error) error {
+	if strings.Contains(err.(*mysql.MySQLError).Message, "username") {
+		return errors.ErrUsernameExists
+	}
+
+	return errors.ErrEmailExists
+}
+
+// Begin starts a transaction and returns a store running queries within it.
+func (s *MySQLStore) Begin(ctx context.Context) (*MySQLStore, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MySQLStore{DB: s.DB, Hasher: s.Hasher, Clock: s.Clock, IDs: s.IDs, IncludeDeleted: s.IncludeDeleted, tx: tx}, nil
+}
+
+// Commit applies changes of the store transaction.
+func (s *MySQLStore) Commit() error {
+	if s.tx == nil {
+		return sql.ErrTxDone
+	}
+
+	return s.tx.Commit()
+}
+
+// Rollback discards changes of the store transaction.
+func (s *MySQLStore) Rollback() error {
+	if s.tx == nil {
+		return sql.ErrTxDone
+	}
+
+	return s.tx.Rollback()
+}
+
+// db returns the transaction of the store or its database.
+func (s *MySQLStore) db() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+
+	return s.DB
+}
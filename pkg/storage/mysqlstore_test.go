@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMySQLStoreCreate(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "inserted"},
+		{name: "duplicate entry", err: &mysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry 'new@domain.zone' for key 'email'"}, want: svcerrors.ErrEmailExists},
+		{name: "duplicate username", err: &mysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry 'newton' for key 'username'"}, want: svcerrors.ErrUsernameExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.Nil(t, err)
+			defer db.Close()
+
+			e := mock.ExpectExec(`INSERT INTO users`).WithArgs("42", "new@domain.zone", sql.NullString{}, "hashed:qwerty", entities.RoleUser, "Isaac", "Newton", "", testNow, testNow)
+			if tt.err != nil {
+				e.WillReturnError(tt.err)
+			} else {
+				e.WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			s := NewMySQLStore(db)
+			s.Hasher = fakeHasher{}
+			s.Clock = &fakeClock{testNow}
+			s.IDs = &SequentialGenerator{Last: 41}
+
+			u, err := s.Create(context.Background(), &entities.Form{Email: "new@domain.zone", Password: "qwerty", FirstName: "Isaac", LastName: "Newton"})
+			assert.Equal(t, tt.want, err)
+			if tt.want == nil {
+				assert.Equal(t, "42", u.ID)
+			}
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/newtondev/service_object/pkg/clock"
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/hasher"
+)
+
+// pgUniqueViolation is the postgres error code for unique constraint violations.
+const pgUniqueViolation = "23505"
+
+// pgUsernameKey is the unique index of usernames of not deleted users.
+const pgUsernameKey = "users_username_active_key"
+
+// PgSchema creates users table, emails and usernames are unique among not
+// deleted users, password history table and canary table written by write
+// checks.
+const PgSchema = `CREATE TABLE IF NOT EXISTS users (
+	id           TEXT PRIMARY KEY,
+	email        TEXT NOT NULL,
+	username     TEXT,
+	password     TEXT NOT NULL,
+	verified     BOOLEAN NOT NULL DEFAULT FALSE,
+	role         TEXT NOT NULL DEFAULT 'user',
+	first_name   TEXT NOT NULL DEFAULT '',
+	last_name    TEXT NOT NULL DEFAULT '',
+	display_name TEXT NOT NULL DEFAULT '',
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	deleted_at   TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_active_key ON users (email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_active_key ON users (username) WHERE deleted_at IS NULL;
+CREATE TABLE IF NOT EXISTS password_history (
+	user_id    TEXT NOT NULL,
+	hash       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS password_history_user_key ON password_history (user_id, created_at);
+CREATE TABLE IF NOT EXISTS canary (
+	id         INTEGER PRIMARY KEY,
+	checked_at TIMESTAMPTZ NOT NULL
+)`
+
+// PgStore is a postgres storage for users.
+type PgStore struct {
+	DB     *sql.DB
+	Hasher hasher.PasswordHasher
+	Clock  clock.Clock
+	// IDs generates ids of created users, UUIDs are used when nil.
+	IDs IDGenerator
+	// IncludeDeleted makes lookups return deleted users too.
+	IncludeDeleted bool
+
+	tx *sql.Tx
+}
+
+// NewPgStore prepares postgres storage.
+func NewPgStore(db *sql.DB) *PgStore {
+	return &PgStore{DB: db}
+}
+
+// Migrate creates users, password history and canary tables if they are missing.
+func (s *PgStore) Migrate(ctx context.Context) error {
+	_, err := s.db().ExecContext(ctx, PgSchema)
+	return err
+}
+
+// Ping checks the database is reachable.
+func (s *PgStore) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+// CheckWrite checks the database accepts writes by updating the canary row.
+func (s *PgStore) CheckWrite(ctx context.Context) error {
+	_, err := s.db().ExecContext(ctx, `INSERT INTO canary (id, checked_at) VALUES (1, $1) ON CONFLICT (id) DO UPDATE SET checked_at = EXCLUDED.checked_at`, now(s.Clock))
+	return err
+}
+
+// Unique checks if a email exists in the database.
+func (s *PgStore) Unique(ctx context.Context, email string) error {
+	var exists bool
+	err := s.db().QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 AND `+notDeleted(s.IncludeDeleted)+`)`, email).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.ErrEmailExists
+	}
+
+	return nil
+}
+
+// UniqueUsername checks if a username exists in the database.
+func (s *PgStore) UniqueUsername(ctx context.Context, username string) error {
+	var exists bool
+	err := s.db().QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE username = $1 AND `+notDeleted(s.IncludeDeleted)+`)`, username).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.ErrUsernameExists
+	}
+
+	return nil
+}
+
+// FindByEmail finds user by email normalized the same way registration does.
+func (s *PgStore) FindByEmail(ctx context.Context, email string) (*entities.User, error) {
+	return findUser(ctx, s.db(), `SELECT id, email, COALESCE(username, ''), password, verified, role, first_name, last_name, display_name, created_at, updated_at, deleted_at FROM users WHERE email = $1 AND `+notDeleted(s.IncludeDeleted), entities.NormalizeEmail(email, false))
+}
+
+// FindByID finds user by id.
+func (s *PgStore) FindByID(ctx context.Context, id string) (*entities.User, error) {
+	return findUser(ctx, s.db(), `SELECT id, email, COALESCE(username, ''), password, verified, role, first_name, last_name, display_name, created_at, updated_at, deleted_at FROM users WHERE id = $1 AND `+notDeleted(s.IncludeDeleted), id)
+}
+
+// List returns at most limit users starting at offset ordered by id.
+func (s *PgStore) List(ctx context.Context, offset, limit int) ([]entities.User, error) {
+	return listUsers(ctx, s.db(), `SELECT id, email, COALESCE(username, ''), password, verified, role, first_name, last_name, display_name, created_at, updated_at, deleted_at FROM users WHERE `+notDeleted(s.IncludeDeleted)+` ORDER BY created_at, id LIMIT $1 OFFSET $2`, limit, offset)
+}
+
+// Count returns number of users.
+func (s *PgStore) Count(ctx context.Context) (int, error) {
+	var n int
+	err := s.db().QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE `+notDeleted(s.IncludeDeleted)).Scan(&n)
+	return n, err
+}
+
+// Create creates user in the database for a form.
+func (s *PgStore) Create(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	hash, err := hashPassword(s.Hasher, f.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newID(s.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	t := now(s.Clock)
+	u := entities.User{
+		ID:        id,
+		Password:  hash,
+		Email:     f.Email,
+		Username:  f.Username,
+		Role:      entities.RoleUser,
+		CreatedAt: t,
+		UpdatedAt: t,
+
+		FirstName:   f.FirstName,
+		LastName:    f.LastName,
+		DisplayName: f.DisplayName,
+	}
+
+	_, err = s.db().ExecContext(ctx, `INSERT INTO users (id, email, username, password, role, first_name, last_name, display_name, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`, u.ID, u.Email, nullString(u.Username), u.Password, u.Role, u.FirstName, u.LastName, u.DisplayName, u.CreatedAt, u.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgUniqueViolation {
+			return nil, pgExists(pqErr)
+		}
+
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// Update replaces email, password and names of the user.
+func (s *PgStore) Update(ctx context.Context, id string, f *entities.Form) (*entities.User, error) {
+	hash, err := hashPassword(s.Hasher, f.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	u := entities.User{
+		ID:        id,
+		Password:  hash,
+		Email:     f.Email,
+		UpdatedAt: now(s.Clock),
+
+		FirstName:   f.FirstName,
+		LastName:    f.LastName,
+		DisplayName: f.DisplayName,
+	}
+
+	err = s.db().QueryRowContext(ctx, `UPDATE users SET email = $1, password = $2, first_name = $3, last_name = $4, display_name = $5, updated_at = $6 WHERE id = $7 AND deleted_at IS NULL RETURNING COALESCE(username, ''), verified, role, created_at`, u.Email, u.Password, u.FirstName, u.LastName, u.DisplayName, u.UpdatedAt, id).Scan(&u.Username, &u.Verified, &u.Role, &u.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrUserNotFound
+		}
+
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgUniqueViolation {
+			return nil, pgExists(pqErr)
+		}
+
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// Delete marks user as deleted keeping its row.
+func (s *PgStore) Delete(ctx context.Context, id string) error {
+	return execUser(ctx, s.db(), `UPDATE users SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`, now(s.Clock), id)
+}
+
+// Restore clears deletion mark of the user. It fails with ErrEmailExists or
+// ErrUsernameExists when they were taken since the user was deleted.
+func (s *PgStore) Restore(ctx context.Context, id string) error {
+	err := execUser(ctx, s.db(), `UPDATE users SET deleted_at = NULL, updated_at = $1 WHERE id = $2 AND deleted_at IS NOT NULL`, now(s.Clock), id)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgUniqueViolation {
+		return pgExists(pqErr)
+	}
+
+	return err
+}
+
+// SetVerified marks user as verified.
+func (s *PgStore) SetVerified(ctx context.Context, id string) error {
+	return execUser(ctx, s.db(), `UPDATE users SET verified = TRUE, updated_at = $1 WHERE id = $2`, now(s.Clock), id)
+}
+
+// UpdatePassword replaces password hash of the user.
+func (s *PgStore) UpdatePassword(ctx context.Context, id string, hash string) error {
+	return execUser(ctx, s.db(), `UPDATE users SET password = $1, updated_at = $2 WHERE id = $3`, hash, now(s.Clock), id)
+}
+
+// PasswordHistory returns at most limit previous password hashes of the
+// user, latest first.
+func (s *PgStore) PasswordHistory(ctx context.Context, id string, limit int) ([]string, error) {
+	rows, err := s.db().QueryContext(ctx, `SELECT hash FROM password_history WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// AddPasswordHistory records a previous password hash of the user.
+func (s *PgStore) AddPasswordHistory(ctx context.Context, id string, hash string) error {
+	_, err := s.db().ExecContext(ctx, `INSERT INTO password_history (user_id, hash, created_at) VALUES ($1, $2, $3)`, id, hash, now(s.Clock))
+	return err
+}
+
+// pgExists returns the exists error of the unique index e violates.
+func pgExists(e *pq.Error) error {
+	if e.Constraint == pgUsernameKey {
+		return errors.ErrUsernameExists
+	}
+
+	return errors.ErrEmailExists
+}
+
+// Begin starts a transaction and returns a store running queries within it.
+func (s *PgStore) Begin(ctx context.Context) (*PgStore, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PgStore{DB: s.DB, Hasher: s.Hasher, Clock: s.Clock, IDs: s.IDs, IncludeDeleted: s.IncludeDeleted, tx: tx}, nil
+}
+
+// Commit applies changes of the store transaction.
+func (s *PgStore) Commit() error {
+	if s.tx == nil {
+		return sql.ErrTxDone
+	}
+
+	return s.tx.Commit()
+}
+
+// Rollback discards changes of the store transaction.
+func (s *PgStore) Rollback() error {
+	if s.tx == nil {
+		return sql.ErrTxDone
+	}
+
+	return s.tx.Rollback()
+}
+
+// db returns the transaction of the store or its database.
+func (s *PgStore) db() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+
+	return s.DB
+}
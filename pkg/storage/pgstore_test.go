@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/newtondev/service_object/pkg/entities"
+	svcerrors "github.com/newtondev/service_object/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPgStoreUnique(t *testing.T) {
+	tests := []struct {
+		name   string
+		exists bool
+		err    error
+	}{
+		{name: "missing email", exists: false, err: nil},
+		{name: "existing email", exists: true, err: svcerrors.ErrEmailExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.Nil(t, err)
+			defer db.Close()
+
+			mock.ExpectQuery(`SELECT EXISTS`).
+				WithArgs("new@domain.zone").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.exists))
+
+			err = NewPgStore(db).Unique(context.Background(), "new@domain.zone")
+			assert.Equal(t, tt.err, err)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPgStoreNotFound(t *testing.T) {
+	columns := []string{"id", "email", "username", "password", "verified", "role", "first_name", "last_name", "display_name", "created_at", "updated_at", "deleted_at"}
+	tests := []struct {
+		name string
+		call func(s *PgStore) error
+		mock func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name: "find by email",
+			call: func(s *PgStore) error {
+				_, err := s.FindByEmail(context.Background(), "missing@domain.zone")
+				return err
+			},
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT (.+) FROM users WHERE email`).WithArgs("missing@domain.zone").WillReturnRows(sqlmock.NewRows(columns))
+			},
+		},
+		{
+			name: "find by id",
+			call: func(s *PgStore) error {
+				_, err := s.FindByID(context.Background(), "42")
+				return err
+			},
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT (.+) FROM users WHERE id`).WithArgs("42").WillReturnRows(sqlmock.NewRows(columns))
+			},
+		},
+		{
+			name: "delete",
+			call: func(s *PgStore) error {
+				return s.Delete(context.Background(), "42")
+			},
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE users SET deleted_at`).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.Nil(t, err)
+			defer db.Close()
+
+			tt.mock(mock)
+
+			err = tt.call(NewPgStore(db))
+			assert.True(t, errors.Is(err, svcerrors.ErrUserNotFound))
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPgStoreCreate(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "inserted"},
+		{name: "unique violation", err: &pq.Error{Code: pgUniqueViolation}, want: svcerrors.ErrEmailExists},
+		{name: "username violation", err: &pq.Error{Code: pgUniqueViolation, Constraint: pgUsernameKey}, want: svcerrors.ErrUsernameExists},
+		{name: "other error", err: errors.New("connection reset"), want: errors.New("connection reset")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.Nil(t, err)
+			defer db.Close()
+
+			e := mock.ExpectExec(`INSERT INTO users`).WithArgs("42", "new@domain.zone", sql.NullString{}, "hashed:qwerty", entities.RoleUser, "Isaac", "Newton", "", testNow, testNow)
+			if tt.err != nil {
+				e.WillReturnError(tt.err)
+			} else {
+				e.WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			s := NewPgStore(db)
+			s.Hasher = fakeHasher{}
+			s.Clock = &fakeClock{testNow}
+			s.IDs = &SequentialGenerator{Last: 41}
+
+			u, err := s.Create(context.Background(), &entities.Form{Email: "new@domain.zone", Password: "qwerty", FirstName: "Isaac", LastName: "Newton"})
+			assert.Equal(t, tt.want, err)
+			if tt.want == nil {
+				assert.Equal(t, "42", u.ID)
+				assert.Equal(t, "hashed:qwerty", u.Password)
+				assert.Equal(t, testNow, u.CreatedAt)
+				assert.Equal(t, testNow, u.UpdatedAt)
+			}
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// testNow is the time told by fakeClock in store tests.
+var testNow = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// fakeClock is a Clock standing still until advanced.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// fakeHasher is a cheap PasswordHasher for tests.
+type fakeHasher struct{}
+
+func (fakeHasher) Hash(password string) (string, error) {
+	return "hashed:" + password, nil
+}
+
+func (fakeHasher) Compare(hash, password string) error {
+	if hash != "hashed:"+password {
+		return errors.New("password mismatch")
+	}
+
+	return nil
+}
+
+func TestPgStorePasswordHistory(t *testing.T) {
+	t.Log("with mocked database.")
+	{
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+
+		s := NewPgStore(db)
+		s.Clock = &fakeClock{testNow}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould record the hash with the current time.")
+		{
+			mock.ExpectExec(`INSERT INTO password_history`).WithArgs("42", "hash:1", testNow).WillReturnResult(sqlmock.NewResult(0, 1))
+			assert.Nil(t, s.AddPasswordHistory(ctx, "42", "hash:1"))
+		}
+
+		t.Log("\ttest:1\tshould return the latest hashes up to the limit.")
+		{
+			mock.ExpectQuery(`SELECT hash FROM password_history WHERE user_id = \$1 ORDER BY created_at DESC LIMIT \$2`).WithArgs("42", 2).
+				WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("hash:2").AddRow("hash:1"))
+
+			hashes, err := s.PasswordHistory(ctx, "42", 2)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"hash:2", "hash:1"}, hashes)
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/newtondev/service_object/pkg/clock"
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/hasher"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteSchema creates users table, emails and usernames are unique among not
+// deleted users, and canary table written by write checks.
+const SQLiteSchema = `CREATE TABLE IF NOT EXISTS users (
+	id           TEXT PRIMARY KEY,
+	email        TEXT NOT NULL,
+	username     TEXT,
+	password     TEXT NOT NULL,
+	verified     BOOLEAN NOT NULL DEFAULT FALSE,
+	role         TEXT NOT NULL DEFAULT 'user',
+	first_name   TEXT NOT NULL DEFAULT '',
+	last_name    TEXT NOT NULL DEFAULT '',
+	display_name TEXT NOT NULL DEFAULT '',
+	created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	deleted_at   TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_active_key ON users (email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_active_key ON users (username) WHERE deleted_at IS NULL;
+CREATE TABLE IF NOT EXISTS canary (
+	id         INTEGER PRIMARY KEY,
+	checked_at TIMESTAMP NOT NULL
+)`
+
+// SQLiteStore is a sqlite storage for users.
+type SQLiteStore struct {
+	DB     *sql.DB
+	Hasher hasher.PasswordHasher
+	Clock  clock.Clock
+	// IDs generates ids of created users, UUIDs are used when nil.
+	IDs IDGenerator
+	// IncludeDeleted makes lookups return deleted users too.
+	IncludeDeleted bool
+
+	tx *sql.Tx
+}
+
+// NewSQLiteStore opens sqlite database at path and creates users table.
+// Use ":memory:" for a database living only as long as the store.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// every connection to ":memory:" opens a separate database.
+	if path == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+
+	if _, err := db.Exec(SQLiteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{DB: db}, nil
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.DB.Close()
+}
+
+// Ping checks the database is reachable.
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+// CheckWrite checks the database accepts writes by updating the canary row.
+func (s *SQLiteStore) CheckWrite(ctx context.Context) error {
+	_, err := s.db().ExecContext(ctx, `INSERT INTO canary (id, checked_at) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET checked_at = excluded.checked_at`, now(s.Clock))
+	return err
+}
+
+// Unique checks if a email exists in the database.
+func (s *SQLiteStore) Unique(ctx context.Context, email string) error {
+	var exists bool
+	err := s.db().QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND `+notDeleted(s.IncludeDeleted)+`)`, email).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.ErrEmailExists
+	}
+
+	return nil
+}
+
+// UniqueUsername checks if a username exists in the database.
+func (s *SQLiteStore) UniqueUsername(ctx context.Context, username string) error {
+	var exists bool
+	err := s.db().QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE username = ? AND `+notDeleted(s.IncludeDeleted)+`)`, username).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.ErrUsernameExists
+	}
+
+	return nil
+}
+
+// FindByEmail finds user by email normalized the same way registration does.
+func (s *SQLiteStore) FindByEmail(ctx context.Context, email string) (*entities.User, error) {
+	return findUser(ctx, s.db(), `SELECT id, email, COALESCE(username, ''), password, verified, role, first_name, last_name, display_name, created_at, updated_at, deleted_at FROM users WHERE email = ? AND `+notDeleted(s.IncludeDeleted), entities.NormalizeEmail(email, false))
+}
+
+// FindByID finds user by id.
+func (s *SQLiteStore) FindByID(ctx context.Context, id string) (*entities.User, error) {
+	return findUser(ctx, s.db(), `SELECT id, email, COALESCE(username, ''), password, verified, role, first_name, last_name, display_name, created_at, updated_at, deleted_at FROM users WHERE id = ? AND `+notDeleted(s.IncludeDeleted), id)
+}
+
+// List returns at most limit users starting at offset ordered by id.
+func (s *SQLiteStore) List(ctx context.Context, offset, limit int) ([]entities.User, error) {
+	return listUsers(ctx, s.db(), `SELECT id, email, COALESCE(username, ''), password, verified, role, first_name, last_name, display_name, created_at, updated_at, deleted_at FROM users WHERE `+notDeleted(s.IncludeDeleted)+` ORDER BY created_at, id LIMIT ? OFFSET ?`, limit, offset)
+}
+
+// Count returns number of users.
+func (s *SQLiteStore) Count(ctx context.Context) (int, error) {
+	var n int
+	err := s.db().QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE `+notDeleted(s.IncludeDeleted)).Scan(&n)
+	return n, err
+}
+
+// Create creates user in the database for a form.
+func (s *SQLiteStore) Create(ctx context.Context, f *entities.Form) (*entities.User, error) {
+	hash, err := hashPassword(s.Hasher, f.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newID(s.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	t := now(s.Clock)
+	u := entities.User{
+		ID:        id,
+		Password:  hash,
+		Email:     f.Email,
+		Username:  f.Username,
+		Role:      entities.RoleUser,
+		CreatedAt: t,
+		UpdatedAt: t,
+
+		FirstName:   f.FirstName,
+		LastName:    f.LastName,
+		DisplayName: f.DisplayName,
+	}
+
+	_, err = s.db().ExecContext(ctx, `INSERT INTO users (id, email, username, password, role, first_name, last_name, display_name, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, u.ID, u.Email, nullString(u.Username), u.Password, u.Role, u.FirstName, u.LastName, u.DisplayName, u.CreatedAt, u.UpdatedAt)
+	if err != nil {
+		if sqliteErr, ok := err.(*sqlite.Error); ok && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return nil, sqliteExists(sqliteErr)
+		}
+
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// Update replaces email, password and names of the user.
+func (s *SQLiteStore) Update(ctx context.Context, id string, f *entities.Form) (*entities.User, error) {
+	hash, err := hashPassword(s.Hasher, f.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	u := entities.User{
+		ID:        id,
+		Password:  hash,
+		Email:     f.Email,
+		UpdatedAt: now(s.Clock),
+
+		FirstName:   f.FirstName,
+		LastName:    f.LastName,
+		DisplayName: f.DisplayName,
+	}
+
+	err = s.db().QueryRowContext(ctx, `UPDATE users SET email = ?, password = ?, first_name = ?, last_name = ?, display_name = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL RETURNING COALESCE(username, ''), verified, role, created_at`, u.Email, u.Password, u.FirstName, u.LastName, u.DisplayName, u.UpdatedAt, id).Scan(&u.Username, &u.Verified, &u.Role, &u.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrUserNotFound
+		}
+
+		if sqliteErr, ok := err.(*sqlite.Error); ok && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return nil, sqliteExists(sqliteErr)
+		}
+
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// Delete marks user as deleted keeping its row.
+func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
+	return execUser(ctx, s.db(), `UPDATE users SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, now(s.Clock), id)
+}
+
+// Restore clears deletion mark of the user. It fails with ErrEmailExists or
+// ErrUsernameExists when they were taken since the user was deleted.
+func (s *SQLiteStore) Restore(ctx context.Context, id string) error {
+	err := execUser(ctx, s.db(), `UPDATE users SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL`, now(s.Clock), id)
+	if sqliteErr, ok := err.(*sqlite.Error); ok && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return sqliteExists(sqliteErr)
+	}
+
+	return err
+}
+
+// SetVerified marks user as verified.
+func (s *SQLiteStore) SetVerified(ctx context.Context, id string) error {
+	return execUser(ctx, s.db(), `UPDATE users SET verified = TRUE, updated_at = ? WHERE id = ?`, now(s.Clock), id)
+}
+
+// UpdatePassword replaces password hash of the user.
+func (s *SQLiteStore) UpdatePassword(ctx context.Context, id string, hash string) error {
+	return execUser(ctx, s.db(), `UPDATE users SET password = ?, updated_at = ? WHERE id = ?`, hash, now(s.Clock), id)
+}
+
+// sqliteExists returns the exists error of the unique index e violates,
+// sqlite names only the violated columns.
+func sqliteExists(e *sqlite.Error) error {
+	if strings.Contains(e.Error(), "users.username") {
+		return errors.ErrUsernameExists
+	}
+
+	return errors.ErrEmailExists
+}
+
+// Begin starts a transaction and returns a store running queries within it.
+func (s *SQLiteStore) Begin(ctx context.Context) (*SQLiteStore, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteStore{DB: s.DB, Hasher: s.Hasher, Clock: s.Clock, IDs: s.IDs, IncludeDeleted: s.IncludeDeleted, tx: tx}, nil
+}
+
+// Commit applies changes of the store transaction.
+func (s *SQLiteStore) Commit() error {
+	if s.tx == nil {
+		return sql.ErrTxDone
+	}
+
+	return s.tx.Commit()
+}
+
+// Rollback discards changes of the store transaction.
+func (s *SQLiteStore) Rollback() error {
+	if s.tx == nil {
+		return sql.ErrTxDone
+	}
+
+	return s.tx.Rollback()
+}
+
+// db returns the transaction of the store or its database.
+func (s *SQLiteStore) db() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+
+	return s.DB
+}
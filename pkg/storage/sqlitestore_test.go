@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	t.Log("with in-memory sqlite store.")
+	{
+		s, err := NewSQLiteStore(":memory:")
+		assert.Nil(t, err)
+		defer s.Close()
+		s.Hasher = fakeHasher{}
+		clock := &fakeClock{testNow}
+		s.Clock = clock
+		s.IDs = &SequentialGenerator{}
+		ctx := context.Background()
+
+		t.Log("\ttest:0\tshould ping open database.")
+		{
+			assert.Nil(t, s.Ping(ctx))
+		}
+
+		t.Log("\ttest:1\tshould create users with generated ids.")
+		{
+			assert.Nil(t, s.Unique(ctx, "new@domain.zone"))
+
+			u, err := s.Create(ctx, &entities.Form{Email: "new@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+			assert.Equal(t, "1", u.ID)
+			assert.Equal(t, "hashed:qwerty", u.Password)
+			assert.Equal(t, entities.RoleUser, u.Role)
+
+			u, err = s.Create(ctx, &entities.Form{Email: "other@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+			assert.Equal(t, "2", u.ID)
+		}
+
+		t.Log("\ttest:2\tshould report existing email.")
+		{
+			assert.Equal(t, errors.ErrEmailExists, s.Unique(ctx, "new@domain.zone"))
+
+			_, err := s.Create(ctx, &entities.Form{Email: "new@domain.zone", Password: "qwerty"})
+			assert.Equal(t, errors.ErrEmailExists, err)
+		}
+
+		t.Log("\ttest:3\tshould list and count users.")
+		{
+			users, err := s.List(ctx, 1, 5)
+			assert.Nil(t, err)
+			assert.Len(t, users, 1)
+			assert.Equal(t, "other@domain.zone", users[0].Email)
+
+			n, err := s.Count(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, n)
+		}
+
+		t.Log("\ttest:4\tshould keep created_at and move updated_at on update.")
+		{
+			clock.Advance(time.Minute)
+
+			u, err := s.Update(ctx, "2", &entities.Form{Email: "other@domain.zone", Password: "other"})
+			assert.Nil(t, err)
+			assert.True(t, testNow.Equal(u.CreatedAt))
+			assert.True(t, testNow.Add(time.Minute).Equal(u.UpdatedAt))
+
+			found, err := s.FindByID(ctx, "2")
+			assert.Nil(t, err)
+			assert.True(t, found.CreatedAt.Equal(u.CreatedAt))
+			assert.True(t, found.UpdatedAt.After(found.CreatedAt))
+		}
+
+		t.Log("\ttest:5\tshould find, verify and delete users.")
+		{
+			assert.Nil(t, s.SetVerified(ctx, "1"))
+
+			u, err := s.FindByEmail(ctx, "new@domain.zone")
+			assert.Nil(t, err)
+			assert.Equal(t, "1", u.ID)
+			assert.True(t, u.Verified)
+
+			assert.Nil(t, s.Delete(ctx, "1"))
+			_, err = s.FindByID(ctx, "1")
+			assert.Equal(t, errors.ErrUserNotFound, err)
+		}
+
+		t.Log("\ttest:6\tshould free email of deleted user and keep it restorable.")
+		{
+			assert.Nil(t, s.Unique(ctx, "new@domain.zone"))
+
+			n, err := s.Count(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, n)
+
+			u, err := s.Create(ctx, &entities.Form{Email: "new@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrEmailExists, s.Restore(ctx, "1"))
+
+			assert.Nil(t, s.Delete(ctx, u.ID))
+			assert.Nil(t, s.Restore(ctx, "1"))
+			assert.Equal(t, errors.ErrUserNotFound, s.Restore(ctx, "1"))
+
+			found, err := s.FindByID(ctx, "1")
+			assert.Nil(t, err)
+			assert.Nil(t, found.DeletedAt)
+		}
+
+		t.Log("\ttest:7\tshould write the canary row repeatedly.")
+		{
+			assert.Nil(t, s.CheckWrite(ctx))
+			clock.Advance(time.Minute)
+			assert.Nil(t, s.CheckWrite(ctx))
+
+			var n int
+			assert.Nil(t, s.DB.QueryRow(`SELECT COUNT(*) FROM canary`).Scan(&n))
+			assert.Equal(t, 1, n)
+		}
+
+		t.Log("\ttest:8\tshould keep usernames unique and optional.")
+		{
+			u, err := s.Create(ctx, &entities.Form{Email: "newton@domain.zone", Username: "newton", Password: "qwerty"})
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrUsernameExists, s.UniqueUsername(ctx, "newton"))
+			assert.Nil(t, s.UniqueUsername(ctx, "other"))
+
+			found, err := s.FindByID(ctx, u.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, "newton", found.Username)
+
+			_, err = s.Create(ctx, &entities.Form{Email: "apple@domain.zone", Username: "newton", Password: "qwerty"})
+			assert.Equal(t, errors.ErrUsernameExists, err)
+
+			_, err = s.Create(ctx, &entities.Form{Email: "first@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+			_, err = s.Create(ctx, &entities.Form{Email: "second@domain.zone", Password: "qwerty"})
+			assert.Nil(t, err)
+		}
+
+		t.Log("\ttest:9\tshould store names on create and update.")
+		{
+			u, err := s.Create(ctx, &entities.Form{Email: "isaac@domain.zone", Password: "qwerty", FirstName: "Isaac", LastName: "Newton", DisplayName: "Sir Isaac"})
+			assert.Nil(t, err)
+
+			found, err := s.FindByID(ctx, u.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, "Isaac", found.FirstName)
+			assert.Equal(t, "Newton", found.LastName)
+			assert.Equal(t, "Sir Isaac", found.DisplayName)
+
+			_, err = s.Update(ctx, u.ID, &entities.Form{Email: "isaac@domain.zone", Password: "qwerty", FirstName: "Isaac", LastName: "Newton"})
+			assert.Nil(t, err)
+
+			found, err = s.FindByEmail(ctx, "isaac@domain.zone")
+			assert.Nil(t, err)
+			assert.Equal(t, "Isaac", found.FirstName)
+			assert.Equal(t, "", found.DisplayName)
+
+			users, err := s.List(ctx, 0, 10)
+			assert.Nil(t, err)
+			last := users[len(users)-1]
+			assert.Equal(t, u.ID, last.ID)
+			assert.Equal(t, "Newton", last.LastName)
+		}
+
+		t.Log("\ttest:10\tshould fail ping and write check of closed database.")
+		{
+			assert.Nil(t, s.Close())
+			assert.NotNil(t, s.Ping(ctx))
+			assert.NotNil(t, s.CheckWrite(ctx))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/newtondev/service_object/pkg/errors"
+	"github.com/newtondev/service_object/pkg/hasher"
+)
+
+// querier runs queries on a database or within a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// hashPassword hashes password with h or bcrypt with the default cost.
+func hashPassword(h hasher.PasswordHasher, password string) (string, error) {
+	if h == nil {
+		h = hasher.NewBcrypt(0)
+	}
+
+	return h.Hash(password)
+}
+
+// notDeleted is a query condition hiding deleted users unless include is set.
+func notDeleted(include bool) string {
+	if include {
+		return "TRUE"
+	}
+
+	return "deleted_at IS NULL"
+}
+
+// nullString stores empty s as NULL, so unique indexes skip it.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// findUser scans a single user row returned by query.
+func findUser(ctx context.Context, db querier, query string, args ...interface{}) (*entities.User, error) {
+	var u entities.User
+	err := db.QueryRowContext(ctx, query, args...).Scan(&u.ID, &u.Email, &u.Username, &u.Password, &u.Verified, &u.Role, &u.FirstName, &u.LastName, &u.DisplayName, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// listUsers scans user rows returned by query.
+func listUsers(ctx context.Context, db querier, query string, args ...interface{}) ([]entities.User, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []entities.User{}
+	for rows.Next() {
+		var u entities.User
+		if err := rows.Scan(&u.ID, &u.Email, &u.Username, &u.Password, &u.Verified, &u.Role, &u.FirstName, &u.LastName, &u.DisplayName, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// execUser runs query affecting a single user and reports missing user.
+func execUser(ctx context.Context, db querier, query string, args ...interface{}) error {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return errors.ErrUserNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+// Package strength estimates how hard passwords are to guess.
+package strength
+
+import "math"
+
+// Shannon estimates password entropy in bits from the frequency of its
+// characters, repeated characters add less than distinct ones. It does not
+// know dictionary words or keyboard patterns.
+type Shannon struct{}
+
+// Entropy returns the estimated entropy of password in bits.
+func (Shannon) Entropy(password string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range password {
+		counts[r]++
+		n++
+	}
+
+	var perChar float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		perChar -= p * math.Log2(p)
+	}
+
+	return perChar * float64(n)
+}
@@ -0,0 +1,35 @@
+package strength
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShannon(t *testing.T) {
+	t.Log("with shannon estimator.")
+	{
+		var s Shannon
+
+		t.Log("\ttest:0\tshould estimate nothing for empty and repeated passwords.")
+		{
+			assert.Zero(t, s.Entropy(""))
+			assert.Zero(t, s.Entropy("aaaaaaaa"))
+		}
+
+		t.Log("\ttest:1\tshould give distinct characters full weight.")
+		{
+			assert.InDelta(t, 24, s.Entropy("abcdefgh"), 1e-9)
+		}
+
+		t.Log("\ttest:2\tshould count characters not bytes.")
+		{
+			assert.InDelta(t, 2, s.Entropy("\u00e9\u00e8"), 1e-9)
+		}
+
+		t.Log("\ttest:3\tshould weigh repetitions less.")
+		{
+			assert.Less(t, s.Entropy("password1"), s.Entropy("pasword1X"))
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package token
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/newtondev/service_object/pkg/clock"
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/pkg/errors"
+)
+
+// DefaultTTL is a lifetime of issued tokens when none is configured.
+const DefaultTTL = 24 * time.Hour
+
+// Claims holds user data carried by a token.
+type Claims struct {
+	Email string `json:"email"`
+	Role  string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns user id stored in the subject.
+func (c *Claims) UserID() string {
+	return c.Subject
+}
+
+// JWT issues and parses HMAC signed tokens.
+type JWT struct {
+	Secret []byte
+	TTL    time.Duration
+	// Clock is used for issue and expiry times, real clock when nil.
+	Clock clock.Clock
+}
+
+// NewJWT prepares HMAC tokenizer.
+func NewJWT(secret []byte, ttl time.Duration) *JWT {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &JWT{Secret: secret, TTL: ttl, Clock: clock.Real{}}
+}
+
+// Generate signs a token for the user.
+func (j *JWT) Generate(u *entities.User) (string, error) {
+	now := clock.Now(j.Clock)
+	claims := Claims{
+		Email: u.Email,
+		Role:  u.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   u.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.TTL)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(j.Secret)
+}
+
+// Parse verifies the token signature and expiry and returns its claims.
+func (j *JWT) Parse(s string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(s, &claims, func(*jwt.Token) (interface{}, error) {
+		return j.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithTimeFunc(func() time.Time {
+		return clock.Now(j.Clock)
+	}))
+	if err != nil {
+		return nil, errors.Wrap(err, "jwt parse")
+	}
+
+	return &claims, nil
+}
@@ -0,0 +1,68 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWT(t *testing.T) {
+	t.Log("with configured tokenizer.")
+	{
+		clock := &fakeClock{now: time.Now()}
+		j := NewJWT([]byte("secret"), time.Hour)
+		j.Clock = clock
+		u := entities.User{ID: "7", Email: "new@domain.zone", Role: entities.RoleAdmin}
+
+		t.Log("\ttest:0\tshould parse generated token back to the same claims.")
+		{
+			s, err := j.Generate(&u)
+			assert.Nil(t, err)
+
+			c, err := j.Parse(s)
+			assert.Nil(t, err)
+			assert.Equal(t, "new@domain.zone", c.Email)
+			assert.Equal(t, entities.RoleAdmin, c.Role)
+
+			assert.Equal(t, "7", c.UserID())
+		}
+
+		t.Log("\ttest:1\tshould reject token signed with other secret.")
+		{
+			s, err := NewJWT([]byte("other"), time.Hour).Generate(&u)
+			assert.Nil(t, err)
+
+			_, err = j.Parse(s)
+			assert.NotNil(t, err)
+		}
+
+		t.Log("\ttest:2\tshould reject expired token.")
+		{
+			s, err := j.Generate(&u)
+			assert.Nil(t, err)
+
+			clock.Advance(time.Hour - time.Second)
+			_, err = j.Parse(s)
+			assert.Nil(t, err)
+
+			clock.Advance(2 * time.Second)
+			_, err = j.Parse(s)
+			assert.NotNil(t, err)
+		}
+	}
+}
+
+// fakeClock is a Clock moved forward by tests.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,129 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/clock"
+	"github.com/newtondev/service_object/pkg/errors"
+)
+
+// Generator produces random token values.
+type Generator interface {
+	Generate() (string, error)
+}
+
+// RandomGenerator generates hex encoded tokens from crypto/rand.
+type RandomGenerator struct{}
+
+// Generate implements Generator.
+func (RandomGenerator) Generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// oneTimeEntry is an issued token.
+type oneTimeEntry struct {
+	userID  string
+	expires time.Time
+}
+
+// OneTime keeps single-use expiring tokens in memory. Expired tokens are
+// forgotten, so tokens never used do not pile up, they are reported invalid
+// afterwards.
+type OneTime struct {
+	Generator Generator
+	TTL       time.Duration
+	// Clock is used for expiry times, real clock when nil.
+	Clock clock.Clock
+
+	mu        sync.Mutex
+	tokens    map[string]oneTimeEntry
+	nextSweep time.Time
+}
+
+// NewOneTime prepares one-time token store.
+func NewOneTime(g Generator, ttl time.Duration) *OneTime {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &OneTime{
+		Generator: g,
+		TTL:       ttl,
+		Clock:     clock.Real{},
+		tokens:    make(map[string]oneTimeEntry),
+	}
+}
+
+// Issue generates a token for the user.
+func (o *OneTime) Issue(userID string) (string, error) {
+	t, err := o.Generator.Generate()
+	if err != nil {
+		return "", err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := clock.Now(o.Clock)
+	o.sweep(now)
+	o.tokens[t] = oneTimeEntry{userID: userID, expires: now.Add(o.TTL)}
+
+	return t, nil
+}
+
+// Peek returns user id of the token keeping it valid.
+func (o *OneTime) Peek(t string) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	e, ok := o.tokens[t]
+	if !ok {
+		return "", errors.ErrInvalidToken
+	}
+
+	if clock.Now(o.Clock).After(e.expires) {
+		return "", errors.ErrTokenExpired
+	}
+
+	return e.userID, nil
+}
+
+// Consume returns user id of the token and invalidates it.
+func (o *OneTime) Consume(t string) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	e, ok := o.tokens[t]
+	if !ok {
+		return "", errors.ErrInvalidToken
+	}
+	delete(o.tokens, t)
+
+	if clock.Now(o.Clock).After(e.expires) {
+		return "", errors.ErrTokenExpired
+	}
+
+	return e.userID, nil
+}
+
+// sweep forgets expired tokens at most once per TTL.
+func (o *OneTime) sweep(now time.Time) {
+	if now.Before(o.nextSweep) {
+		return
+	}
+
+	for t, e := range o.tokens {
+		if now.After(e.expires) {
+			delete(o.tokens, t)
+		}
+	}
+	o.nextSweep = now.Add(o.TTL)
+}
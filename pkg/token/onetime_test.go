@@ -0,0 +1,95 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOneTime(t *testing.T) {
+	t.Log("with one-time token store.")
+	{
+		clock := &fakeClock{now: time.Now()}
+		o := NewOneTime(RandomGenerator{}, time.Hour)
+		o.Clock = clock
+
+		t.Log("\ttest:0\tshould consume issued token once.")
+		{
+			tok, err := o.Issue("7")
+			assert.Nil(t, err)
+
+			id, err := o.Consume(tok)
+			assert.Nil(t, err)
+			assert.Equal(t, "7", id)
+
+			_, err = o.Consume(tok)
+			assert.Equal(t, errors.ErrInvalidToken, err)
+		}
+
+		t.Log("\ttest:1\tshould reject unknown token.")
+		{
+			_, err := o.Consume("unknown")
+			assert.Equal(t, errors.ErrInvalidToken, err)
+
+			_, err = o.Peek("unknown")
+			assert.Equal(t, errors.ErrInvalidToken, err)
+		}
+
+		t.Log("\ttest:2\tshould peek token without consuming it.")
+		{
+			tok, err := o.Issue("7")
+			assert.Nil(t, err)
+
+			id, err := o.Peek(tok)
+			assert.Nil(t, err)
+			assert.Equal(t, "7", id)
+
+			id, err = o.Consume(tok)
+			assert.Nil(t, err)
+			assert.Equal(t, "7", id)
+		}
+
+		t.Log("\ttest:3\tshould reject expired token.")
+		{
+			tok, err := o.Issue("7")
+			assert.Nil(t, err)
+			clock.Advance(time.Hour + time.Second)
+
+			_, err = o.Consume(tok)
+			assert.Equal(t, errors.ErrTokenExpired, err)
+		}
+	}
+
+	t.Log("with one-time token store issuing tokens never used.")
+	{
+		clock := &fakeClock{now: time.Now()}
+		o := NewOneTime(RandomGenerator{}, time.Hour)
+		o.Clock = clock
+
+		stale, err := o.Issue("7")
+		assert.Nil(t, err)
+		_, err = o.Issue("8")
+		assert.Nil(t, err)
+
+		t.Log("\ttest:0\tshould keep tokens until a ttl passes.")
+		{
+			clock.Advance(30 * time.Minute)
+			_, err := o.Issue("9")
+			assert.Nil(t, err)
+			assert.Len(t, o.tokens, 3)
+		}
+
+		t.Log("\ttest:1\tshould forget expired tokens on issue once per ttl.")
+		{
+			clock.Advance(31 * time.Minute)
+			_, err := o.Issue("10")
+			assert.Nil(t, err)
+			assert.Len(t, o.tokens, 2)
+
+			_, err = o.Consume(stale)
+			assert.Equal(t, errors.ErrInvalidToken, err)
+		}
+	}
+}
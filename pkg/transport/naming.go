@@ -0,0 +1,139 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Naming is a casing of JSON object keys in responses. Wire types are tagged
+// in snake_case, other namings rename the keys of encoded responses.
+type Naming string
+
+// Supported namings.
+const (
+	SnakeCase Naming = "snake_case"
+	CamelCase Naming = "camelCase"
+)
+
+// ParseNaming parses naming name, empty name is SnakeCase.
+func ParseNaming(name string) (Naming, error) {
+	switch n := Naming(name); n {
+	case "":
+		return SnakeCase, nil
+	case SnakeCase, CamelCase:
+		return n, nil
+	}
+
+	return "", fmt.Errorf("unsupported naming %q", name)
+}
+
+// Rename returns snake_case key in the naming.
+func (n Naming) Rename(key string) string {
+	if n != CamelCase {
+		return key
+	}
+
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+
+	return strings.Join(parts, "")
+}
+
+// Transform re-encodes JSON data with object keys renamed, keeping their
+// order. Keys within objects under keep keys are not renamed, as they hold
+// data like names of request fields rather than names of response fields.
+func (n Naming) Transform(data []byte, keep ...string) ([]byte, error) {
+	kept := make(map[string]bool, len(keep))
+	for _, k := range keep {
+		kept[k] = true
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := n.transform(dec, &buf, kept, true); err != nil {
+		return nil, err
+	}
+
+	// encoders end documents with a newline, so transformed ones do too.
+	if bytes.HasSuffix(data, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// transform copies the next value of dec to buf, renaming object keys when
+// rename is set.
+func (n Naming) transform(dec *json.Decoder, buf *bytes.Buffer, kept map[string]bool, rename bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		b, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(b)
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		buf.WriteByte('{')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+
+			name := key
+			if rename {
+				name = n.Rename(key)
+			}
+			b, err := json.Marshal(name)
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+			buf.WriteByte(':')
+
+			if err := n.transform(dec, buf, kept, rename && !kept[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case '[':
+		buf.WriteByte('[')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := n.transform(dec, buf, kept, rename); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	}
+
+	// consume the closing delimiter.
+	_, err = dec.Token()
+	return err
+}
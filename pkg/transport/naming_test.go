@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNaming(t *testing.T) {
+	t.Log("with encoded user.")
+	{
+		u := entities.User{ID: "1", Email: "a@domain.zone", FirstName: "Ada", DisplayName: "ada", CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+		b, err := json.Marshal(NewUserResponse(u.Safe()))
+		assert.Nil(t, err)
+
+		t.Log("\ttest:0\tshould keep snake_case keys.")
+		{
+			out, err := SnakeCase.Transform(b)
+			assert.Nil(t, err)
+			assert.Equal(t, string(b), string(out))
+		}
+
+		t.Log("\ttest:1\tshould rename keys to camelCase keeping their order.")
+		{
+			out, err := CamelCase.Transform(b)
+			assert.Nil(t, err)
+			assert.Equal(t, `{"id":"1","email":"a@domain.zone","verified":false,"firstName":"Ada","displayName":"ada","createdAt":"2020-01-02T03:04:05Z"}`, string(out))
+		}
+	}
+
+	t.Log("with nested document.")
+	{
+		b := []byte(`{"users":[{"first_name":"Ada"}],"total_count":10,"error":{"fields":{"password_confirmation":"required"}}}` + "\n")
+
+		t.Log("\ttest:0\tshould rename nested keys except within kept ones.")
+		{
+			out, err := CamelCase.Transform(b, "fields")
+			assert.Nil(t, err)
+			assert.Equal(t, `{"users":[{"firstName":"Ada"}],"totalCount":10,"error":{"fields":{"password_confirmation":"required"}}}`+"\n", string(out))
+		}
+
+		t.Log("\ttest:1\tshould reject invalid JSON.")
+		{
+			_, err := CamelCase.Transform([]byte(`{"first_name":`))
+			assert.NotNil(t, err)
+		}
+	}
+
+	t.Log("with naming names.")
+	{
+		t.Log("\ttest:0\tshould parse supported namings.")
+		{
+			for name, want := range map[string]Naming{"": SnakeCase, "snake_case": SnakeCase, "camelCase": CamelCase} {
+				n, err := ParseNaming(name)
+				assert.Nil(t, err)
+				assert.Equal(t, want, n)
+			}
+
+			_, err := ParseNaming("kebab-case")
+			assert.NotNil(t, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package transport holds wire representations of requests and responses
+// and their mapping to domain entities.
+package transport
+
+import (
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+)
+
+// RegisterRequest is a registration or user update request body.
+type RegisterRequest struct {
+	Email                string `json:"email"`
+	Username             string `json:"username"`
+	Password             string `json:"password"`
+	PasswordConfirmation string `json:"password_confirmation"`
+	FirstName            string `json:"first_name"`
+	LastName             string `json:"last_name"`
+	DisplayName          string `json:"display_name"`
+	AcceptedTerms        bool   `json:"accepted_terms"`
+}
+
+// Form maps the request to a domain form.
+func (r *RegisterRequest) Form() *entities.Form {
+	return &entities.Form{
+		Email:                r.Email,
+		Username:             r.Username,
+		Password:             r.Password,
+		PasswordConfirmation: r.PasswordConfirmation,
+		FirstName:            r.FirstName,
+		LastName:             r.LastName,
+		DisplayName:          r.DisplayName,
+		AcceptedTerms:        r.AcceptedTerms,
+	}
+}
+
+// UserResponse is a user representation safe to return to clients.
+type UserResponse struct {
+	ID       string `json:"id"`
+	Email    string `json:"email"`
+	Username string `json:"username,omitempty"`
+	Verified bool   `json:"verified"`
+	Role     string `json:"role,omitempty"`
+
+	FirstName   string `json:"first_name,omitempty"`
+	LastName    string `json:"last_name,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// NewUserResponse maps the user to its response, secrets are already left
+// out of u.
+func NewUserResponse(u entities.SafeUser) *UserResponse {
+	return &UserResponse{
+		ID:       u.ID,
+		Email:    u.Email,
+		Username: u.Username,
+		Verified: u.Verified,
+		Role:     u.Role,
+
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		DisplayName: u.DisplayName,
+
+		CreatedAt: timestamp(u.CreatedAt),
+		UpdatedAt: timestamp(u.UpdatedAt),
+	}
+}
+
+// timestamp formats t in RFC3339 leaving unknown times empty.
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// UserListResponse is a page of users.
+type UserListResponse struct {
+	Users []*UserResponse `json:"users"`
+	Total int             `json:"total"`
+	Page  int             `json:"page"`
+	Limit int             `json:"limit"`
+}
+
+// NewUserListResponse maps a page of users to its response.
+func NewUserListResponse(users []entities.User, total, page, limit int) *UserListResponse {
+	resp := UserListResponse{
+		Users: make([]*UserResponse, 0, len(users)),
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	}
+	for i := range users {
+		resp.Users = append(resp.Users, NewUserResponse(users[i].Safe()))
+	}
+
+	return &resp
+}
@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/newtondev/service_object/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransport(t *testing.T) {
+	t.Log("with registration request.")
+	{
+		t.Log("\ttest:0\tshould map request body to a form.")
+		{
+			var r RegisterRequest
+			assert.Nil(t, json.Unmarshal([]byte(`{"email": "a@domain.zone", "password": "qwerty", "password_confirmation": "qwerty", "first_name": "Ada"}`), &r))
+
+			f := r.Form()
+			assert.Equal(t, "a@domain.zone", f.Email)
+			assert.Equal(t, "qwerty", f.Password)
+			assert.Equal(t, "qwerty", f.PasswordConfirmation)
+			assert.Equal(t, "Ada", f.FirstName)
+		}
+	}
+
+	t.Log("with user.")
+	{
+		u := entities.User{ID: "1", Email: "a@domain.zone", Password: "hash", Verified: true}
+
+		t.Log("\ttest:0\tshould leave out the password hash.")
+		{
+			b, err := json.Marshal(NewUserResponse(u.Safe()))
+			assert.Nil(t, err)
+			assert.JSONEq(t, `{"id": "1", "email": "a@domain.zone", "verified": true}`, string(b))
+		}
+
+		t.Log("\ttest:1\tshould format timestamps in RFC3339.")
+		{
+			u := u
+			u.CreatedAt = time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+			u.UpdatedAt = u.CreatedAt.Add(time.Hour)
+
+			r := NewUserResponse(u.Safe())
+			assert.Equal(t, "2020-01-02T03:04:05Z", r.CreatedAt)
+			assert.Equal(t, "2020-01-02T04:04:05Z", r.UpdatedAt)
+		}
+
+		t.Log("\ttest:2\tshould map a page of users.")
+		{
+			l := NewUserListResponse([]entities.User{u}, 3, 2, 1)
+			assert.Len(t, l.Users, 1)
+			assert.Equal(t, "a@domain.zone", l.Users[0].Email)
+			assert.Equal(t, 3, l.Total)
+			assert.Equal(t, 2, l.Page)
+			assert.Equal(t, 1, l.Limit)
+		}
+	}
+}